@@ -0,0 +1,9 @@
+package api
+
+import _ "embed"
+
+// Spec is the OpenAPI description of the HTTP API. It is served at /api/openapi.yaml
+// and used to validate traffic outside production.
+//
+//go:embed openapi.yaml
+var Spec []byte
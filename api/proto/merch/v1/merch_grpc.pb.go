@@ -0,0 +1,253 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: merch/v1/merch.proto
+
+// gRPC API of the merch shop. It mirrors the /api/v2 HTTP routes and shares their
+// error codes: a failed call carries a google.rpc.ErrorInfo detail whose reason is
+// the code sent in ErrorResponse.code over HTTP, e.g. INSUFFICIENT_FUNDS.
+
+package merchv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	MerchShop_Auth_FullMethodName     = "/merch.v1.MerchShop/Auth"
+	MerchShop_Info_FullMethodName     = "/merch.v1.MerchShop/Info"
+	MerchShop_SendCoin_FullMethodName = "/merch.v1.MerchShop/SendCoin"
+	MerchShop_BuyItem_FullMethodName  = "/merch.v1.MerchShop/BuyItem"
+)
+
+// MerchShopClient is the client API for MerchShop service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// MerchShop requires the token returned by Auth in the "authorization" metadata
+// as "Bearer <token>" for every call but Auth.
+type MerchShopClient interface {
+	// Auth signs the user in, registering them with the starting balance on first sign in.
+	Auth(ctx context.Context, in *AuthRequest, opts ...grpc.CallOption) (*AuthResponse, error)
+	// Info returns the balance, inventory and coin history of the user.
+	Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error)
+	// SendCoin transfers coins to another user.
+	SendCoin(ctx context.Context, in *SendCoinRequest, opts ...grpc.CallOption) (*Transfer, error)
+	// BuyItem buys a single item for coins.
+	BuyItem(ctx context.Context, in *BuyItemRequest, opts ...grpc.CallOption) (*Purchase, error)
+}
+
+type merchShopClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMerchShopClient(cc grpc.ClientConnInterface) MerchShopClient {
+	return &merchShopClient{cc}
+}
+
+func (c *merchShopClient) Auth(ctx context.Context, in *AuthRequest, opts ...grpc.CallOption) (*AuthResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AuthResponse)
+	err := c.cc.Invoke(ctx, MerchShop_Auth_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *merchShopClient) Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InfoResponse)
+	err := c.cc.Invoke(ctx, MerchShop_Info_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *merchShopClient) SendCoin(ctx context.Context, in *SendCoinRequest, opts ...grpc.CallOption) (*Transfer, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Transfer)
+	err := c.cc.Invoke(ctx, MerchShop_SendCoin_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *merchShopClient) BuyItem(ctx context.Context, in *BuyItemRequest, opts ...grpc.CallOption) (*Purchase, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Purchase)
+	err := c.cc.Invoke(ctx, MerchShop_BuyItem_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MerchShopServer is the server API for MerchShop service.
+// All implementations must embed UnimplementedMerchShopServer
+// for forward compatibility.
+//
+// MerchShop requires the token returned by Auth in the "authorization" metadata
+// as "Bearer <token>" for every call but Auth.
+type MerchShopServer interface {
+	// Auth signs the user in, registering them with the starting balance on first sign in.
+	Auth(context.Context, *AuthRequest) (*AuthResponse, error)
+	// Info returns the balance, inventory and coin history of the user.
+	Info(context.Context, *InfoRequest) (*InfoResponse, error)
+	// SendCoin transfers coins to another user.
+	SendCoin(context.Context, *SendCoinRequest) (*Transfer, error)
+	// BuyItem buys a single item for coins.
+	BuyItem(context.Context, *BuyItemRequest) (*Purchase, error)
+	mustEmbedUnimplementedMerchShopServer()
+}
+
+// UnimplementedMerchShopServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMerchShopServer struct{}
+
+func (UnimplementedMerchShopServer) Auth(context.Context, *AuthRequest) (*AuthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Auth not implemented")
+}
+func (UnimplementedMerchShopServer) Info(context.Context, *InfoRequest) (*InfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Info not implemented")
+}
+func (UnimplementedMerchShopServer) SendCoin(context.Context, *SendCoinRequest) (*Transfer, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendCoin not implemented")
+}
+func (UnimplementedMerchShopServer) BuyItem(context.Context, *BuyItemRequest) (*Purchase, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BuyItem not implemented")
+}
+func (UnimplementedMerchShopServer) mustEmbedUnimplementedMerchShopServer() {}
+func (UnimplementedMerchShopServer) testEmbeddedByValue()                   {}
+
+// UnsafeMerchShopServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MerchShopServer will
+// result in compilation errors.
+type UnsafeMerchShopServer interface {
+	mustEmbedUnimplementedMerchShopServer()
+}
+
+func RegisterMerchShopServer(s grpc.ServiceRegistrar, srv MerchShopServer) {
+	// If the following call pancis, it indicates UnimplementedMerchShopServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&MerchShop_ServiceDesc, srv)
+}
+
+func _MerchShop_Auth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MerchShopServer).Auth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MerchShop_Auth_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MerchShopServer).Auth(ctx, req.(*AuthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MerchShop_Info_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MerchShopServer).Info(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MerchShop_Info_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MerchShopServer).Info(ctx, req.(*InfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MerchShop_SendCoin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendCoinRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MerchShopServer).SendCoin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MerchShop_SendCoin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MerchShopServer).SendCoin(ctx, req.(*SendCoinRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MerchShop_BuyItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BuyItemRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MerchShopServer).BuyItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MerchShop_BuyItem_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MerchShopServer).BuyItem(ctx, req.(*BuyItemRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// MerchShop_ServiceDesc is the grpc.ServiceDesc for MerchShop service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MerchShop_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "merch.v1.MerchShop",
+	HandlerType: (*MerchShopServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Auth",
+			Handler:    _MerchShop_Auth_Handler,
+		},
+		{
+			MethodName: "Info",
+			Handler:    _MerchShop_Info_Handler,
+		},
+		{
+			MethodName: "SendCoin",
+			Handler:    _MerchShop_SendCoin_Handler,
+		},
+		{
+			MethodName: "BuyItem",
+			Handler:    _MerchShop_BuyItem_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "merch/v1/merch.proto",
+}
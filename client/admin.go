@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"merch-shop/internal/models"
+	"net/http"
+	"net/url"
+)
+
+// The admin methods need a client holding the admin token of the deployment, see WithToken.
+
+// GrantCoins adds amount coins to the balance of the user named username.
+func (c *Client) GrantCoins(ctx context.Context, username string, amount int) (*CoinGrant, error) {
+	grantRequest := &models.GrantCoinsRequest{
+		Amount: amount,
+	}
+
+	grant := &CoinGrant{}
+	if err := c.do(ctx, http.MethodPost, "/api/admin/users/"+url.PathEscape(username)+"/coins", grantRequest, grant); err != nil {
+		return nil, err
+	}
+
+	return grant, nil
+}
+
+// DeactivateUser hides the user named username from the shop. Their history is kept
+// and the name stays taken.
+func (c *Client) DeactivateUser(ctx context.Context, username string) error {
+	return c.do(ctx, http.MethodPost, "/api/admin/users/"+url.PathEscape(username)+"/deactivate", nil, nil)
+}
+
+// Items returns the items on sale with their prices, ordered by type.
+func (c *Client) Items(ctx context.Context) ([]*Item, error) {
+	list := &models.ItemList{}
+	if err := c.do(ctx, http.MethodGet, "/api/admin/items", nil, list); err != nil {
+		return nil, err
+	}
+
+	return list.Items, nil
+}
+
+// SetItemPrice changes the price of an item, putting it on sale if it is new.
+func (c *Client) SetItemPrice(ctx context.Context, item string, price int) (*Item, error) {
+	priceRequest := &models.SetItemPriceRequest{
+		Price: price,
+	}
+
+	updated := &Item{}
+	if err := c.do(ctx, http.MethodPut, "/api/admin/items/"+url.PathEscape(item), priceRequest, updated); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,268 @@
+// Package client is a typed Go client for the merch-shop HTTP API.
+//
+//	c := client.New("http://localhost:8080")
+//	if _, err := c.Auth(ctx, "alice", "password"); err != nil {
+//		return err
+//	}
+//	transfer, err := c.SendCoin(ctx, "bob", 10)
+//
+// Errors returned by the API are *Error values carrying the stable error code.
+//
+// Reads and Auth are retried on server errors. Transfers and purchases are not, a failed one
+// may still have been performed, so check Info before sending it again.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"merch-shop/internal/models"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 2
+	defaultRetryWait  = 100 * time.Millisecond
+	maxRetryWait      = 5 * time.Second
+
+	authPath = "/api/v2/auth"
+)
+
+// The API types are aliases of the ones the server encodes, so they can be named outside this module.
+type (
+	InfoResponse    = models.InfoResponse
+	InventoryItem   = models.InventoryItem
+	CoinHistory     = models.CoinHistory
+	CoinTransaction = models.CoinTransaction
+	Transfer        = models.Transfer
+	Purchase        = models.Purchase
+	Item            = models.Item
+	CoinGrant       = models.CoinGrant
+)
+
+// Client calls the API of a single merch-shop deployment. It keeps the token of the last
+// successful Auth and sends it with every other call. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	retryWait  time.Duration
+
+	mu    sync.RWMutex
+	token string
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests, by default one with a 30s timeout.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries sets how many times a request failing with a 5xx status is retried, 2 by default,
+// and the wait before the first retry, doubled for every next one. Zero maxRetries disables retries.
+// Only requests that are safe to repeat are retried: reads, Auth and PUTs, never transfers,
+// purchases or grants.
+func WithRetries(maxRetries int, wait time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryWait = wait
+	}
+}
+
+// WithToken starts the client with a token issued earlier, skipping Auth.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// New returns a client for the API served at baseURL, e.g. "http://localhost:8080".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		maxRetries: defaultMaxRetries,
+		retryWait:  defaultRetryWait,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Token returns the token sent with requests, empty before Auth.
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.token
+}
+
+// SetToken replaces the token sent with requests.
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.token = token
+}
+
+// Auth logs the user in, registering them on first use, and stores the token for the
+// next calls. It returns the token so it can be cached and passed to WithToken later.
+func (c *Client) Auth(ctx context.Context, username, password string) (string, error) {
+	authRequest := &models.AuthRequest{
+		Username: username,
+		Password: password,
+	}
+
+	authResponse := &models.AuthResponse{}
+	if err := c.do(ctx, http.MethodPost, authPath, authRequest, authResponse); err != nil {
+		return "", err
+	}
+
+	c.SetToken(authResponse.Token)
+
+	return authResponse.Token, nil
+}
+
+// Info returns the balance, inventory and coin history of the user.
+func (c *Client) Info(ctx context.Context) (*InfoResponse, error) {
+	info := &InfoResponse{}
+	if err := c.do(ctx, http.MethodGet, "/api/v2/info", nil, info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// SendCoin transfers amount coins to the user named toUser.
+func (c *Client) SendCoin(ctx context.Context, toUser string, amount int) (*Transfer, error) {
+	sendCoinRequest := &models.SendCoinRequest{
+		ReceiverName: toUser,
+		Amount:       amount,
+	}
+
+	transfer := &Transfer{}
+	if err := c.do(ctx, http.MethodPost, "/api/v2/transfers", sendCoinRequest, transfer); err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// BuyItem buys one item.
+func (c *Client) BuyItem(ctx context.Context, item string) (*Purchase, error) {
+	purchaseRequest := &models.PurchaseRequest{
+		Item: item,
+	}
+
+	purchase := &Purchase{}
+	if err := c.do(ctx, http.MethodPost, "/api/v2/purchases", purchaseRequest, purchase); err != nil {
+		return nil, err
+	}
+
+	return purchase, nil
+}
+
+// do sends a request with body encoded as JSON and decodes a successful response into out,
+// which is nil for responses without a body.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+	}
+
+	maxRetries := c.maxRetries
+	if !idempotent(method, path) {
+		maxRetries = 0
+	}
+
+	wait := c.retryWait
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, path, payload, out)
+
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || attempt >= maxRetries || !retryable(apiErr.StatusCode) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait = min(2*wait, maxRetryWait)
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload []byte, out any) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if token := c.Token(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s %s response: %w", method, path, err)
+	}
+
+	return nil
+}
+
+// idempotent reports whether a request may be sent again without repeating its effect. A failed
+// request may still have gone through, the server or a gateway in front of it answering 5xx
+// after the commit, so transfers and purchases are never repeated. Auth registers the user once
+// and only logs them in after that.
+func idempotent(method, path string) bool {
+	return method == http.MethodGet || method == http.MethodPut || path == authPath
+}
+
+// retryable reports whether a request that failed with status may succeed if sent again.
+func retryable(status int) bool {
+	switch status {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,289 @@
+package client
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"merch-shop/internal/config"
+	"merch-shop/internal/handlers"
+	"merch-shop/internal/health"
+	"merch-shop/internal/metrics"
+	"merch-shop/internal/repository"
+	"merch-shop/internal/service"
+	"merch-shop/internal/stream"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminToken = "admin-0123456789abcdef0123456789abcdef"
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	// The client has to send what the OpenAPI spec describes.
+	return newTestServerWithMode(t, config.ModeTest)
+}
+
+func newTestServerWithMode(t *testing.T, mode string) *httptest.Server {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.JWT.SecretKey = "0123456789abcdef0123456789abcdef"
+	cfg.JWT.TokenExpiry = time.Hour
+	cfg.Admin.Token = testAdminToken
+	cfg.Events.Heartbeat = time.Second
+	cfg.Events.PollInterval = time.Second
+	cfg.Events.Retention = time.Hour
+	cfg.Server.Mode = mode
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := repository.NewMemoryRepository()
+	hub := stream.NewHub(repo, cfg.Events, logger)
+	h := handlers.NewHandler(service.NewService(repo, cfg), cfg, logger, metrics.New(), health.New(time.Second), hub)
+
+	srv := httptest.NewServer(h.Routes())
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func requireAPIError(t *testing.T, err error, statusCode int, code string) *Error {
+	t.Helper()
+
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, statusCode, apiErr.StatusCode)
+	assert.Equal(t, code, apiErr.Code)
+	assert.NotEmpty(t, apiErr.RequestID)
+
+	return apiErr
+}
+
+func Test_Client(t *testing.T) {
+	ctx := context.Background()
+	srv := newTestServer(t)
+
+	alice := New(srv.URL)
+	token, err := alice.Auth(ctx, "alice", "password")
+	require.NoError(t, err)
+	assert.Equal(t, token, alice.Token())
+
+	_, err = New(srv.URL).Auth(ctx, "bob", "password")
+	require.NoError(t, err)
+
+	t.Run("send coin", func(t *testing.T) {
+		transfer, err := alice.SendCoin(ctx, "bob", 100)
+		require.NoError(t, err)
+
+		assert.Equal(t, "alice", transfer.FromUser)
+		assert.Equal(t, "bob", transfer.ToUser)
+		assert.Equal(t, 900, transfer.Balance)
+	})
+
+	t.Run("buy item", func(t *testing.T) {
+		purchase, err := alice.BuyItem(ctx, "cup")
+		require.NoError(t, err)
+
+		assert.Equal(t, "cup", purchase.Item)
+		assert.Equal(t, 880, purchase.Balance)
+	})
+
+	t.Run("info", func(t *testing.T) {
+		// A stored token works with a new client.
+		info, err := New(srv.URL, WithToken(token)).Info(ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, 880, info.Coins)
+		require.Len(t, info.Inventory, 1)
+		assert.Equal(t, "cup", info.Inventory[0].Type)
+		require.Len(t, info.CoinHistory.Sent, 1)
+		assert.Equal(t, 100, info.CoinHistory.Sent[0].Amount)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := New(srv.URL).Info(ctx)
+		requireAPIError(t, err, http.StatusUnauthorized, CodeMissingToken)
+
+		_, err = New(srv.URL).Auth(ctx, "alice", "wrong")
+		requireAPIError(t, err, http.StatusUnauthorized, CodeWrongPassword)
+
+		_, err = alice.SendCoin(ctx, "bob", 100000)
+		requireAPIError(t, err, http.StatusBadRequest, CodeInsufficientFunds)
+
+		_, err = alice.BuyItem(ctx, "yacht")
+		requireAPIError(t, err, http.StatusBadRequest, CodeItemNotFound)
+
+		_, err = alice.SendCoin(ctx, "", 0)
+		requireAPIError(t, err, http.StatusBadRequest, CodeInvalidRequest)
+
+		// Outside test mode the request gets to the handler, which names the invalid fields.
+		carol := New(newTestServerWithMode(t, config.ModeProduction).URL)
+		_, err = carol.Auth(ctx, "carol", "password")
+		require.NoError(t, err)
+
+		_, err = carol.SendCoin(ctx, "", 0)
+		apiErr := requireAPIError(t, err, http.StatusBadRequest, CodeValidationFailed)
+		assert.Equal(t, map[string]string{"toUser": "must be provided", "amount": "must be positive"}, apiErr.Fields)
+	})
+}
+
+func Test_Client_Admin(t *testing.T) {
+	ctx := context.Background()
+	srv := newTestServer(t)
+
+	alice := New(srv.URL)
+	_, err := alice.Auth(ctx, "alice", "password")
+	require.NoError(t, err)
+
+	admin := New(srv.URL, WithToken(testAdminToken))
+
+	_, err = alice.GrantCoins(ctx, "alice", 100)
+	requireAPIError(t, err, http.StatusForbidden, CodeForbidden)
+
+	grant, err := admin.GrantCoins(ctx, "alice", 100)
+	require.NoError(t, err)
+	assert.Equal(t, 1100, grant.Balance)
+
+	item, err := admin.SetItemPrice(ctx, "sticker", 5)
+	require.NoError(t, err)
+	assert.Equal(t, 5, item.Price)
+
+	items, err := admin.Items(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, items, &Item{Name: "sticker", Price: 5})
+
+	purchase, err := alice.BuyItem(ctx, "sticker")
+	require.NoError(t, err)
+	assert.Equal(t, 1095, purchase.Balance)
+
+	require.NoError(t, admin.DeactivateUser(ctx, "alice"))
+	err = admin.DeactivateUser(ctx, "alice")
+	requireAPIError(t, err, http.StatusNotFound, CodeUserNotFound)
+}
+
+func Test_Client_Retries(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		status      int
+		call        func(c *Client) error
+		wantCalls   int32
+		wantErrCode int
+	}{
+		{
+			name:   "read retried on bad gateway",
+			status: http.StatusBadGateway,
+			call: func(c *Client) error {
+				_, err := c.Info(ctx)
+				return err
+			},
+			wantCalls:   3,
+			wantErrCode: http.StatusBadGateway,
+		},
+		{
+			name:   "transfer not retried on internal error",
+			status: http.StatusInternalServerError,
+			call: func(c *Client) error {
+				_, err := c.SendCoin(ctx, "bob", 1)
+				return err
+			},
+			wantCalls:   1,
+			wantErrCode: http.StatusInternalServerError,
+		},
+		{
+			name:   "purchase not retried on gateway timeout",
+			status: http.StatusGatewayTimeout,
+			call: func(c *Client) error {
+				_, err := c.BuyItem(ctx, "cup")
+				return err
+			},
+			wantCalls:   1,
+			wantErrCode: http.StatusGatewayTimeout,
+		},
+		{
+			name:   "grant not retried",
+			status: http.StatusServiceUnavailable,
+			call: func(c *Client) error {
+				_, err := c.GrantCoins(ctx, "bob", 1)
+				return err
+			},
+			wantCalls:   1,
+			wantErrCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:   "client errors not retried",
+			status: http.StatusBadRequest,
+			call: func(c *Client) error {
+				_, err := c.BuyItem(ctx, "cup")
+				return err
+			},
+			wantCalls:   1,
+			wantErrCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				http.Error(w, "upstream failed", tt.status)
+			}))
+			defer srv.Close()
+
+			err := tt.call(New(srv.URL, WithRetries(2, time.Millisecond)))
+
+			var apiErr *Error
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, tt.wantErrCode, apiErr.StatusCode)
+			assert.Empty(t, apiErr.Code)
+			assert.Equal(t, "upstream failed\n", apiErr.Message)
+			assert.Equal(t, tt.wantCalls, calls.Load())
+		})
+	}
+
+	t.Run("succeeds after retry", func(t *testing.T) {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"token":"token"}`))
+		}))
+		defer srv.Close()
+
+		c := New(srv.URL, WithRetries(1, time.Millisecond))
+		_, err := c.Auth(ctx, "alice", "password")
+		require.NoError(t, err)
+		assert.Equal(t, "token", c.Token())
+		assert.EqualValues(t, 2, calls.Load())
+	})
+
+	t.Run("canceled context stops retries", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := New(srv.URL, WithRetries(100, time.Second)).Info(ctx)
+		assert.Less(t, time.Since(start), time.Second)
+
+		// The last response is returned, not the context error.
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	})
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"merch-shop/internal/models"
+	"net/http"
+)
+
+// Error codes of Error.Code. Unlike the messages they are part of the API contract and never change.
+const (
+	CodeInvalidRequest       = models.CodeInvalidRequest
+	CodeValidationFailed     = models.CodeValidationFailed
+	CodeUnsupportedMediaType = models.CodeUnsupportedMediaType
+	CodeBodyTooLarge         = models.CodeBodyTooLarge
+	CodeEmptyCredentials     = models.CodeEmptyCredentials
+	CodeEmptyReceiver        = models.CodeEmptyReceiver
+	CodeInvalidAmount        = models.CodeInvalidAmount
+	CodeEmptyItem            = models.CodeEmptyItem
+	CodePasswordTooLong      = models.CodePasswordTooLong
+	CodeWrongPassword        = models.CodeWrongPassword
+	CodeMissingToken         = models.CodeMissingToken
+	CodeInvalidAuthHeader    = models.CodeInvalidAuthHeader
+	CodeInvalidToken         = models.CodeInvalidToken
+	CodeTokenExpired         = models.CodeTokenExpired
+	CodeForbidden            = models.CodeForbidden
+	CodeUserDeactivated      = models.CodeUserDeactivated
+	CodeUserNotFound         = models.CodeUserNotFound
+	CodeReceiverNotFound     = models.CodeReceiverNotFound
+	CodeItemNotFound         = models.CodeItemNotFound
+	CodeNotFound             = models.CodeNotFound
+	CodeAlreadyExists        = models.CodeAlreadyExists
+	CodeInsufficientFunds    = models.CodeInsufficientFunds
+	CodeBalanceLimit         = models.CodeBalanceLimit
+	CodeSelfTransfer         = models.CodeSelfTransfer
+	CodeInternalError        = models.CodeInternalError
+)
+
+// maxErrorBody bounds how much of an error response is read, proxies may send whole pages.
+const maxErrorBody = 64 << 10
+
+// Error is a response of the API with a 4xx or 5xx status, decoded from models.ErrorResponse.
+// Check it with errors.As and compare Code, not Message.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	// Fields maps each invalid request field to its problem when Code is CodeValidationFailed.
+	Fields map[string]string
+	// RequestID identifies the request in the service logs.
+	RequestID string
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("merch-shop: status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("merch-shop: %s: %s", e.Code, e.Message)
+}
+
+// decodeError reads the error response. Responses from proxies in front of the service
+// aren't JSON, their status and body are kept as they are.
+func decodeError(resp *http.Response) error {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if err != nil {
+		return fmt.Errorf("read error response: %w", err)
+	}
+
+	apiErr := &Error{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("X-Request-ID"),
+	}
+
+	errorResponse := &models.ErrorResponse{}
+	if err := json.Unmarshal(body, errorResponse); err != nil || errorResponse.Code == "" {
+		apiErr.Message = string(body)
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+
+	apiErr.Code = errorResponse.Code
+	apiErr.Message = errorResponse.Errors
+	apiErr.Fields = errorResponse.Fields
+	if errorResponse.RequestID != "" {
+		apiErr.RequestID = errorResponse.RequestID
+	}
+
+	return apiErr
+}
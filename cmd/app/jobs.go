@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// jobs runs background work that outlives a single request and has to be
+// stopped and waited for before the process exits.
+type jobs struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newJobs() *jobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobs{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in its own goroutine. fn must return soon after its context is canceled.
+func (j *jobs) Go(fn func(ctx context.Context)) {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		fn(j.ctx)
+	}()
+}
+
+// Stop cancels all jobs and waits until they return or ctx expires.
+func (j *jobs) Stop(ctx context.Context) error {
+	j.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
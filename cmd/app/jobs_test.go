@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_jobs(t *testing.T) {
+	t.Run("waits for jobs to return", func(t *testing.T) {
+		j := newJobs()
+
+		stopped := false
+		j.Go(func(ctx context.Context) {
+			<-ctx.Done()
+			time.Sleep(10 * time.Millisecond)
+			stopped = true
+		})
+
+		assert.NoError(t, j.Stop(context.Background()))
+		assert.True(t, stopped)
+	})
+
+	t.Run("gives up when the deadline expires", func(t *testing.T) {
+		j := newJobs()
+
+		release := make(chan struct{})
+		defer close(release)
+		j.Go(func(ctx context.Context) {
+			<-release
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		assert.ErrorIs(t, j.Stop(ctx), context.DeadlineExceeded)
+	})
+}
@@ -2,55 +2,245 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"merch-shop/internal/config"
-	"merch-shop/internal/dbinit"
+	"merch-shop/internal/grpcapi"
 	"merch-shop/internal/handlers"
-	"merch-shop/internal/repository"
+	"merch-shop/internal/health"
+	"merch-shop/internal/logger"
+	"merch-shop/internal/metrics"
 	"merch-shop/internal/service"
+	"merch-shop/internal/stream"
+	"merch-shop/internal/tracing"
+	"merch-shop/internal/webhook"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
+
+	"google.golang.org/grpc"
 )
 
 func main() {
-	logger := log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime)
+	log := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	configPath := flag.String("config", defaultConfigPath(), "path to config file, empty to read config from env only")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [migrate up|down|status]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
 
-	cfg, err := config.New(".")
+	cfg, err := config.New(*configPath)
 	if err != nil {
-		logger.Fatal(err)
+		fatal(log, "failed to load config", err)
+	}
+
+	// Migrations only need the database, so they run with a config the server would refuse.
+	if flag.Arg(0) == "migrate" {
+		if err := cfg.ValidateDB(); err != nil {
+			fatal(log, "invalid config", err)
+		}
+
+		if err := runMigrate(context.Background(), cfg, log, os.Stdout, flag.Args()[1:]); err != nil {
+			fatal(log, "migrate failed", err)
+		}
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		fatal(log, "invalid config", err)
 	}
 
-	db, err := dbinit.OpenDB(cfg)
+	log, err = logger.New(os.Stdout, cfg)
+	if err != nil {
+		fatal(slog.Default(), "failed to create logger", err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		fatal(log, "failed to set up tracing", err)
+	}
+
+	metrics := metrics.New()
+	checker := health.New(cfg.Health.Timeout)
+
+	repo, closeStorage, err := openStorage(context.Background(), cfg, log, metrics, checker)
 	if err != nil {
-		logger.Fatal(err)
+		fatal(log, "failed to open storage", err)
+	}
+
+	jobs := newJobs()
+
+	dispatcher := webhook.NewDispatcher(repo, cfg.Webhooks, log, metrics)
+	jobs.Go(dispatcher.Run)
+
+	hub := stream.NewHub(repo, cfg.Events, log)
+	switch cfg.DB.Driver {
+	case config.DBDriverMemory, config.DBDriverSQLite:
+		jobs.Go(hub.Poll)
+	default:
+		// Every replica listens, so streams get the events written by any of them.
+		dsn, err := cfg.DSN()
+		if err != nil {
+			fatal(log, "failed to listen for user events", err)
+		}
+		jobs.Go(func(ctx context.Context) { hub.Listen(ctx, dsn) })
 	}
 
-	repo := repository.NewPostgresRepository(db)
 	service := service.NewService(repo, cfg)
-	handler := handlers.NewHandler(service, cfg, logger)
+	handler := handlers.NewHandler(service, cfg, log, metrics, checker, hub)
 
 	srv := &http.Server{
 		Addr:         net.JoinHostPort("", cfg.Server.Port),
 		Handler:      handler.Routes(),
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
+		ErrorLog:     slog.NewLogLogger(log.Handler(), slog.LevelError),
 	}
+	// Event streams never finish on their own, Shutdown would wait for them until it times out.
+	srv.RegisterOnShutdown(hub.Close)
 
-	logger.Printf("starting backend on %s", srv.Addr)
+	// A server that fails to start or dies takes the whole process down the same way a signal does.
+	serverErr := make(chan error, 3)
+
+	log.Info("starting backend", slog.String("addr", srv.Addr))
 	go func() {
-		logger.Print(srv.ListenAndServe())
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- fmt.Errorf("server: %w", err)
+		}
 	}()
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
-	<-quit
+	var grpcSrv *grpc.Server
+	if cfg.Server.GRPCPort != "" {
+		grpcSrv = grpcapi.NewServer(service, cfg, log, metrics)
+		grpcAddr := net.JoinHostPort("", cfg.Server.GRPCPort)
+
+		log.Info("starting grpc server", slog.String("addr", grpcAddr))
+		go func() {
+			lis, err := net.Listen("tcp", grpcAddr)
+			if err == nil {
+				err = grpcSrv.Serve(lis)
+			}
+			if err != nil {
+				serverErr <- fmt.Errorf("grpc server: %w", err)
+			}
+		}()
+	}
+
+	var adminSrv *http.Server
+	if cfg.Metrics.Enabled && cfg.Metrics.Port != "" {
+		adminMux := http.NewServeMux()
+		adminMux.Handle("GET /metrics", metrics.Handler())
+
+		adminSrv = &http.Server{
+			Addr:         net.JoinHostPort("", cfg.Metrics.Port),
+			Handler:      adminMux,
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+			ErrorLog:     slog.NewLogLogger(log.Handler(), slog.LevelError),
+		}
+
+		log.Info("starting admin server", slog.String("addr", adminSrv.Addr))
+		go func() {
+			if err := adminSrv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- fmt.Errorf("admin server: %w", err)
+			}
+		}()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		log.Info("shutdown signal received")
+	case runErr = <-serverErr:
+		log.Error("server failed", slog.String("error", runErr.Error()))
+	}
+
+	// A second signal kills the process right away.
+	stop()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	log.Info("shutting down backend...")
+	checker.SetShuttingDown()
+
+	if runErr == nil && cfg.Server.ShutdownDelay > 0 {
+		log.Info("readiness is failing, waiting before draining", slog.Duration("delay", cfg.Server.ShutdownDelay))
+		time.Sleep(cfg.Server.ShutdownDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
-	logger.Print("shutting down backend...")
-	srv.Shutdown(ctx)
+	errs := []error{runErr}
+
+	if err := srv.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("drain requests: %w", err))
+	}
+
+	if grpcSrv != nil {
+		if err := stopGRPC(ctx, grpcSrv); err != nil {
+			errs = append(errs, fmt.Errorf("drain grpc calls: %w", err))
+		}
+	}
+
+	if adminSrv != nil {
+		if err := adminSrv.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("stop admin server: %w", err))
+		}
+	}
+
+	if err := jobs.Stop(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("stop background jobs: %w", err))
+	}
+
+	if err := closeStorage(); err != nil {
+		errs = append(errs, fmt.Errorf("close storage: %w", err))
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("flush traces: %w", err))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		fatal(log, "unclean shutdown", err)
+	}
+
+	log.Info("shutdown complete")
+}
+
+// stopGRPC waits for in-flight calls like http.Server.Shutdown does, and cuts them off
+// when ctx is done.
+func stopGRPC(ctx context.Context, srv *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		srv.Stop()
+		return ctx.Err()
+	}
+}
+
+func defaultConfigPath() string {
+	if path, ok := os.LookupEnv("CONFIG_PATH"); ok {
+		return path
+	}
+	return "config.yml"
+}
+
+func fatal(log *slog.Logger, msg string, err error) {
+	log.Error(msg, slog.String("error", err.Error()))
+	os.Exit(1)
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"merch-shop/internal/config"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	errMigrateUsage = errors.New("usage: app [-config path] migrate up|down|status")
+	errNoSQLStorage = errors.New("in-memory storage has no database to migrate")
+)
+
+func runMigrate(ctx context.Context, cfg *config.Config, log *slog.Logger, w io.Writer, args []string) error {
+	if len(args) != 1 {
+		return errMigrateUsage
+	}
+
+	db, migrator, err := openDB(ctx, cfg, log)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 {
+			fmt.Fprintln(w, "no pending migrations")
+		}
+		for _, m := range applied {
+			fmt.Fprintf(w, "applied %d_%s\n", m.Version, m.Name)
+		}
+	case "down":
+		m, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "rolled back %d_%s\n", m.Version, m.Name)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return tw.Flush()
+	default:
+		return errMigrateUsage
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"merch-shop/internal/config"
+	"merch-shop/internal/dbinit"
+	"merch-shop/internal/health"
+	"merch-shop/internal/metrics"
+	"merch-shop/internal/migrate"
+	"merch-shop/internal/repository"
+	"merch-shop/migrations"
+	"path/filepath"
+)
+
+// openStorage builds the repository for the configured driver and registers its readiness
+// checks and metrics. The returned function releases the underlying connections.
+func openStorage(ctx context.Context, cfg *config.Config, log *slog.Logger, metrics *metrics.Metrics, checker *health.Checker) (repository.Repository, func() error, error) {
+	if cfg.DB.Driver == config.DBDriverMemory {
+		log.Warn("using in-memory storage, all data is lost on restart")
+		return repository.NewMemoryRepository(), func() error { return nil }, nil
+	}
+
+	db, migrator, err := openDB(ctx, cfg, log)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.DB.AutoMigrate {
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		log.Info("migrations applied", slog.Int("count", len(applied)), slog.Int64("version", migrator.Latest()))
+	}
+
+	metrics.RegisterDB(db, dbName(cfg))
+	checker.Add("db", db.PingContext)
+	checker.Add("migrations", migrator.Check)
+
+	if cfg.DB.Driver == config.DBDriverSQLite {
+		return repository.NewSQLiteRepository(db), db.Close, nil
+	}
+
+	return repository.NewPostgresRepository(db), db.Close, nil
+}
+
+// dbName labels the connection pool metrics: the database name for postgres, the file
+// name for sqlite.
+func dbName(cfg *config.Config) string {
+	if cfg.DB.Driver == config.DBDriverSQLite {
+		return filepath.Base(cfg.DB.SQLitePath)
+	}
+
+	return cfg.DB.Name
+}
+
+// openDB connects to the SQL database of the configured driver and prepares its migrations.
+func openDB(ctx context.Context, cfg *config.Config, log *slog.Logger) (*sql.DB, *migrate.Migrator, error) {
+	var (
+		db       *sql.DB
+		err      error
+		migrator *migrate.Migrator
+	)
+
+	switch cfg.DB.Driver {
+	case config.DBDriverMemory:
+		return nil, nil, errNoSQLStorage
+	case config.DBDriverSQLite:
+		db, err = dbinit.OpenSQLite(ctx, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		migrator, err = migrate.New(db, migrations.SQLite, migrate.DialectSQLite)
+	default:
+		db, err = dbinit.OpenDB(ctx, cfg, log)
+		if err != nil {
+			return nil, nil, err
+		}
+		migrator, err = migrate.New(db, migrations.Postgres, migrate.DialectPostgres)
+	}
+
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	return db, migrator, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"merch-shop/client"
+	"time"
+)
+
+func (c *cli) login(ctx context.Context, username string) error {
+	password, err := c.readPassword(username)
+	if err != nil {
+		return err
+	}
+
+	api := client.New(c.server)
+	token, err := api.Auth(ctx, username, password)
+	if err != nil {
+		return err
+	}
+
+	if err := c.sessions.put(c.server, session{Username: username, Token: token}); err != nil {
+		return err
+	}
+
+	return c.out.print(map[string]string{"username": username, "server": c.server},
+		[]string{"USERNAME", "SERVER"},
+		[][]any{{username, c.server}})
+}
+
+func (c *cli) balance(ctx context.Context) error {
+	api, err := c.userClient()
+	if err != nil {
+		return err
+	}
+
+	info, err := api.Info(ctx)
+	if err != nil {
+		return userError(err)
+	}
+
+	return c.out.print(map[string]int{"coins": info.Coins},
+		[]string{"COINS"},
+		[][]any{{info.Coins}})
+}
+
+func (c *cli) send(ctx context.Context, toUser string, amount int) error {
+	api, err := c.userClient()
+	if err != nil {
+		return err
+	}
+
+	transfer, err := api.SendCoin(ctx, toUser, amount)
+	if err != nil {
+		return userError(err)
+	}
+
+	return c.out.print(transfer,
+		[]string{"ID", "TO", "AMOUNT", "BALANCE", "CREATED AT"},
+		[][]any{{transfer.ID, transfer.ToUser, transfer.Amount, transfer.Balance, transfer.CreatedAt.Local().Format(time.DateTime)}})
+}
+
+func (c *cli) buy(ctx context.Context, item string) error {
+	api, err := c.userClient()
+	if err != nil {
+		return err
+	}
+
+	purchase, err := api.BuyItem(ctx, item)
+	if err != nil {
+		return userError(err)
+	}
+
+	return c.out.print(purchase,
+		[]string{"ID", "ITEM", "PRICE", "QUANTITY", "BALANCE", "CREATED AT"},
+		[][]any{{purchase.ID, purchase.Item, purchase.Price, purchase.Quantity, purchase.Balance, purchase.CreatedAt.Local().Format(time.DateTime)}})
+}
+
+func (c *cli) history(ctx context.Context) error {
+	api, err := c.userClient()
+	if err != nil {
+		return err
+	}
+
+	info, err := api.Info(ctx)
+	if err != nil {
+		return userError(err)
+	}
+
+	var rows [][]any
+	for _, ct := range info.CoinHistory.Received {
+		rows = append(rows, []any{"received", ct.FromUser, ct.Amount})
+	}
+	for _, ct := range info.CoinHistory.Sent {
+		rows = append(rows, []any{"sent", ct.ToUser, ct.Amount})
+	}
+
+	return c.out.print(info.CoinHistory, []string{"DIRECTION", "USER", "AMOUNT"}, rows)
+}
+
+func (c *cli) inventory(ctx context.Context) error {
+	api, err := c.userClient()
+	if err != nil {
+		return err
+	}
+
+	info, err := api.Info(ctx)
+	if err != nil {
+		return userError(err)
+	}
+
+	var rows [][]any
+	for _, item := range info.Inventory {
+		rows = append(rows, []any{item.Type, item.Quantity})
+	}
+
+	return c.out.print(info.Inventory, []string{"ITEM", "QUANTITY"}, rows)
+}
+
+func (c *cli) grant(ctx context.Context, username string, amount int) error {
+	api, err := c.adminClient()
+	if err != nil {
+		return err
+	}
+
+	grant, err := api.GrantCoins(ctx, username, amount)
+	if err != nil {
+		return err
+	}
+
+	return c.out.print(grant,
+		[]string{"USERNAME", "AMOUNT", "BALANCE"},
+		[][]any{{grant.Username, grant.Amount, grant.Balance}})
+}
+
+func (c *cli) items(ctx context.Context) error {
+	api, err := c.adminClient()
+	if err != nil {
+		return err
+	}
+
+	items, err := api.Items(ctx)
+	if err != nil {
+		return err
+	}
+
+	var rows [][]any
+	for _, item := range items {
+		rows = append(rows, []any{item.Name, item.Price})
+	}
+
+	return c.out.print(items, []string{"ITEM", "PRICE"}, rows)
+}
+
+func (c *cli) setPrice(ctx context.Context, itemName string, price int) error {
+	api, err := c.adminClient()
+	if err != nil {
+		return err
+	}
+
+	item, err := api.SetItemPrice(ctx, itemName, price)
+	if err != nil {
+		return err
+	}
+
+	return c.out.print(item, []string{"ITEM", "PRICE"}, [][]any{{item.Name, item.Price}})
+}
+
+func (c *cli) deactivate(ctx context.Context, username string) error {
+	api, err := c.adminClient()
+	if err != nil {
+		return err
+	}
+
+	if err := api.DeactivateUser(ctx, username); err != nil {
+		return err
+	}
+
+	return c.out.print(map[string]any{"username": username, "active": false},
+		[]string{"USERNAME", "ACTIVE"},
+		[][]any{{username, false}})
+}
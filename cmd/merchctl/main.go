@@ -0,0 +1,237 @@
+// Command merchctl calls the merch-shop API from the command line.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"merch-shop/client"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const usage = `usage: merchctl [flags] <command> [args]
+
+commands:
+  login <username>             log in, registering on first use, and cache the token
+  logout                       forget the cached token
+  balance                      show the balance
+  send <user> <amount>         send coins to a user
+  buy <item>                   buy an item
+  history                      show received and sent coins
+  inventory                    show bought items
+
+admin commands, authenticated with -admin-token:
+  admin grant <user> <amount>  add coins to the balance of a user
+  admin items                  list items with their prices
+  admin set-price <item> <n>   change the price of an item, adding it if it is new
+  admin deactivate <user>      hide a user from the shop
+
+The password for login is read from MERCHCTL_PASSWORD, the terminal, or the first line of stdin.
+
+flags:
+`
+
+var (
+	errUsage       = errors.New("invalid arguments")
+	errNotLoggedIn = errors.New("not logged in, run merchctl login <username>")
+	errNoAdmin     = errors.New("admin token is not set, pass -admin-token or set MERCHCTL_ADMIN_TOKEN")
+)
+
+// cli holds what the commands share: where to send requests, how to print results
+// and the cached sessions.
+type cli struct {
+	server     string
+	adminToken string
+	timeout    time.Duration
+	out        *printer
+	stdin      io.Reader
+	stderr     io.Writer
+	sessions   *sessionStore
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if err == nil {
+		return
+	}
+
+	if errors.Is(err, errUsage) {
+		os.Exit(2)
+	}
+	fmt.Fprintf(os.Stderr, "merchctl: %v\n", err)
+	os.Exit(1)
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("merchctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+
+	c := &cli{stdin: stdin, stderr: stderr}
+	output := fs.String("o", outputTable, "output format, table or json")
+	fs.StringVar(&c.server, "server", envOr("MERCHCTL_SERVER", "http://localhost:8080"), "API address (env MERCHCTL_SERVER)")
+	// The token is not used as the flag default, so it is not printed with the usage.
+	fs.StringVar(&c.adminToken, "admin-token", "", "admin token (env MERCHCTL_ADMIN_TOKEN)")
+	fs.DurationVar(&c.timeout, "timeout", 30*time.Second, "timeout of a command")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+
+	if *output != outputTable && *output != outputJSON {
+		fmt.Fprintln(stderr, "merchctl: -o must be table or json")
+		return errUsage
+	}
+	c.out = &printer{w: stdout, format: *output}
+	c.server = strings.TrimRight(c.server, "/")
+	if c.adminToken == "" {
+		c.adminToken = os.Getenv("MERCHCTL_ADMIN_TOKEN")
+	}
+
+	var err error
+	if c.sessions, err = newSessionStore(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	err = c.dispatch(ctx, fs.Args())
+	if errors.Is(err, errUsage) {
+		fs.Usage()
+	}
+	return err
+}
+
+func (c *cli) dispatch(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	cmd, args := args[0], args[1:]
+	switch {
+	case cmd == "login" && len(args) == 1:
+		return c.login(ctx, args[0])
+	case cmd == "logout" && len(args) == 0:
+		return c.sessions.remove(c.server)
+	case cmd == "balance" && len(args) == 0:
+		return c.balance(ctx)
+	case cmd == "send" && len(args) == 2:
+		amount, err := strconv.Atoi(args[1])
+		if err != nil {
+			return errUsage
+		}
+		return c.send(ctx, args[0], amount)
+	case cmd == "buy" && len(args) == 1:
+		return c.buy(ctx, args[0])
+	case cmd == "history" && len(args) == 0:
+		return c.history(ctx)
+	case cmd == "inventory" && len(args) == 0:
+		return c.inventory(ctx)
+	case cmd == "admin" && len(args) > 0:
+		return c.admin(ctx, args[0], args[1:])
+	}
+
+	return errUsage
+}
+
+func (c *cli) admin(ctx context.Context, cmd string, args []string) error {
+	switch {
+	case cmd == "grant" && len(args) == 2:
+		amount, err := strconv.Atoi(args[1])
+		if err != nil {
+			return errUsage
+		}
+		return c.grant(ctx, args[0], amount)
+	case cmd == "items" && len(args) == 0:
+		return c.items(ctx)
+	case cmd == "set-price" && len(args) == 2:
+		price, err := strconv.Atoi(args[1])
+		if err != nil {
+			return errUsage
+		}
+		return c.setPrice(ctx, args[0], price)
+	case cmd == "deactivate" && len(args) == 1:
+		return c.deactivate(ctx, args[0])
+	}
+
+	return errUsage
+}
+
+// userClient returns a client with the cached token of the server.
+func (c *cli) userClient() (*client.Client, error) {
+	sess, ok, err := c.sessions.get(c.server)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errNotLoggedIn
+	}
+
+	return client.New(c.server, client.WithToken(sess.Token)), nil
+}
+
+func (c *cli) adminClient() (*client.Client, error) {
+	if c.adminToken == "" {
+		return nil, errNoAdmin
+	}
+
+	return client.New(c.server, client.WithToken(c.adminToken)), nil
+}
+
+// userError points to login when the cached token is no longer accepted.
+func userError(err error) error {
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case client.CodeTokenExpired, client.CodeInvalidToken:
+			return fmt.Errorf("%w, run merchctl login <username> again", err)
+		}
+	}
+	return err
+}
+
+func (c *cli) readPassword(username string) (string, error) {
+	if password, ok := os.LookupEnv("MERCHCTL_PASSWORD"); ok {
+		return password, nil
+	}
+
+	if f, ok := c.stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprintf(c.stderr, "Password for %s: ", username)
+		password, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(c.stderr)
+		return string(password), err
+	}
+
+	line, err := bufio.NewReader(c.stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func envOr(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"merch-shop/internal/config"
+	"merch-shop/internal/handlers"
+	"merch-shop/internal/health"
+	"merch-shop/internal/metrics"
+	"merch-shop/internal/repository"
+	"merch-shop/internal/service"
+	"merch-shop/internal/stream"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminToken = "admin-0123456789abcdef0123456789abcdef"
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.JWT.SecretKey = "0123456789abcdef0123456789abcdef"
+	cfg.JWT.TokenExpiry = time.Hour
+	cfg.Admin.Token = testAdminToken
+	cfg.Events.Heartbeat = time.Second
+	cfg.Events.PollInterval = time.Second
+	cfg.Events.Retention = time.Hour
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repo := repository.NewMemoryRepository()
+	hub := stream.NewHub(repo, cfg.Events, logger)
+	h := handlers.NewHandler(service.NewService(repo, cfg), cfg, logger, metrics.New(), health.New(time.Second), hub)
+
+	srv := httptest.NewServer(h.Routes())
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// merchctl runs the command against srv with the password on stdin and returns its output.
+func merchctl(t *testing.T, srv *httptest.Server, stdin string, args ...string) (string, error) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-server", srv.URL, "-admin-token", testAdminToken}, args...)
+	err := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+
+	return stdout.String(), err
+}
+
+func Test_Merchctl(t *testing.T) {
+	// Sessions are cached in the user config directory.
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("HOME", configDir)
+
+	srv := newTestServer(t)
+
+	_, err := merchctl(t, srv, "", "balance")
+	require.ErrorIs(t, err, errNotLoggedIn)
+
+	_, err = merchctl(t, srv, "password\n", "login", "bob")
+	require.NoError(t, err)
+
+	out, err := merchctl(t, srv, "password\n", "login", "alice")
+	require.NoError(t, err)
+	assert.Contains(t, out, "alice")
+
+	store, err := newSessionStore()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(store.path, configDir), store.path)
+	stat, err := os.Stat(store.path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), stat.Mode().Perm(), "tokens must only be readable by the user")
+
+	out, err = merchctl(t, srv, "", "balance")
+	require.NoError(t, err)
+	assert.Equal(t, "COINS\n1000\n", out)
+
+	out, err = merchctl(t, srv, "", "-o", "json", "send", "bob", "100")
+	require.NoError(t, err)
+	var transfer struct {
+		ToUser  string `json:"toUser"`
+		Balance int    `json:"balance"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &transfer))
+	assert.Equal(t, "bob", transfer.ToUser)
+	assert.Equal(t, 900, transfer.Balance)
+
+	_, err = merchctl(t, srv, "", "buy", "cup")
+	require.NoError(t, err)
+
+	out, err = merchctl(t, srv, "", "inventory")
+	require.NoError(t, err)
+	assert.Equal(t, "ITEM  QUANTITY\ncup   1\n", out)
+
+	out, err = merchctl(t, srv, "", "history")
+	require.NoError(t, err)
+	assert.Equal(t, "DIRECTION  USER  AMOUNT\nsent       bob   100\n", out)
+
+	_, err = merchctl(t, srv, "", "send", "bob", "100000")
+	assert.ErrorContains(t, err, "INSUFFICIENT_FUNDS")
+
+	t.Run("admin", func(t *testing.T) {
+		out, err := merchctl(t, srv, "", "admin", "grant", "alice", "20")
+		require.NoError(t, err)
+		assert.Equal(t, "USERNAME  AMOUNT  BALANCE\nalice     20      900\n", out)
+
+		_, err = merchctl(t, srv, "", "admin", "set-price", "sticker", "5")
+		require.NoError(t, err)
+
+		out, err = merchctl(t, srv, "", "-o", "json", "admin", "items")
+		require.NoError(t, err)
+		assert.Contains(t, out, `"type": "sticker"`)
+
+		_, err = merchctl(t, srv, "", "admin", "deactivate", "bob")
+		require.NoError(t, err)
+
+		_, err = merchctl(t, srv, "", "send", "bob", "1")
+		assert.ErrorContains(t, err, "RECEIVER_NOT_FOUND")
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		for _, args := range [][]string{
+			{},
+			{"send", "bob"},
+			{"send", "bob", "many"},
+			{"admin", "grant", "bob"},
+			{"-o", "yaml", "balance"},
+		} {
+			_, err := merchctl(t, srv, "", args...)
+			assert.ErrorIs(t, err, errUsage, args)
+		}
+	})
+
+	t.Run("logout", func(t *testing.T) {
+		_, err := merchctl(t, srv, "", "logout")
+		require.NoError(t, err)
+
+		_, err = merchctl(t, srv, "", "balance")
+		assert.ErrorIs(t, err, errNotLoggedIn)
+	})
+}
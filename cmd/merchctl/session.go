@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// session is the login cached for a server.
+type session struct {
+	Username string `json:"username"`
+	Token    string `json:"token"`
+}
+
+// sessionStore keeps the sessions by server URL in a file only the user can read,
+// so scripts log in once and reuse the token until it expires.
+type sessionStore struct {
+	path string
+}
+
+// newSessionStore uses merchctl/sessions.json in the user config directory.
+func newSessionStore() (*sessionStore, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, fmt.Errorf("find config directory: %w", err)
+	}
+
+	return &sessionStore{path: filepath.Join(dir, "merchctl", "sessions.json")}, nil
+}
+
+func (s *sessionStore) load() (map[string]session, error) {
+	sessions := map[string]session{}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return sessions, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, fmt.Errorf("read %s: %w", s.path, err)
+	}
+
+	return sessions, nil
+}
+
+// get returns the session for server, or false if there is none.
+func (s *sessionStore) get(server string) (session, bool, error) {
+	sessions, err := s.load()
+	if err != nil {
+		return session{}, false, err
+	}
+
+	sess, ok := sessions[server]
+	return sess, ok, nil
+}
+
+func (s *sessionStore) put(server string, sess session) error {
+	return s.update(func(sessions map[string]session) {
+		sessions[server] = sess
+	})
+}
+
+func (s *sessionStore) remove(server string) error {
+	return s.update(func(sessions map[string]session) {
+		delete(sessions, server)
+	})
+}
+
+func (s *sessionStore) update(fn func(map[string]session)) error {
+	sessions, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	fn(sessions)
+
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+
+	// The tokens are written next to the file and renamed over it, so a failed
+	// write doesn't lose the other sessions.
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
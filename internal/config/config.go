@@ -2,57 +2,435 @@ package config
 
 import (
 	"fmt"
+	"merch-shop/internal/models"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
+const minSecretKeyLen = 32
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+const (
+	ModeProduction  = "production"
+	ModeDevelopment = "development"
+	ModeTest        = "test"
+)
+
+const (
+	DBDriverPostgres = "postgres"
+	DBDriverMemory   = "memory"
+	DBDriverSQLite   = "sqlite"
+)
+
+const (
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterOTLP   = "otlp"
+)
+
+var insecureSecretKeys = []string{"change_me", "secret", "password"}
+
 type (
 	Config struct {
-		Server `yaml:"server"`
-		DB     `yaml:"db"`
-		JWT    `yaml:"jwt"`
+		Server   `yaml:"server"`
+		DB       `yaml:"db"`
+		JWT      `yaml:"jwt"`
+		Log      `yaml:"log"`
+		Metrics  `yaml:"metrics"`
+		Tracing  `yaml:"tracing"`
+		Health   `yaml:"health"`
+		API      `yaml:"api"`
+		Admin    `yaml:"admin"`
+		Webhooks `yaml:"webhooks"`
+		Events   `yaml:"events"`
 	}
 
 	Server struct {
-		Port         string        `yaml:"port" env:"SERVER_PORT"`
-		ReadTimeout  time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT"`
-		WriteTimeout time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+		Port string `yaml:"port" env:"SERVER_PORT" env-default:"8080"`
+		// GRPCPort serves the gRPC API next to the HTTP one. It has no default, the gRPC API
+		// is off unless a port is set.
+		GRPCPort string `yaml:"grpc_port" env:"SERVER_GRPC_PORT"`
+		// Mode is production, development or test. Outside production requests and responses
+		// are checked against the OpenAPI spec.
+		Mode         string        `yaml:"mode" env:"SERVER_MODE" env-default:"production"`
+		ReadTimeout  time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT" env-default:"10s"`
+		WriteTimeout time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT" env-default:"30s"`
+		// ShutdownDelay is how long readiness fails before the server stops accepting requests,
+		// giving load balancers time to take the instance out of rotation.
+		ShutdownDelay time.Duration `yaml:"shutdown_delay" env:"SERVER_SHUTDOWN_DELAY" env-default:"3s"`
+		// ShutdownTimeout bounds draining in-flight requests and stopping background jobs.
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" env-default:"15s"`
 	}
 
 	DB struct {
-		Host     string `yaml:"host" env:"DB_HOST"`
-		Port     string `yaml:"port" env:"DB_PORT"`
-		User     string `yaml:"user" env:"DB_USER"`
-		Password string `yaml:"password" env:"DB_PASSWORD"`
-		Name     string `yaml:"name" env:"DB_NAME"`
-		SSLMode  string `yaml:"ssl_mode" env:"DB_SSL_MODE"`
+		// Driver selects the storage backend: postgres, sqlite, or memory to run without a database.
+		Driver string `yaml:"driver" env:"DB_DRIVER" env-default:"postgres"`
+		// SQLitePath is the database file used by the sqlite driver.
+		SQLitePath string `yaml:"sqlite_path" env:"DB_SQLITE_PATH" env-default:"merch-shop.db"`
+		// URL is a full postgres:// connection URL, it takes precedence over the separate connection
+		// fields. Only the SSL certificate files are still added to it.
+		URL         string `yaml:"url" env:"DB_URL"`
+		Host        string `yaml:"host" env:"DB_HOST" env-default:"localhost"`
+		Port        string `yaml:"port" env:"DB_PORT" env-default:"5432"`
+		User        string `yaml:"user" env:"DB_USER"`
+		Password    string `yaml:"password" env:"DB_PASSWORD"`
+		Name        string `yaml:"name" env:"DB_NAME"`
+		SSLMode     string `yaml:"ssl_mode" env:"DB_SSL_MODE" env-default:"disable"`
+		SSLRootCert string `yaml:"ssl_root_cert" env:"DB_SSL_ROOT_CERT"`
+		SSLCert     string `yaml:"ssl_cert" env:"DB_SSL_CERT"`
+		SSLKey      string `yaml:"ssl_key" env:"DB_SSL_KEY"`
+		// AutoMigrate applies pending migrations on start, guarded by an advisory lock.
+		AutoMigrate bool `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE"`
+
+		MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" env-default:"25"`
+		MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" env-default:"25"`
+		ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" env-default:"30m"`
+		ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" env-default:"5m"`
+
+		// ConnectTimeout bounds the total time spent retrying the initial connection.
+		ConnectTimeout time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT" env-default:"30s"`
 	}
 
 	JWT struct {
 		SecretKey   string        `yaml:"secret_key" env:"JWT_SECRET_KEY"`
-		TokenExpiry time.Duration `yaml:"token_expiry" env:"JWT_TOKEN_EXPIRY"`
+		TokenExpiry time.Duration `yaml:"token_expiry" env:"JWT_TOKEN_EXPIRY" env-default:"24h"`
+	}
+
+	Log struct {
+		Level  string `yaml:"level" env:"LOG_LEVEL" env-default:"info"`
+		Format string `yaml:"format" env:"LOG_FORMAT" env-default:"text"`
+	}
+
+	Metrics struct {
+		Enabled bool `yaml:"enabled" env:"METRICS_ENABLED" env-default:"true"`
+		// Port of the admin server exposing /metrics, empty to serve it on the main server port.
+		Port string `yaml:"port" env:"METRICS_PORT" env-default:"9090"`
+	}
+
+	Tracing struct {
+		Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`
+		Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT"`
+		ServiceName string  `yaml:"service_name" env:"TRACING_SERVICE_NAME" env-default:"merch-shop"`
+		SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+	}
+
+	Health struct {
+		Timeout time.Duration `yaml:"timeout" env:"HEALTH_TIMEOUT" env-default:"2s"`
+	}
+
+	API struct {
+		// Sunset is the date (YYYY-MM-DD) after which the deprecated unversioned /api routes
+		// may be removed. It is sent in their Sunset header, empty to omit it.
+		Sunset string `yaml:"sunset" env:"API_SUNSET"`
+	}
+
+	Admin struct {
+		// Token is the bearer token of the /api/admin routes, empty to disable them.
+		Token string `yaml:"token" env:"ADMIN_TOKEN"`
+	}
+
+	Webhooks struct {
+		// Endpoints receive the events they subscribe to. They can only be set in the config file.
+		Endpoints []WebhookEndpoint `yaml:"endpoints"`
+		// MaxAttempts is how many times a delivery is tried before it is marked dead.
+		MaxAttempts  int           `yaml:"max_attempts" env:"WEBHOOKS_MAX_ATTEMPTS" env-default:"10"`
+		PollInterval time.Duration `yaml:"poll_interval" env:"WEBHOOKS_POLL_INTERVAL" env-default:"1s"`
+		Timeout      time.Duration `yaml:"timeout" env:"WEBHOOKS_TIMEOUT" env-default:"10s"`
+		// Retention is how long delivered deliveries are kept, dead ones are kept until replayed.
+		Retention time.Duration `yaml:"retention" env:"WEBHOOKS_RETENTION" env-default:"168h"`
+	}
+
+	Events struct {
+		// Heartbeat is how often an idle event stream gets a comment, so proxies keep it open.
+		Heartbeat time.Duration `yaml:"heartbeat" env:"EVENTS_HEARTBEAT" env-default:"15s"`
+		// PollInterval is how often new events are looked up with SQLite and memory storage,
+		// Postgres pushes them with NOTIFY.
+		PollInterval time.Duration `yaml:"poll_interval" env:"EVENTS_POLL_INTERVAL" env-default:"500ms"`
+		// Retention is how long events are kept for streams resumed with Last-Event-ID.
+		Retention time.Duration `yaml:"retention" env:"EVENTS_RETENTION" env-default:"24h"`
+	}
+
+	WebhookEndpoint struct {
+		// Name identifies the endpoint in deliveries, renaming it orphans its pending deliveries.
+		Name   string `yaml:"name"`
+		URL    string `yaml:"url"`
+		Secret string `yaml:"secret"`
+		// Events lists the event types sent to the endpoint, empty for all of them.
+		Events []string `yaml:"events"`
 	}
 )
 
+// New reads the config file at path and applies environment overrides on top of it.
+// An empty path skips the file and builds the config from environment variables only.
 func New(path string) (*Config, error) {
 	cfg := &Config{}
 
-	err := cleanenv.ReadConfig("config.yml", cfg)
-	if err != nil {
-		return nil, err
+	if path == "" {
+		if err := cleanenv.ReadEnv(cfg); err != nil {
+			return nil, fmt.Errorf("config from env: %w", err)
+		}
+		return cfg, nil
+	}
+
+	if err := cleanenv.ReadConfig(path, cfg); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
 	}
 
 	return cfg, nil
 }
 
-func (c *Config) GetDSN() string {
-	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		c.DB.Host,
-		c.DB.Port,
-		c.DB.User,
-		c.DB.Password,
-		c.DB.Name,
-		c.DB.SSLMode,
-	)
+func (c *Config) Validate() error {
+	if c.Server.Port == "" {
+		return ErrEmptyServerPort
+	}
+
+	if !validPort(c.Server.Port) {
+		return ErrInvalidServerPort
+	}
+
+	if c.Server.GRPCPort != "" {
+		if !validPort(c.Server.GRPCPort) {
+			return ErrInvalidGRPCPort
+		}
+		if c.Server.GRPCPort == c.Server.Port || c.Server.GRPCPort == c.Metrics.Port {
+			return ErrGRPCPortInUse
+		}
+	}
+
+	switch c.Server.Mode {
+	case "", ModeProduction, ModeDevelopment, ModeTest:
+	default:
+		return ErrInvalidServerMode
+	}
+
+	if c.Server.ShutdownDelay < 0 || c.Server.ShutdownTimeout <= 0 {
+		return ErrInvalidShutdownTimeout
+	}
+
+	if c.Metrics.Port != "" {
+		if !validPort(c.Metrics.Port) {
+			return ErrInvalidMetricsPort
+		}
+		if c.Metrics.Port == c.Server.Port {
+			return ErrMetricsPortInUse
+		}
+	}
+
+	if err := c.ValidateDB(); err != nil {
+		return err
+	}
+
+	if c.JWT.SecretKey == "" {
+		return ErrEmptyJWTSecret
+	}
+
+	for _, key := range insecureSecretKeys {
+		if c.JWT.SecretKey == key {
+			return ErrInsecureJWTSecret
+		}
+	}
+
+	if len(c.JWT.SecretKey) < minSecretKeyLen {
+		return ErrShortJWTSecret
+	}
+
+	if c.JWT.TokenExpiry <= 0 {
+		return ErrInvalidJWTExpiry
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "", "debug", "info", "warn", "error":
+	default:
+		return ErrInvalidLogLevel
+	}
+
+	switch strings.ToLower(c.Log.Format) {
+	case "", LogFormatText, LogFormatJSON:
+	default:
+		return ErrInvalidLogFormat
+	}
+
+	switch c.Tracing.Exporter {
+	case "", TracingExporterNone, TracingExporterStdout, TracingExporterOTLP:
+	default:
+		return ErrInvalidTracingExporter
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return ErrInvalidSampleRatio
+	}
+
+	if c.API.Sunset != "" {
+		if _, err := time.Parse(time.DateOnly, c.API.Sunset); err != nil {
+			return ErrInvalidAPISunset
+		}
+	}
+
+	if c.Admin.Token != "" && len(c.Admin.Token) < minSecretKeyLen {
+		return ErrShortAdminToken
+	}
+
+	if c.Events.Heartbeat <= 0 || c.Events.PollInterval <= 0 || c.Events.Retention <= 0 {
+		return ErrInvalidEvents
+	}
+
+	return c.validateWebhooks()
+}
+
+func (c *Config) validateWebhooks() error {
+	if len(c.Webhooks.Endpoints) == 0 {
+		return nil
+	}
+
+	if c.Webhooks.MaxAttempts < 1 || c.Webhooks.PollInterval <= 0 || c.Webhooks.Timeout <= 0 || c.Webhooks.Retention <= 0 {
+		return ErrInvalidWebhooks
+	}
+
+	names := map[string]bool{}
+
+	for _, e := range c.Webhooks.Endpoints {
+		if e.Name == "" || len(e.Name) > 100 || names[e.Name] {
+			return fmt.Errorf("%w: endpoint names must be unique and 1 to 100 characters long", ErrInvalidWebhookEndpoint)
+		}
+		names[e.Name] = true
+
+		u, err := url.Parse(e.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: %s: url must be an http or https URL", ErrInvalidWebhookEndpoint, e.Name)
+		}
+
+		if len(e.Secret) < minSecretKeyLen {
+			return fmt.Errorf("%w: %s: secret should be at least %d characters", ErrInvalidWebhookEndpoint, e.Name, minSecretKeyLen)
+		}
+
+		for _, eventType := range e.Events {
+			if !slices.Contains(models.EventTypes, eventType) {
+				return fmt.Errorf("%w: %s: unknown event %q", ErrInvalidWebhookEndpoint, e.Name, eventType)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateDB checks only the DB section, which is all the migrate subcommand uses, so the
+// migrations can run with a config the server would refuse, e.g. one with a placeholder secret.
+func (c *Config) ValidateDB() error {
+	switch c.DB.Driver {
+	case "", DBDriverPostgres:
+		if c.DB.URL != "" {
+			if _, err := c.DSN(); err != nil {
+				return err
+			}
+		} else {
+			switch {
+			case c.DB.Host == "":
+				return ErrEmptyDBHost
+			case c.DB.User == "":
+				return ErrEmptyDBUser
+			case c.DB.Name == "":
+				return ErrEmptyDBName
+			}
+		}
+	case DBDriverMemory:
+		return nil
+	case DBDriverSQLite:
+		if c.DB.SQLitePath == "" {
+			return ErrEmptySQLitePath
+		}
+	default:
+		return ErrInvalidDBDriver
+	}
+
+	if c.DB.MaxOpenConns < 0 || c.DB.MaxIdleConns < 0 || c.DB.ConnMaxLifetime < 0 || c.DB.ConnMaxIdleTime < 0 {
+		return ErrInvalidDBPool
+	}
+
+	if c.DB.ConnectTimeout < 0 {
+		return ErrInvalidDBConnectTimeout
+	}
+
+	return nil
+}
+
+func validPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port >= 1 && port <= 65535
+}
+
+// DSN returns the postgres connection URL, either the configured DB.URL or one built from the separate fields.
+func (c *Config) DSN() (string, error) {
+	if c.DB.URL != "" {
+		u, err := url.Parse(c.DB.URL)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidDBURL, err)
+		}
+
+		if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+			return "", fmt.Errorf("%w: unsupported scheme %q", ErrInvalidDBURL, u.Scheme)
+		}
+
+		if u.Host == "" {
+			return "", fmt.Errorf("%w: missing host", ErrInvalidDBURL)
+		}
+
+		// The certificate files are usually mounted separately from the secret holding the URL,
+		// so they are added to it. sslmode is left to the URL, since ssl_mode always has a value.
+		if files := c.sslFiles(); len(files) > 0 {
+			query := u.Query()
+			for key, value := range files {
+				if current := query.Get(key); current != "" && current != value {
+					return "", fmt.Errorf("%w: %s %q conflicts with %q from the config", ErrInvalidDBURL, key, current, value)
+				}
+				query.Set(key, value)
+			}
+			u.RawQuery = query.Encode()
+		}
+
+		return u.String(), nil
+	}
+
+	query := url.Values{}
+	if c.DB.SSLMode != "" {
+		query.Set("sslmode", c.DB.SSLMode)
+	}
+	for key, value := range c.sslFiles() {
+		query.Set(key, value)
+	}
+
+	u := &url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.DB.User, c.DB.Password),
+		Host:     net.JoinHostPort(c.DB.Host, c.DB.Port),
+		Path:     "/" + c.DB.Name,
+		RawQuery: query.Encode(),
+	}
+
+	return u.String(), nil
+}
+
+// sslFiles returns the configured certificate files keyed by their connection parameter.
+func (c *Config) sslFiles() map[string]string {
+	files := map[string]string{}
+	if c.DB.SSLRootCert != "" {
+		files["sslrootcert"] = c.DB.SSLRootCert
+	}
+	if c.DB.SSLCert != "" {
+		files["sslcert"] = c.DB.SSLCert
+	}
+	if c.DB.SSLKey != "" {
+		files["sslkey"] = c.DB.SSLKey
+	}
+
+	return files
 }
@@ -0,0 +1,35 @@
+package config
+
+import "errors"
+
+var (
+	ErrEmptyServerPort         = errors.New("server port is not set")
+	ErrInvalidServerPort       = errors.New("server port should be a number between 1 and 65535")
+	ErrInvalidGRPCPort         = errors.New("grpc port should be a number between 1 and 65535")
+	ErrGRPCPortInUse           = errors.New("grpc port should differ from server and metrics ports, leave it empty to disable grpc")
+	ErrInvalidServerMode       = errors.New("server mode should be one of production, development, test")
+	ErrInvalidShutdownTimeout  = errors.New("server shutdown delay must not be negative and timeout must be positive")
+	ErrInvalidMetricsPort      = errors.New("metrics port should be a number between 1 and 65535")
+	ErrMetricsPortInUse        = errors.New("metrics port should differ from server port, leave it empty to serve metrics on server port")
+	ErrInvalidDBDriver         = errors.New("db driver should be postgres, sqlite or memory")
+	ErrEmptySQLitePath         = errors.New("sqlite path is not set")
+	ErrEmptyDBHost             = errors.New("db host is not set")
+	ErrEmptyDBUser             = errors.New("db user is not set")
+	ErrEmptyDBName             = errors.New("db name is not set")
+	ErrInvalidDBURL            = errors.New("invalid db url")
+	ErrInvalidDBPool           = errors.New("db pool settings should not be negative")
+	ErrInvalidDBConnectTimeout = errors.New("db connect timeout should not be negative")
+	ErrEmptyJWTSecret          = errors.New("jwt secret key is not set")
+	ErrInsecureJWTSecret       = errors.New("jwt secret key is insecure, set JWT_SECRET_KEY")
+	ErrShortJWTSecret          = errors.New("jwt secret key should be at least 32 characters")
+	ErrInvalidJWTExpiry        = errors.New("jwt token expiry should be positive")
+	ErrInvalidLogLevel         = errors.New("log level should be one of debug, info, warn, error")
+	ErrInvalidLogFormat        = errors.New("log format should be text or json")
+	ErrInvalidTracingExporter  = errors.New("tracing exporter should be one of none, stdout, otlp")
+	ErrInvalidSampleRatio      = errors.New("tracing sample ratio should be between 0 and 1")
+	ErrInvalidAPISunset        = errors.New("api sunset must be a date in YYYY-MM-DD format")
+	ErrShortAdminToken         = errors.New("admin token should be at least 32 characters")
+	ErrInvalidWebhooks         = errors.New("webhook max attempts, poll interval, timeout and retention should be positive")
+	ErrInvalidWebhookEndpoint  = errors.New("invalid webhook endpoint")
+	ErrInvalidEvents           = errors.New("events heartbeat, poll interval and retention should be positive")
+)
@@ -3,25 +3,131 @@ package dbinit
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
 	"merch-shop/internal/config"
+	"net/url"
 	"time"
 
 	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 )
 
-func OpenDB(cfg *config.Config) (*sql.DB, error) {
-	db, err := sql.Open("postgres", cfg.GetDSN())
+const (
+	pingTimeout    = 3 * time.Second
+	initialBackoff = 200 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+// OpenDB opens the connection pool and pings the database, retrying with exponential
+// backoff until it answers or cfg.DB.ConnectTimeout runs out.
+func OpenDB(ctx context.Context, cfg *config.Config, logger *slog.Logger) (*sql.DB, error) {
+	dsn, err := cfg.DSN()
 	if err != nil {
 		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(cfg.DB.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.DB.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DB.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DB.ConnMaxIdleTime)
+
+	err = pingWithRetry(ctx, db, cfg.DB.ConnectTimeout, logger)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// OpenSQLite opens the SQLite database file, creating it if needed. Transactions take
+// the write lock when they begin, unless they are read-only, and wait up to busy_timeout
+// for concurrent writers.
+func OpenSQLite(ctx context.Context, cfg *config.Config) (*sql.DB, error) {
+	query := url.Values{}
+	query.Set("_txlock", "immediate")
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "busy_timeout(5000)")
+
+	db, err := sql.Open("sqlite", "file:"+cfg.DB.SQLitePath+"?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
 
-	err = db.PingContext(ctx)
+	db.SetMaxOpenConns(cfg.DB.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.DB.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DB.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DB.ConnMaxIdleTime)
+
+	err = ping(ctx, db)
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
+
+func pingWithRetry(ctx context.Context, db *sql.DB, timeout time.Duration, logger *slog.Logger) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := ping(ctx, db)
+		if err == nil {
+			return nil
+		}
+
+		delay := backoff(attempt)
+		if timeout <= 0 || !retryAllowed(ctx, delay) {
+			return fmt.Errorf("db is unreachable after %d attempts: %w", attempt, err)
+		}
+
+		logger.Warn("db is unreachable, retrying",
+			slog.Int("attempt", attempt),
+			slog.Duration("delay", delay),
+			slog.String("error", err.Error()),
+		)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("db is unreachable after %d attempts: %w", attempt, err)
+		case <-time.After(delay):
+		}
+	}
+}
+
+func ping(ctx context.Context, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	return db.PingContext(ctx)
+}
+
+func retryAllowed(ctx context.Context, delay time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > delay
+}
+
+// backoff returns the delay before the next attempt: it doubles with every attempt
+// up to maxBackoff, with up to 20% jitter so replicas don't retry in lockstep.
+func backoff(attempt int) time.Duration {
+	delay := initialBackoff << min(attempt-1, 10)
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+
+	jitter := time.Duration(rand.Int64N(int64(delay) / 5))
+	return delay - jitter
+}
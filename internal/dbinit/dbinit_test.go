@@ -0,0 +1,27 @@
+package dbinit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_backoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 1, max: initialBackoff},
+		{attempt: 2, max: 2 * initialBackoff},
+		{attempt: 3, max: 4 * initialBackoff},
+		{attempt: 10, max: maxBackoff},
+		{attempt: 100, max: maxBackoff},
+	}
+
+	for _, tt := range tests {
+		got := backoff(tt.attempt)
+		assert.LessOrEqual(t, got, tt.max, "attempt %d", tt.attempt)
+		assert.Greater(t, got, tt.max*4/5-time.Nanosecond, "attempt %d", tt.attempt)
+	}
+}
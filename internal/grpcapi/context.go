@@ -0,0 +1,38 @@
+package grpcapi
+
+import "context"
+
+type contextKey string
+
+const (
+	callLogKey = contextKey("callLog")
+	userIDKey  = contextKey("userID")
+)
+
+// callLog is shared between the log interceptor and the ones after it, so values
+// discovered later (e.g. the authenticated user) end up in the call log line.
+type callLog struct {
+	userID int
+}
+
+func contextWithCallLog(ctx context.Context, cl *callLog) context.Context {
+	return context.WithValue(ctx, callLogKey, cl)
+}
+
+func callLogFromContext(ctx context.Context) *callLog {
+	cl, ok := ctx.Value(callLogKey).(*callLog)
+	if !ok {
+		return &callLog{}
+	}
+	return cl
+}
+
+func contextWithUserID(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// userIDFromContext returns the ID of the user authenticated by interceptAuth.
+// It panics if called from a public method, as that is a bug.
+func userIDFromContext(ctx context.Context) int {
+	return ctx.Value(userIDKey).(int)
+}
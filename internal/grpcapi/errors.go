@@ -0,0 +1,108 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"merch-shop/internal/models"
+	"merch-shop/internal/service"
+	"sort"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// errorDomain is the ErrorInfo domain of the errors sent to clients. The reason next to it
+// is the same code the HTTP API puts in models.ErrorResponse.
+const errorDomain = "merch-shop"
+
+// codeStatuses is the gRPC status of every code service.ErrorCode returns.
+var codeStatuses = map[string]codes.Code{
+	models.CodeInvalidRequest:    codes.InvalidArgument,
+	models.CodeValidationFailed:  codes.InvalidArgument,
+	models.CodePasswordTooLong:   codes.InvalidArgument,
+	models.CodeWrongPassword:     codes.Unauthenticated,
+	models.CodeMissingToken:      codes.Unauthenticated,
+	models.CodeInvalidAuthHeader: codes.Unauthenticated,
+	models.CodeInvalidToken:      codes.Unauthenticated,
+	models.CodeTokenExpired:      codes.Unauthenticated,
+	models.CodeUserDeactivated:   codes.PermissionDenied,
+	models.CodeSelfTransfer:      codes.InvalidArgument,
+	models.CodeInsufficientFunds: codes.FailedPrecondition,
+	models.CodeBalanceLimit:      codes.FailedPrecondition,
+	models.CodeItemNotFound:      codes.NotFound,
+	models.CodeReceiverNotFound:  codes.NotFound,
+	models.CodeUserNotFound:      codes.NotFound,
+	models.CodeNotFound:          codes.NotFound,
+	models.CodeAlreadyExists:     codes.AlreadyExists,
+}
+
+// protoFields names the fields of models.ValidationError as in the proto messages,
+// where they differ from the JSON requests.
+var protoFields = map[string]string{
+	"toUser": "to_user",
+}
+
+// toStatus converts err returned by a method to the status sent to the client. The second
+// result is false if err is unexpected and should be logged; its message is not sent.
+func toStatus(err error) (*status.Status, bool) {
+	if _, ok := status.FromError(err); ok {
+		return status.Convert(err), true
+	}
+
+	if code, ok := service.ErrorCode(err); ok {
+		var ve *models.ValidationError
+		if errors.As(err, &ve) {
+			return validationStatus(ve), true
+		}
+
+		st := status.New(codeStatuses[code], err.Error())
+		return withDetails(st, &errdetails.ErrorInfo{Reason: code, Domain: errorDomain}), true
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err), true
+	}
+
+	st := status.New(codes.Internal, "internal server error")
+	return withDetails(st, &errdetails.ErrorInfo{Reason: models.CodeInternalError, Domain: errorDomain}), false
+}
+
+// validationStatus lists the invalid fields of a request in a BadRequest detail.
+func validationStatus(ve *models.ValidationError) *status.Status {
+	fields := make([]string, 0, len(ve.Fields))
+	for field := range ve.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	br := &errdetails.BadRequest{}
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		name := field
+		if protoName, ok := protoFields[field]; ok {
+			name = protoName
+		}
+
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       name,
+			Description: ve.Fields[field],
+		})
+		messages = append(messages, fmt.Sprintf("%s: %s", name, ve.Fields[field]))
+	}
+
+	st := status.New(codes.InvalidArgument, strings.Join(messages, "; "))
+	return withDetails(st, &errdetails.ErrorInfo{Reason: models.CodeValidationFailed, Domain: errorDomain}, br)
+}
+
+// withDetails attaches details to st. They are well-formed messages, so it doesn't fail.
+func withDetails(st *status.Status, details ...protoadapt.MessageV1) *status.Status {
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st
+	}
+	return withDetails
+}
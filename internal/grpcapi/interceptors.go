@@ -0,0 +1,105 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	merchv1 "merch-shop/api/proto/merch/v1"
+	"merch-shop/internal/requestid"
+	"merch-shop/internal/utils"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// publicMethods can be called without a token.
+var publicMethods = map[string]bool{
+	merchv1.MerchShop_Auth_FullMethodName: true,
+}
+
+// requestIDKey is the metadata key of the request ID, metadata keys are lowercase.
+var requestIDKey = strings.ToLower(requestid.Header)
+
+// interceptLog takes the request ID from the x-request-id metadata, or generates one if it
+// is missing or malformed, and echoes it in the response header. It converts the errors of
+// the methods to statuses and logs every call, the same as MiddlewareAccessLog over HTTP.
+func (s *server) interceptLog(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	start := time.Now()
+
+	id := firstMetadata(ctx, requestIDKey)
+	if !requestid.Valid(id) {
+		id = requestid.New()
+	}
+	ctx = requestid.NewContext(ctx, id)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+
+	cl := &callLog{}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v\n%s", p, debug.Stack())
+		}
+
+		code := codes.OK
+		if err != nil {
+			st, known := toStatus(err)
+			if !known {
+				s.logger.ErrorContext(ctx, "internal error", slog.String("method", info.FullMethod), slog.String("error", err.Error()))
+			}
+			code, err = st.Code(), st.Err()
+		}
+
+		level := slog.LevelInfo
+		if code == codes.Internal || code == codes.Unknown {
+			level = slog.LevelError
+		}
+
+		s.logger.LogAttrs(ctx, level, "call",
+			slog.String("method", info.FullMethod),
+			slog.String("code", code.String()),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("user_id", cl.userID),
+		)
+	}()
+
+	return handler(contextWithCallLog(ctx, cl), req)
+}
+
+// interceptAuth validates the token in the authorization metadata, sent as "Bearer <token>"
+// like the Authorization header, and stores the user it was issued to in the context.
+func (s *server) interceptAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if publicMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	authorization := firstMetadata(ctx, "authorization")
+	if authorization == "" {
+		return nil, utils.ErrNoAuthorizationHeader
+	}
+
+	parts := strings.Split(authorization, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil, utils.ErrInvalidAuthorizationHeader
+	}
+
+	userID, err := utils.ValidateToken(parts[1], s.cfg.JWT.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	callLogFromContext(ctx).userID = userID
+
+	return handler(contextWithUserID(ctx, userID), req)
+}
+
+func firstMetadata(ctx context.Context, key string) string {
+	values := metadata.ValueFromIncomingContext(ctx, key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,156 @@
+// Package grpcapi serves the gRPC API described in api/proto on top of service.Service.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	merchv1 "merch-shop/api/proto/merch/v1"
+	"merch-shop/internal/config"
+	"merch-shop/internal/metrics"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repository"
+	"merch-shop/internal/service"
+	"merch-shop/internal/utils"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type server struct {
+	merchv1.UnimplementedMerchShopServer
+
+	service *service.Service
+	cfg     *config.Config
+	logger  *slog.Logger
+	metrics *metrics.Metrics
+}
+
+// NewServer returns a gRPC server with the MerchShop service registered. Every call is logged,
+// and every call but Auth has to carry a token issued by Auth, the same as over HTTP.
+func NewServer(service *service.Service, cfg *config.Config, logger *slog.Logger, metrics *metrics.Metrics) *grpc.Server {
+	s := &server{
+		service: service,
+		cfg:     cfg,
+		logger:  logger,
+		metrics: metrics,
+	}
+
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(s.interceptLog, s.interceptAuth))
+	merchv1.RegisterMerchShopServer(srv, s)
+
+	return srv
+}
+
+func (s *server) Auth(ctx context.Context, req *merchv1.AuthRequest) (*merchv1.AuthResponse, error) {
+	authRequest := &models.AuthRequest{Username: req.GetUsername(), Password: req.GetPassword()}
+	if err := authRequest.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Registration must not be left half done if the client goes away, so only
+	// the call values are kept, not its cancellation.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 3*time.Second)
+	defer cancel()
+
+	token, err := s.service.Login(ctx, req.GetUsername(), req.GetPassword())
+	if err != nil {
+		if errors.Is(err, utils.ErrMismatchHashPassword) {
+			s.metrics.FailedLogin()
+		}
+		return nil, err
+	}
+
+	return &merchv1.AuthResponse{Token: token}, nil
+}
+
+func (s *server) Info(ctx context.Context, _ *merchv1.InfoRequest) (*merchv1.InfoResponse, error) {
+	info, err := s.service.Info(ctx, userIDFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &merchv1.InfoResponse{
+		Coins:       int64(info.Coins),
+		CoinHistory: &merchv1.CoinHistory{},
+	}
+
+	for _, item := range info.Inventory {
+		resp.Inventory = append(resp.Inventory, &merchv1.InventoryItem{
+			Type:     item.Type,
+			Quantity: int64(item.Quantity),
+		})
+	}
+
+	if info.CoinHistory != nil {
+		resp.CoinHistory.Received = coinTransactions(info.CoinHistory.Received)
+		resp.CoinHistory.Sent = coinTransactions(info.CoinHistory.Sent)
+	}
+
+	return resp, nil
+}
+
+func (s *server) SendCoin(ctx context.Context, req *merchv1.SendCoinRequest) (*merchv1.Transfer, error) {
+	sendCoinRequest := &models.SendCoinRequest{ReceiverName: req.GetToUser(), Amount: int(req.GetAmount())}
+	if err := sendCoinRequest.Validate(); err != nil {
+		return nil, err
+	}
+
+	transfer, err := s.service.SendCoin(ctx, userIDFromContext(ctx), req.GetToUser(), int(req.GetAmount()))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotEnoughCoins) {
+			s.metrics.NotEnoughCoins("send_coin")
+		}
+		return nil, err
+	}
+
+	s.metrics.CoinsTransferred(transfer.Amount)
+
+	return &merchv1.Transfer{
+		Id:        int64(transfer.ID),
+		FromUser:  transfer.FromUser,
+		ToUser:    transfer.ToUser,
+		Amount:    int64(transfer.Amount),
+		CreatedAt: timestamppb.New(transfer.CreatedAt),
+		Balance:   int64(transfer.Balance),
+	}, nil
+}
+
+func (s *server) BuyItem(ctx context.Context, req *merchv1.BuyItemRequest) (*merchv1.Purchase, error) {
+	purchaseRequest := &models.PurchaseRequest{Item: req.GetItem()}
+	if err := purchaseRequest.Validate(); err != nil {
+		return nil, err
+	}
+
+	purchase, err := s.service.BuyItem(ctx, userIDFromContext(ctx), req.GetItem())
+	if err != nil {
+		if errors.Is(err, repository.ErrNotEnoughCoins) {
+			s.metrics.NotEnoughCoins("buy_item")
+		}
+		return nil, err
+	}
+
+	s.metrics.ItemPurchased(purchase.Item)
+
+	return &merchv1.Purchase{
+		Id:        int64(purchase.ID),
+		Item:      purchase.Item,
+		Price:     int64(purchase.Price),
+		Quantity:  int64(purchase.Quantity),
+		CreatedAt: timestamppb.New(purchase.CreatedAt),
+		Balance:   int64(purchase.Balance),
+	}, nil
+}
+
+func coinTransactions(transactions []*models.CoinTransaction) []*merchv1.CoinTransaction {
+	converted := make([]*merchv1.CoinTransaction, 0, len(transactions))
+	for _, ct := range transactions {
+		converted = append(converted, &merchv1.CoinTransaction{
+			FromUser: ct.FromUser,
+			ToUser:   ct.ToUser,
+			Amount:   int64(ct.Amount),
+		})
+	}
+	return converted
+}
@@ -0,0 +1,238 @@
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	merchv1 "merch-shop/api/proto/merch/v1"
+	"merch-shop/internal/config"
+	"merch-shop/internal/metrics"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repository"
+	"merch-shop/internal/service"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T) merchv1.MerchShopClient {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.JWT.SecretKey = "0123456789abcdef0123456789abcdef"
+	cfg.JWT.TokenExpiry = time.Hour
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := service.NewService(repository.NewMemoryRepository(), cfg)
+	srv := NewServer(service, cfg, logger, metrics.New())
+
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return merchv1.NewMerchShopClient(conn)
+}
+
+func authenticate(t *testing.T, client merchv1.MerchShopClient, username string) context.Context {
+	t.Helper()
+
+	resp, err := client.Auth(context.Background(), &merchv1.AuthRequest{Username: username, Password: "password"})
+	require.NoError(t, err)
+	require.NotEmpty(t, resp.GetToken())
+
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+resp.GetToken())
+}
+
+// requireStatus checks the code of err and the reason of its ErrorInfo.
+func requireStatus(t *testing.T, err error, code codes.Code, reason string) *status.Status {
+	t.Helper()
+
+	st, ok := status.FromError(err)
+	require.True(t, ok, "not a status error: %v", err)
+	assert.Equal(t, code, st.Code(), st.Message())
+
+	var info *errdetails.ErrorInfo
+	for _, d := range st.Details() {
+		if i, ok := d.(*errdetails.ErrorInfo); ok {
+			info = i
+		}
+	}
+	require.NotNil(t, info, "no ErrorInfo in status")
+	assert.Equal(t, reason, info.GetReason())
+	assert.Equal(t, errorDomain, info.GetDomain())
+
+	return st
+}
+
+func Test_Server(t *testing.T) {
+	client := newTestClient(t)
+
+	alice := authenticate(t, client, "alice")
+	authenticate(t, client, "bob")
+
+	t.Run("info", func(t *testing.T) {
+		var header metadata.MD
+		info, err := client.Info(alice, &merchv1.InfoRequest{}, grpc.Header(&header))
+		require.NoError(t, err)
+
+		assert.EqualValues(t, 1000, info.GetCoins())
+		assert.Empty(t, info.GetInventory())
+		assert.NotEmpty(t, header.Get("x-request-id"))
+	})
+
+	t.Run("send coin", func(t *testing.T) {
+		transfer, err := client.SendCoin(alice, &merchv1.SendCoinRequest{ToUser: "bob", Amount: 100})
+		require.NoError(t, err)
+
+		assert.Equal(t, "alice", transfer.GetFromUser())
+		assert.Equal(t, "bob", transfer.GetToUser())
+		assert.EqualValues(t, 900, transfer.GetBalance())
+		assert.False(t, transfer.GetCreatedAt().AsTime().IsZero())
+	})
+
+	t.Run("buy item", func(t *testing.T) {
+		purchase, err := client.BuyItem(alice, &merchv1.BuyItemRequest{Item: "cup"})
+		require.NoError(t, err)
+
+		assert.Equal(t, "cup", purchase.GetItem())
+		assert.EqualValues(t, 880, purchase.GetBalance())
+
+		info, err := client.Info(alice, &merchv1.InfoRequest{})
+		require.NoError(t, err)
+		require.Len(t, info.GetInventory(), 1)
+		assert.Equal(t, "cup", info.GetInventory()[0].GetType())
+		require.Len(t, info.GetCoinHistory().GetSent(), 1)
+		assert.EqualValues(t, 100, info.GetCoinHistory().GetSent()[0].GetAmount())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			call   func() error
+			code   codes.Code
+			reason string
+		}{
+			{
+				name: "missing token",
+				call: func() error {
+					_, err := client.Info(context.Background(), &merchv1.InfoRequest{})
+					return err
+				},
+				code:   codes.Unauthenticated,
+				reason: models.CodeMissingToken,
+			},
+			{
+				name: "malformed authorization",
+				call: func() error {
+					ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "token")
+					_, err := client.Info(ctx, &merchv1.InfoRequest{})
+					return err
+				},
+				code:   codes.Unauthenticated,
+				reason: models.CodeInvalidAuthHeader,
+			},
+			{
+				name: "invalid token",
+				call: func() error {
+					ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer token")
+					_, err := client.Info(ctx, &merchv1.InfoRequest{})
+					return err
+				},
+				code:   codes.Unauthenticated,
+				reason: models.CodeInvalidToken,
+			},
+			{
+				name: "wrong password",
+				call: func() error {
+					_, err := client.Auth(context.Background(), &merchv1.AuthRequest{Username: "alice", Password: "wrong"})
+					return err
+				},
+				code:   codes.Unauthenticated,
+				reason: models.CodeWrongPassword,
+			},
+			{
+				name: "send to yourself",
+				call: func() error {
+					_, err := client.SendCoin(alice, &merchv1.SendCoinRequest{ToUser: "alice", Amount: 1})
+					return err
+				},
+				code:   codes.InvalidArgument,
+				reason: models.CodeSelfTransfer,
+			},
+			{
+				name: "unknown receiver",
+				call: func() error {
+					_, err := client.SendCoin(alice, &merchv1.SendCoinRequest{ToUser: "carol", Amount: 1})
+					return err
+				},
+				code:   codes.NotFound,
+				reason: models.CodeReceiverNotFound,
+			},
+			{
+				name: "not enough coins",
+				call: func() error {
+					_, err := client.SendCoin(alice, &merchv1.SendCoinRequest{ToUser: "bob", Amount: 100000})
+					return err
+				},
+				code:   codes.FailedPrecondition,
+				reason: models.CodeInsufficientFunds,
+			},
+			{
+				name: "unknown item",
+				call: func() error {
+					_, err := client.BuyItem(alice, &merchv1.BuyItemRequest{Item: "yacht"})
+					return err
+				},
+				code:   codes.NotFound,
+				reason: models.CodeItemNotFound,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				requireStatus(t, tt.call(), tt.code, tt.reason)
+			})
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		_, err := client.SendCoin(alice, &merchv1.SendCoinRequest{Amount: -1})
+		st := requireStatus(t, err, codes.InvalidArgument, models.CodeValidationFailed)
+
+		var violations []string
+		for _, d := range st.Details() {
+			if br, ok := d.(*errdetails.BadRequest); ok {
+				for _, v := range br.GetFieldViolations() {
+					violations = append(violations, v.GetField())
+				}
+			}
+		}
+		assert.Equal(t, []string{"amount", "to_user"}, violations)
+	})
+
+}
+
+func Test_codeStatuses(t *testing.T) {
+	for _, code := range service.ErrorCodes() {
+		assert.Contains(t, codeStatuses, code, "no gRPC status for %s", code)
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"errors"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repository"
+	"net/http"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+)
+
+const (
+	defaultDeliveriesLimit = 20
+	maxDeliveriesLimit     = 100
+	// maxGrant bounds a single grant, so a typo can't flood the shop with coins.
+	maxGrant = 1_000_000
+	// maxItemPrice keeps prices far below what the price and balance columns hold.
+	maxItemPrice   = 1_000_000
+	maxItemNameLen = 50
+)
+
+var deliveryStatuses = []string{models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead}
+
+// ListWebhookDeliveries pages through webhook deliveries in a status, dead by default.
+// Pages are ordered by ID, the next one is requested by passing the returned cursor as after.
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := models.NewValidator()
+
+	status := readString(qs, "status", models.DeliveryDead)
+	after := h.readInt(qs, "after", 0, v)
+	limit := h.readInt(qs, "limit", defaultDeliveriesLimit, v)
+
+	v.Check(slices.Contains(deliveryStatuses, status), "status", "must be one of pending, delivered, dead")
+	v.Check(after >= 0, "after", "must not be negative")
+	v.Check(limit >= 1 && limit <= maxDeliveriesLimit, "limit", models.Between(1, maxDeliveriesLimit))
+
+	if err := v.Err(); err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	list, err := h.service.WebhookDeliveries(r.Context(), status, after, limit)
+	if err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	err = h.writeJSON(w, http.StatusOK, list, nil)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
+
+// ReplayWebhookDelivery sends a dead delivery again with a fresh set of attempts.
+func (h *Handler) ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	v := models.NewValidator()
+
+	deliveryID, err := strconv.Atoi(r.PathValue("id"))
+	v.Check(err == nil && deliveryID > 0, "id", "must be a positive integer")
+
+	if err := v.Err(); err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	delivery, err := h.service.ReplayWebhookDelivery(r.Context(), deliveryID)
+	if err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	err = h.writeJSON(w, http.StatusOK, delivery, nil)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
+
+// GrantCoins adds coins to the balance of a user.
+func (h *Handler) GrantCoins(w http.ResponseWriter, r *http.Request) {
+	grantRequest := &models.GrantCoinsRequest{}
+	err := h.readJSON(w, r, grantRequest)
+	if err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	v := models.NewValidator()
+	v.Check(grantRequest.Amount > 0 && grantRequest.Amount <= maxGrant, "amount", models.Between(1, maxGrant))
+
+	if err := v.Err(); err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	grant, err := h.service.GrantCoins(r.Context(), r.PathValue("username"), grantRequest.Amount)
+	if err != nil {
+		h.adminUserErrorResponse(w, r, err)
+		return
+	}
+
+	err = h.writeJSON(w, http.StatusOK, grant, nil)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
+
+// DeactivateUser hides a user from the shop, their history is kept.
+func (h *Handler) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	err := h.service.DeactivateUser(r.Context(), r.PathValue("username"))
+	if err != nil {
+		h.adminUserErrorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adminUserErrorResponse answers 404 for a missing user, as on the admin routes it is the
+// requested resource itself.
+func (h *Handler) adminUserErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, repository.ErrUserNotFound) {
+		h.errorResponse(w, r, http.StatusNotFound, models.CodeUserNotFound, err.Error())
+		return
+	}
+	h.apiErrorResponse(w, r, err)
+}
+
+// ListItems returns all items on sale with their prices.
+func (h *Handler) ListItems(w http.ResponseWriter, r *http.Request) {
+	list, err := h.service.Items(r.Context())
+	if err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	err = h.writeJSON(w, http.StatusOK, list, nil)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
+
+// SetItemPrice changes the price of an item, putting it on sale if it is new. Purchases
+// already made keep the price they were made at.
+func (h *Handler) SetItemPrice(w http.ResponseWriter, r *http.Request) {
+	priceRequest := &models.SetItemPriceRequest{}
+	err := h.readJSON(w, r, priceRequest)
+	if err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	itemName := r.PathValue("item")
+
+	v := models.NewValidator()
+	v.Check(utf8.RuneCountInString(itemName) <= maxItemNameLen, "item", models.NotLongerThan(maxItemNameLen))
+	v.Check(priceRequest.Price >= 0 && priceRequest.Price <= maxItemPrice, "price", models.Between(0, maxItemPrice))
+
+	if err := v.Err(); err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	item, err := h.service.SetItemPrice(r.Context(), itemName, priceRequest.Price)
+	if err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	err = h.writeJSON(w, http.StatusOK, item, nil)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey string
+
+const (
+	requestLogKey = contextKey("requestLog")
+	userIDKey     = contextKey("userID")
+	legacyAPIKey  = contextKey("legacyAPI")
+)
+
+// requestLog is shared between the access log middleware and the handlers down the chain,
+// so values discovered later (e.g. the authenticated user) end up in the access log line.
+type requestLog struct {
+	userID int
+}
+
+func contextWithRequestLog(ctx context.Context, rl *requestLog) context.Context {
+	return context.WithValue(ctx, requestLogKey, rl)
+}
+
+func requestLogFromContext(ctx context.Context) *requestLog {
+	rl, ok := ctx.Value(requestLogKey).(*requestLog)
+	if !ok {
+		return &requestLog{}
+	}
+	return rl
+}
+
+func contextWithUserID(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// userIDFromContext returns the ID of the user authenticated by MiddlewareAuth.
+// It panics if called on a route without it, as that is a routing bug.
+func userIDFromContext(ctx context.Context) int {
+	return ctx.Value(userIDKey).(int)
+}
+
+func contextWithLegacyAPI(ctx context.Context) context.Context {
+	return context.WithValue(ctx, legacyAPIKey, true)
+}
+
+// isLegacyAPI reports whether the request came through one of the unversioned /api
+// routes, which keep the errors they answered with before /api/v2.
+func isLegacyAPI(ctx context.Context) bool {
+	legacy, _ := ctx.Value(legacyAPIKey).(bool)
+	return legacy
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"merch-shop/api"
+	"net/http"
+
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// swaggerInitializer replaces the initializer shipped with Swagger UI, which points at the petstore demo.
+const swaggerInitializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: "/api/openapi.yaml",
+    dom_id: "#swagger-ui",
+    deepLinking: true,
+    presets: [
+      SwaggerUIBundle.presets.apis,
+      SwaggerUIStandalonePreset
+    ],
+    plugins: [
+      SwaggerUIBundle.plugins.DownloadUrl
+    ],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+func (h *Handler) OpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(api.Spec)
+}
+
+// SwaggerUI serves the embedded Swagger UI under /api/docs/.
+func (h *Handler) SwaggerUI() http.Handler {
+	files := http.StripPrefix("/api/docs/", http.FileServerFS(swaggerFiles.FS))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/docs/swagger-initializer.js" {
+			w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+			w.Write([]byte(swaggerInitializer))
+			return
+		}
+
+		files.ServeHTTP(w, r)
+	})
+}
@@ -2,6 +2,10 @@ package handlers
 
 import (
 	"errors"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repository"
+	"merch-shop/internal/service"
+	"net/http"
 )
 
 var (
@@ -9,4 +13,67 @@ var (
 	ErrEmptyToUser          = errors.New("empty toUser field")
 	ErrZeroOrNegativeAmount = errors.New("amount to send should be positive")
 	ErrEmptyItem            = errors.New("empty item parameter")
+	ErrUnsupportedMediaType = errors.New("Content-Type header must be application/json")
+	ErrBodyTooLarge         = errors.New("body is too large")
+	ErrInvalidBody          = errors.New("invalid body")
 )
+
+type apiError struct {
+	err    error
+	status int
+	code   string
+}
+
+// apiErrors maps the errors of the HTTP layer to the response sent to the client, and
+// overrides the status of a few service errors. It is matched with errors.Is in order,
+// so specific errors must come before the ones they wrap.
+var apiErrors = []apiError{
+	{ErrEmptyNamePassword, http.StatusBadRequest, models.CodeEmptyCredentials},
+	{ErrEmptyToUser, http.StatusBadRequest, models.CodeEmptyReceiver},
+	{ErrZeroOrNegativeAmount, http.StatusBadRequest, models.CodeInvalidAmount},
+	{ErrEmptyItem, http.StatusBadRequest, models.CodeEmptyItem},
+	{ErrUnsupportedMediaType, http.StatusUnsupportedMediaType, models.CodeUnsupportedMediaType},
+	{ErrBodyTooLarge, http.StatusRequestEntityTooLarge, models.CodeBodyTooLarge},
+	{ErrInvalidBody, http.StatusBadRequest, models.CodeInvalidRequest},
+
+	{repository.ErrDeliveryNotFound, http.StatusNotFound, models.CodeNotFound},
+}
+
+// codeStatuses is the HTTP status of every code service.ErrorCode returns. Missing records
+// answer with 400 rather than 404, as the API always has.
+var codeStatuses = map[string]int{
+	models.CodeInvalidRequest:    http.StatusBadRequest,
+	models.CodeValidationFailed:  http.StatusBadRequest,
+	models.CodePasswordTooLong:   http.StatusBadRequest,
+	models.CodeWrongPassword:     http.StatusUnauthorized,
+	models.CodeMissingToken:      http.StatusUnauthorized,
+	models.CodeInvalidAuthHeader: http.StatusUnauthorized,
+	models.CodeInvalidToken:      http.StatusUnauthorized,
+	models.CodeTokenExpired:      http.StatusUnauthorized,
+	models.CodeUserDeactivated:   http.StatusForbidden,
+	models.CodeSelfTransfer:      http.StatusBadRequest,
+	models.CodeInsufficientFunds: http.StatusBadRequest,
+	models.CodeBalanceLimit:      http.StatusBadRequest,
+	models.CodeItemNotFound:      http.StatusBadRequest,
+	models.CodeReceiverNotFound:  http.StatusBadRequest,
+	models.CodeUserNotFound:      http.StatusBadRequest,
+	models.CodeNotFound:          http.StatusBadRequest,
+	models.CodeAlreadyExists:     http.StatusConflict,
+}
+
+// lookupAPIError returns the mapping for err, or false if err is unexpected
+// and should be reported as an internal error.
+func lookupAPIError(err error) (apiError, bool) {
+	for _, e := range apiErrors {
+		if errors.Is(err, e.err) {
+			return e, true
+		}
+	}
+
+	code, ok := service.ErrorCode(err)
+	if !ok {
+		return apiError{}, false
+	}
+
+	return apiError{err: err, status: codeStatuses[code], code: code}, true
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repository"
+	"merch-shop/internal/service"
+	"merch-shop/internal/utils"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_lookupAPIError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantOK     bool
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "item not found",
+			err:        repository.ErrItemNotFound,
+			wantOK:     true,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   models.CodeItemNotFound,
+		},
+		{
+			name:       "wrapped receiver not found",
+			err:        fmt.Errorf("send coin: %w", repository.ErrReceiverNotFound),
+			wantOK:     true,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   models.CodeReceiverNotFound,
+		},
+		{
+			name:       "generic not found",
+			err:        repository.ErrRecordNotFound,
+			wantOK:     true,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   models.CodeNotFound,
+		},
+		{
+			name:       "not enough coins",
+			err:        repository.ErrNotEnoughCoins,
+			wantOK:     true,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   models.CodeInsufficientFunds,
+		},
+		{
+			name:       "send to yourself",
+			err:        service.ErrSendToYourself,
+			wantOK:     true,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   models.CodeSelfTransfer,
+		},
+		{
+			name:       "wrong password",
+			err:        utils.ErrMismatchHashPassword,
+			wantOK:     true,
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   models.CodeWrongPassword,
+		},
+		{
+			name:       "empty item",
+			err:        ErrEmptyItem,
+			wantOK:     true,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   models.CodeEmptyItem,
+		},
+		{
+			name:   "unknown error",
+			err:    errors.New("connection refused"),
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := lookupAPIError(tt.err)
+			require.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantStatus, got.status)
+			assert.Equal(t, tt.wantCode, got.code)
+		})
+	}
+}
+
+func Test_lookupAPIError_ExpiredToken(t *testing.T) {
+	secret := "0123456789abcdef0123456789abcdef"
+
+	token, err := utils.GenerateToken(1, secret, -time.Minute)
+	require.NoError(t, err)
+
+	_, err = utils.ValidateToken(token, secret)
+	require.ErrorIs(t, err, utils.ErrExpiredToken)
+
+	got, ok := lookupAPIError(err)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusUnauthorized, got.status)
+	assert.Equal(t, models.CodeTokenExpired, got.code)
+}
+
+func Test_codeStatuses(t *testing.T) {
+	for _, code := range service.ErrorCodes() {
+		assert.Contains(t, codeStatuses, code, "no HTTP status for %s", code)
+	}
+}
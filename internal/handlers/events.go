@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"merch-shop/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// eventsRetry is how long EventSource waits before reconnecting a dropped stream.
+	eventsRetry       = 3 * time.Second
+	resumeEventsLimit = 100
+)
+
+// Events streams the balance changes, received transfers and purchases of the authenticated
+// user as Server-Sent Events. A client that reconnects with Last-Event-ID first gets the
+// events it missed, as long as they are still retained.
+func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := userIDFromContext(ctx)
+
+	lastID := 0
+	resume := r.Header.Get("Last-Event-ID")
+	if resume != "" {
+		id, err := strconv.Atoi(resume)
+
+		v := models.NewValidator()
+		v.Check(err == nil && id >= 0, "Last-Event-ID", "must be a non-negative integer")
+		if err := v.Err(); err != nil {
+			h.apiErrorResponse(w, r, err)
+			return
+		}
+
+		lastID = id
+	}
+
+	// Subscribing before looking up the missed events leaves no gap between the two,
+	// events that show up in both are skipped by ID.
+	sub := h.hub.Subscribe(userID)
+	defer sub.Close()
+
+	var missed []*models.UserEvent
+	for after := lastID; resume != ""; {
+		events, err := h.service.UserEvents(ctx, userID, after, resumeEventsLimit)
+		if err != nil {
+			h.apiErrorResponse(w, r, err)
+			return
+		}
+
+		missed = append(missed, events...)
+		if len(events) < resumeEventsLimit {
+			break
+		}
+		after = events[len(events)-1].ID
+	}
+
+	// The stream outlives the server write timeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		h.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keeps nginx from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", eventsRetry.Milliseconds()); err != nil {
+		return
+	}
+
+	// The hub may publish an event after ones with greater IDs, when its transaction commits
+	// late, so the events already sent are told apart by ID rather than by order.
+	sent := make(map[int]struct{}, len(missed))
+	for _, e := range missed {
+		if err := writeEvent(w, e); err != nil {
+			return
+		}
+		sent[e.ID] = struct{}{}
+	}
+
+	heartbeat := time.NewTicker(h.cfg.Events.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.C:
+			// The subscriber fell behind or the server is shutting down, the client
+			// reconnects and resumes from the last event it got.
+			if !ok {
+				return
+			}
+			if _, ok := sent[e.ID]; ok {
+				continue
+			}
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeEvent writes e as a Server-Sent Event. Its data is compact JSON, so it fits on one line.
+func writeEvent(w io.Writer, e *models.UserEvent) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+	return err
+}
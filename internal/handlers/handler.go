@@ -3,11 +3,14 @@ package handlers
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"merch-shop/internal/config"
+	"merch-shop/internal/health"
+	"merch-shop/internal/metrics"
 	"merch-shop/internal/models"
 	"merch-shop/internal/repository"
 	"merch-shop/internal/service"
+	"merch-shop/internal/stream"
 	"merch-shop/internal/utils"
 	"net/http"
 	"time"
@@ -16,43 +19,51 @@ import (
 type Handler struct {
 	service *service.Service
 	cfg     *config.Config
-	logger  *log.Logger
+	logger  *slog.Logger
+	metrics *metrics.Metrics
+	health  *health.Checker
+	hub     *stream.Hub
 }
 
-func NewHandler(service *service.Service, cfg *config.Config, logger *log.Logger) *Handler {
+func NewHandler(service *service.Service, cfg *config.Config, logger *slog.Logger, metrics *metrics.Metrics, health *health.Checker, hub *stream.Hub) *Handler {
 	return &Handler{
 		service: service,
 		cfg:     cfg,
 		logger:  logger,
+		metrics: metrics,
+		health:  health,
+		hub:     hub,
 	}
 }
 
 func (h *Handler) Auth(w http.ResponseWriter, r *http.Request) {
 	authRequest := &models.AuthRequest{}
-	err := h.readJSON(r, authRequest)
+	err := h.readJSON(w, r, authRequest)
 	if err != nil {
-		h.badRequestResponse(w, r, err)
+		h.apiErrorResponse(w, r, err)
 		return
 	}
 
-	if err := authRequestValid(authRequest); err != nil {
-		h.badRequestResponse(w, r, err)
+	err = authRequest.Validate()
+	if err != nil && isLegacyAPI(r.Context()) {
+		err = legacyAuthRequestError(authRequest, err)
+	}
+	if err != nil {
+		h.apiErrorResponse(w, r, err)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	// Registration must not be left half done if the client goes away, so only
+	// the request values are kept, not its cancellation.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 3*time.Second)
 	defer cancel()
 
 	token, err := h.service.Login(ctx, authRequest.Username, authRequest.Password)
 	if err != nil {
-		switch {
-		case errors.Is(err, utils.ErrMismatchHashPassword):
-			h.unauthorizedResponse(w, r, err)
-		case errors.Is(err, utils.ErrTooLongPassword):
-			h.badRequestResponse(w, r, err)
-		default:
-			h.serverErrorResponse(w, r, err)
+		if errors.Is(err, utils.ErrMismatchHashPassword) {
+			h.metrics.FailedLogin()
 		}
+		h.apiErrorResponse(w, r, err)
 		return
 	}
 
@@ -67,68 +78,135 @@ func (h *Handler) Auth(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) SendCoin(w http.ResponseWriter, r *http.Request) {
-	sendCoinRequest := &models.SendCoinRequest{}
-	err := h.readJSON(r, sendCoinRequest)
-	if err != nil {
-		h.badRequestResponse(w, r, err)
+	transfer, ok := h.sendCoin(w, r)
+	if !ok {
 		return
 	}
 
-	if err := sendCoinRequestValid(sendCoinRequest); err != nil {
-		h.badRequestResponse(w, r, err)
+	err := h.writeJSON(w, http.StatusOK, transfer, nil)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
+
+// CreateTransfer is the /api/v2 version of SendCoin, it answers with 201 Created.
+func (h *Handler) CreateTransfer(w http.ResponseWriter, r *http.Request) {
+	transfer, ok := h.sendCoin(w, r)
+	if !ok {
 		return
 	}
 
+	err := h.writeJSON(w, http.StatusCreated, transfer, nil)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
+
+// sendCoin performs the transfer described by the request body. On failure it writes
+// the error response and returns false.
+func (h *Handler) sendCoin(w http.ResponseWriter, r *http.Request) (*models.Transfer, bool) {
+	sendCoinRequest := &models.SendCoinRequest{}
+	err := h.readJSON(w, r, sendCoinRequest)
+	if err != nil {
+		h.apiErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	err = sendCoinRequest.Validate()
+	if err != nil && isLegacyAPI(r.Context()) {
+		err = legacySendCoinRequestError(sendCoinRequest, err)
+	}
+	if err != nil {
+		h.apiErrorResponse(w, r, err)
+		return nil, false
+	}
+
 	ctx := r.Context()
-	senderID := ctx.Value("userID").(int)
+	senderID := userIDFromContext(ctx)
 
-	err = h.service.SendCoin(ctx, senderID, sendCoinRequest.ReceiverName, sendCoinRequest.Amount)
+	transfer, err := h.service.SendCoin(ctx, senderID, sendCoinRequest.ReceiverName, sendCoinRequest.Amount)
 	if err != nil {
-		switch {
-		case errors.Is(err, repository.ErrRecordNotFound),
-			errors.Is(err, repository.ErrNotEnoughCoins),
-			errors.Is(err, service.ErrSendToYourself):
-			h.badRequestResponse(w, r, err)
-		default:
-			h.serverErrorResponse(w, r, err)
+		if errors.Is(err, repository.ErrNotEnoughCoins) {
+			h.metrics.NotEnoughCoins("send_coin")
 		}
+		h.apiErrorResponse(w, r, err)
+		return nil, false
 	}
+
+	h.metrics.CoinsTransferred(sendCoinRequest.Amount)
+	return transfer, true
 }
 
 func (h *Handler) BuyItem(w http.ResponseWriter, r *http.Request) {
 	itemName := r.PathValue("item")
 	if itemName == "" {
-		h.badRequestResponse(w, r, ErrEmptyItem)
+		h.apiErrorResponse(w, r, ErrEmptyItem)
+		return
+	}
+
+	purchase, ok := h.buyItem(w, r, itemName)
+	if !ok {
+		return
+	}
+
+	err := h.writeJSON(w, http.StatusOK, purchase, nil)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
+
+// CreatePurchase is the /api/v2 version of BuyItem. It takes the item from the body
+// instead of a GET path, and answers with 201 Created.
+func (h *Handler) CreatePurchase(w http.ResponseWriter, r *http.Request) {
+	purchaseRequest := &models.PurchaseRequest{}
+	err := h.readJSON(w, r, purchaseRequest)
+	if err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	if err := purchaseRequest.Validate(); err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	purchase, ok := h.buyItem(w, r, purchaseRequest.Item)
+	if !ok {
 		return
 	}
 
+	err = h.writeJSON(w, http.StatusCreated, purchase, nil)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
+
+// buyItem buys itemName for the authenticated user. On failure it writes
+// the error response and returns false.
+func (h *Handler) buyItem(w http.ResponseWriter, r *http.Request, itemName string) (*models.Purchase, bool) {
 	ctx := r.Context()
-	userID := ctx.Value("userID").(int)
+	userID := userIDFromContext(ctx)
 
-	err := h.service.BuyItem(ctx, userID, itemName)
+	purchase, err := h.service.BuyItem(ctx, userID, itemName)
 	if err != nil {
-		switch {
-		case errors.Is(err, repository.ErrRecordNotFound),
-			errors.Is(err, repository.ErrNotEnoughCoins):
-			h.badRequestResponse(w, r, err)
-		default:
-			h.serverErrorResponse(w, r, err)
+		if errors.Is(err, repository.ErrNotEnoughCoins) {
+			h.metrics.NotEnoughCoins("buy_item")
 		}
+		h.apiErrorResponse(w, r, err)
+		return nil, false
 	}
+
+	h.metrics.ItemPurchased(itemName)
+	return purchase, true
 }
 
 func (h *Handler) Info(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	userID := ctx.Value("userID").(int)
+	userID := userIDFromContext(ctx)
 
 	infoResponse, err := h.service.Info(ctx, userID)
 	if err != nil {
-		switch {
-		case errors.Is(err, repository.ErrRecordNotFound):
-			h.badRequestResponse(w, r, err)
-		default:
-			h.serverErrorResponse(w, r, err)
-		}
+		h.apiErrorResponse(w, r, err)
 		return
 	}
 
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"log/slog"
+	"merch-shop/internal/health"
+	"merch-shop/internal/models"
+	"net/http"
+)
+
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	err := h.writeJSON(w, http.StatusOK, &models.HealthResponse{Status: health.StatusOK}, nil)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
+
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	checks, err := h.health.Check(r.Context())
+
+	status := http.StatusOK
+	healthResponse := &models.HealthResponse{
+		Status: health.StatusOK,
+		Checks: checks,
+	}
+
+	if err != nil {
+		// The details may name hosts and tables, so they go to the log and not to whoever probes.
+		h.logger.LogAttrs(r.Context(), slog.LevelWarn, "service is not ready", slog.String("error", err.Error()))
+
+		status = http.StatusServiceUnavailable
+		healthResponse.Status = health.StatusFail
+	}
+
+	err = h.writeJSON(w, status, healthResponse, nil)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"merch-shop/internal/health"
+	"merch-shop/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Readyz(t *testing.T) {
+	checker := health.New(time.Second)
+	checker.Add("db", func(ctx context.Context) error { return nil })
+	checker.Add("migrations", func(ctx context.Context) error {
+		return errors.New(`relation "schema_migrations" does not exist`)
+	})
+
+	var logs bytes.Buffer
+	h := &Handler{logger: slog.New(slog.NewTextHandler(&logs, nil)), health: checker}
+
+	w := httptest.NewRecorder()
+	h.Readyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NotContains(t, w.Body.String(), "schema_migrations")
+
+	var healthResponse models.HealthResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&healthResponse))
+	assert.Equal(t, health.StatusFail, healthResponse.Status)
+	assert.Equal(t, map[string]string{"db": health.StatusOK, "migrations": health.StatusFail}, healthResponse.Checks)
+
+	assert.Contains(t, logs.String(), "schema_migrations")
+}
@@ -2,13 +2,98 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"merch-shop/internal/models"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 )
 
-func (h *Handler) readJSON(r *http.Request, dst any) error {
+const maxBodyBytes = 1 << 20
+
+// readJSON decodes a single JSON object from the request body into dst. Errors are
+// wrapped in ErrInvalidBody, ErrBodyTooLarge or ErrUnsupportedMediaType with a
+// message that is safe to show to the client. The unversioned /api routes read the
+// body as JSON whatever its Content-Type, as they did before the header was checked.
+func (h *Handler) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	if !isLegacyAPI(r.Context()) && !hasJSONBody(r) {
+		return ErrUnsupportedMediaType
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+
 	dec := json.NewDecoder(r.Body)
-	return dec.Decode(dst)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var unmarshalTypeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("%w: badly-formed JSON at character %d", ErrInvalidBody, syntaxError.Offset)
+
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return fmt.Errorf("%w: badly-formed JSON", ErrInvalidBody)
+
+		case errors.As(err, &unmarshalTypeError):
+			if unmarshalTypeError.Field != "" {
+				return fmt.Errorf("%w: field %q must be %s", ErrInvalidBody, unmarshalTypeError.Field, jsonTypeName(unmarshalTypeError.Type.Kind().String()))
+			}
+			return fmt.Errorf("%w: incorrect JSON type at character %d", ErrInvalidBody, unmarshalTypeError.Offset)
+
+		case errors.Is(err, io.EOF):
+			return fmt.Errorf("%w: body must not be empty", ErrInvalidBody)
+
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
+			return fmt.Errorf("%w: unknown field %s", ErrInvalidBody, fieldName)
+
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("%w: body must not be larger than %d bytes", ErrBodyTooLarge, maxBytesError.Limit)
+
+		default:
+			// The body could not be read, e.g. the client went away or was too slow to send it.
+			return fmt.Errorf("%w: body could not be read", ErrInvalidBody)
+		}
+	}
+
+	err = dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: body must only contain a single JSON value", ErrInvalidBody)
+	}
+
+	return nil
+}
+
+// hasJSONBody reports whether the Content-Type of the request is application/json.
+func hasJSONBody(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// jsonTypeName turns a Go kind into the JSON type a client would recognize.
+func jsonTypeName(kind string) string {
+	switch kind {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+		return "an integer"
+	case "float32", "float64":
+		return "a number"
+	case "bool":
+		return "a boolean"
+	case "slice", "array":
+		return "an array"
+	case "map", "struct":
+		return "an object"
+	default:
+		return "a " + kind
+	}
 }
 
 func (h *Handler) writeJSON(w http.ResponseWriter, status int, data any, headers http.Header) error {
@@ -29,22 +114,29 @@ func (h *Handler) writeJSON(w http.ResponseWriter, status int, data any, headers
 	return nil
 }
 
-func authRequestValid(authRequest *models.AuthRequest) error {
-	if authRequest.Username == "" || authRequest.Password == "" {
-		return ErrEmptyNamePassword
+// readString returns the query parameter key, or defaultValue if it is not set.
+func readString(qs url.Values, key, defaultValue string) string {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
 	}
 
-	return nil
+	return s
 }
 
-func sendCoinRequestValid(sendCoinRequest *models.SendCoinRequest) error {
-	if sendCoinRequest.ReceiverName == "" {
-		return ErrEmptyToUser
+// readInt returns the query parameter key as an integer, or defaultValue if it is not set.
+// A malformed value is recorded in v.
+func (h *Handler) readInt(qs url.Values, key string, defaultValue int, v *models.Validator) int {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
 	}
 
-	if sendCoinRequest.Amount <= 0 {
-		return ErrZeroOrNegativeAmount
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.Check(false, key, "must be an integer")
+		return defaultValue
 	}
 
-	return nil
+	return i
 }
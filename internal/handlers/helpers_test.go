@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"merch-shop/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_readJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		legacy      bool
+		body        string
+		wantErr     error
+		wantMessage string
+	}{
+		{
+			name:        "valid body",
+			contentType: "application/json",
+			body:        `{"toUser": "bob", "amount": 10}`,
+		},
+		{
+			name:        "content type with charset",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"toUser": "bob", "amount": 10}`,
+		},
+		{
+			name:        "missing content type",
+			body:        `{"toUser": "bob", "amount": 10}`,
+			wantErr:     ErrUnsupportedMediaType,
+			wantMessage: "Content-Type header must be application/json",
+		},
+		{
+			name:        "wrong content type",
+			contentType: "text/plain",
+			body:        `{"toUser": "bob", "amount": 10}`,
+			wantErr:     ErrUnsupportedMediaType,
+			wantMessage: "Content-Type header must be application/json",
+		},
+		{
+			name:   "missing content type on legacy route",
+			legacy: true,
+			body:   `{"toUser": "bob", "amount": 10}`,
+		},
+		{
+			name:        "form content type on legacy route",
+			contentType: "application/x-www-form-urlencoded",
+			legacy:      true,
+			body:        `{"toUser": "bob", "amount": 10}`,
+		},
+		{
+			name:        "syntax error on legacy route",
+			legacy:      true,
+			body:        `{"toUser": "bob",, "amount": 10}`,
+			wantErr:     ErrInvalidBody,
+			wantMessage: "invalid body: badly-formed JSON at character 18",
+		},
+		{
+			name:        "empty body",
+			contentType: "application/json",
+			wantErr:     ErrInvalidBody,
+			wantMessage: "invalid body: body must not be empty",
+		},
+		{
+			name:        "syntax error",
+			contentType: "application/json",
+			body:        `{"toUser": "bob",, "amount": 10}`,
+			wantErr:     ErrInvalidBody,
+			wantMessage: "invalid body: badly-formed JSON at character 18",
+		},
+		{
+			name:        "truncated body",
+			contentType: "application/json",
+			body:        `{"toUser": "bob"`,
+			wantErr:     ErrInvalidBody,
+			wantMessage: "invalid body: badly-formed JSON",
+		},
+		{
+			name:        "wrong field type",
+			contentType: "application/json",
+			body:        `{"toUser": "bob", "amount": "10"}`,
+			wantErr:     ErrInvalidBody,
+			wantMessage: `invalid body: field "amount" must be an integer`,
+		},
+		{
+			name:        "unknown field",
+			contentType: "application/json",
+			body:        `{"toUser": "bob", "amount": 10, "note": "hi"}`,
+			wantErr:     ErrInvalidBody,
+			wantMessage: `invalid body: unknown field "note"`,
+		},
+		{
+			name:        "trailing data",
+			contentType: "application/json",
+			body:        `{"toUser": "bob", "amount": 10} {}`,
+			wantErr:     ErrInvalidBody,
+			wantMessage: "invalid body: body must only contain a single JSON value",
+		},
+		{
+			name:        "too large",
+			contentType: "application/json",
+			body:        `{"toUser": "` + strings.Repeat("a", maxBodyBytes) + `"}`,
+			wantErr:     ErrBodyTooLarge,
+			wantMessage: "body is too large: body must not be larger than 1048576 bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/sendCoin", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.legacy {
+				r = r.WithContext(contextWithLegacyAPI(r.Context()))
+			}
+
+			h := &Handler{}
+			err := h.readJSON(httptest.NewRecorder(), r, &models.SendCoinRequest{})
+
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantMessage, err.Error())
+		})
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read tcp: i/o timeout")
+}
+
+func Test_readJSON_ReadError(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/sendCoin", failingReader{})
+	r.Header.Set("Content-Type", "application/json")
+
+	h := &Handler{}
+	err := h.readJSON(httptest.NewRecorder(), r, &models.SendCoinRequest{})
+
+	require.ErrorIs(t, err, ErrInvalidBody)
+	assert.Equal(t, "invalid body: body could not be read", err.Error())
+}
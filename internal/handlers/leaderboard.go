@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"merch-shop/internal/models"
+	"net/http"
+	"slices"
+)
+
+const (
+	defaultLeaderboardLimit = 10
+	maxLeaderboardLimit     = 100
+)
+
+var (
+	leaderboardModes   = []string{models.LeaderboardReceived, models.LeaderboardSent, models.LeaderboardItems}
+	leaderboardPeriods = []string{models.PeriodDay, models.PeriodWeek, models.PeriodMonth, models.PeriodAll}
+)
+
+func (h *Handler) Leaderboard(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := models.NewValidator()
+
+	mode := readString(qs, "mode", models.LeaderboardReceived)
+	period := readString(qs, "period", models.PeriodMonth)
+	limit := h.readInt(qs, "limit", defaultLeaderboardLimit, v)
+
+	v.Check(slices.Contains(leaderboardModes, mode), "mode", "must be one of received, sent, items")
+	v.Check(slices.Contains(leaderboardPeriods, period), "period", "must be one of day, week, month, all")
+	v.Check(limit >= 1 && limit <= maxLeaderboardLimit, "limit", models.Between(1, maxLeaderboardLimit))
+
+	if err := v.Err(); err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	board, err := h.service.Leaderboard(r.Context(), mode, period, limit)
+	if err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	err = h.writeJSON(w, http.StatusOK, board, nil)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
@@ -1,28 +1,276 @@
 package handlers
 
 import (
-	"context"
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log/slog"
+	"merch-shop/internal/config"
+	"merch-shop/internal/models"
+	"merch-shop/internal/requestid"
 	"merch-shop/internal/utils"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("merch-shop/internal/handlers")
+
 func (h *Handler) MiddlewareAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		tokenStr, err := utils.ExtractTokenFromHeader(r)
 		if err != nil {
-			h.unauthorizedResponse(w, r, err)
+			h.apiErrorResponse(w, r, err)
 			return
 		}
 
 		userID, err := utils.ValidateToken(tokenStr, h.cfg.JWT.SecretKey)
 		if err != nil {
-			h.unauthorizedResponse(w, r, err)
+			h.apiErrorResponse(w, r, err)
 			return
 		}
 
 		ctx := r.Context()
-		ctx = context.WithValue(ctx, "userID", userID)
+		requestLogFromContext(ctx).userID = userID
+		ctx = contextWithUserID(ctx, userID)
 
 		next(w, r.WithContext(ctx))
 	}
 }
+
+// MiddlewareAdmin lets through requests bearing the configured admin token. Without
+// a configured token the admin routes are disabled and always answer 403.
+func (h *Handler) MiddlewareAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tokenStr, err := utils.ExtractTokenFromHeader(r)
+		if err != nil {
+			h.apiErrorResponse(w, r, err)
+			return
+		}
+
+		adminToken := h.cfg.Admin.Token
+		if adminToken == "" || subtle.ConstantTimeCompare([]byte(tokenStr), []byte(adminToken)) != 1 {
+			h.errorResponse(w, r, http.StatusForbidden, models.CodeForbidden, "admin token required")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// MiddlewareRequestID takes the request ID from the X-Request-ID header, or generates one
+// if it is missing or malformed, stores it in the context and echoes it in the response.
+func (h *Handler) MiddlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		w.Header().Set(requestid.Header, id)
+
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	})
+}
+
+// MiddlewareDeprecation marks the unversioned /api routes as deprecated in favor of /api/v2,
+// announcing the configured sunset date.
+func (h *Handler) MiddlewareDeprecation(next http.HandlerFunc) http.HandlerFunc {
+	var sunset string
+	if t, err := time.Parse(time.DateOnly, h.cfg.API.Sunset); err == nil {
+		sunset = t.Format(http.TimeFormat)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		if sunset != "" {
+			w.Header().Set("Sunset", sunset)
+		}
+		w.Header().Set("Link", `</api/v2>; rel="successor-version"`)
+
+		next(w, r.WithContext(contextWithLegacyAPI(r.Context())))
+	}
+}
+
+func (h *Handler) MiddlewareAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rl := &requestLog{}
+		sr := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(sr, r.WithContext(contextWithRequestLog(r.Context(), rl)))
+
+		if sr.status == 0 {
+			sr.status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		if sr.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		h.logger.LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", sr.status),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("user_id", rl.userID),
+		)
+	})
+}
+
+func (h *Handler) MiddlewareMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(sr, r)
+
+		if sr.status == 0 {
+			sr.status = http.StatusOK
+		}
+
+		// ServeMux fills r.Pattern on the request it was given, so after the call it holds
+		// the matched route and keeps label cardinality bounded.
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+
+		h.metrics.ObserveRequest(r.Method, route, sr.status, time.Since(start))
+	})
+}
+
+func (h *Handler) MiddlewareTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		if id := requestid.FromContext(ctx); id != "" {
+			span.SetAttributes(attribute.String("request.id", id))
+		}
+
+		sr := &statusRecorder{ResponseWriter: w}
+		req := r.WithContext(ctx)
+
+		next.ServeHTTP(sr, req)
+
+		if sr.status == 0 {
+			sr.status = http.StatusOK
+		}
+
+		if req.Pattern != "" {
+			span.SetName(req.Pattern)
+			span.SetAttributes(semconv.HTTPRoute(req.Pattern))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(sr.status))
+		if sr.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sr.status))
+		}
+	})
+}
+
+// MiddlewareOpenAPI checks requests and responses of the routes described in api/openapi.yaml.
+// Mismatches are logged; in test mode a request that breaks the spec is rejected with 400
+// and a response that breaks it is replaced with an internal error, so tests catch clients
+// and handlers drifting away from the spec.
+func (h *Handler) MiddlewareOpenAPI(next http.Handler) http.Handler {
+	router := mustSpecRouter()
+	strict := h.cfg.Server.Mode == config.ModeTest
+
+	// Authentication is left to MiddlewareAuth, the spec only documents it.
+	requestOptions := &openapi3filter.Options{
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		MultiError:         true,
+	}
+	responseOptions := &openapi3filter.Options{
+		IncludeResponseStatus: true,
+		MultiError:            true,
+	}
+	// By default a schema error dumps the whole schema and value, which buries the reason in the logs.
+	for _, opts := range []*openapi3filter.Options{requestOptions, responseOptions} {
+		opts.WithCustomSchemaErrorFunc(func(err *openapi3.SchemaError) string {
+			return fmt.Sprintf("%s: %s", strings.Join(err.JSONPointer(), "/"), err.Reason)
+		})
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, pathParams, err := router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    specRequest(r, route.Operation),
+			PathParams: pathParams,
+			Route:      route,
+			Options:    requestOptions,
+		}
+
+		// The validator buffers the whole body, so bodies of unknown or excessive size
+		// are left to readJSON to reject.
+		if r.ContentLength >= 0 && r.ContentLength <= maxBodyBytes {
+			err := openapi3filter.ValidateRequest(r.Context(), input)
+			// The validator puts the buffered body back on the request it was given.
+			r.Body = input.Request.Body
+			if err != nil {
+				h.logger.LogAttrs(r.Context(), slog.LevelWarn, "request does not match openapi spec",
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("error", err.Error()),
+				)
+				if strict {
+					h.errorResponse(w, r, http.StatusBadRequest, models.CodeInvalidRequest, fmt.Sprintf("request does not match openapi spec: %v", err))
+					return
+				}
+			}
+		}
+
+		// An event stream never ends, so it can't be buffered and checked.
+		if streams(route.Operation) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rb := &responseBuffer{ResponseWriter: w}
+		next.ServeHTTP(rb, r)
+
+		err = openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 rb.status,
+			Header:                 w.Header(),
+			Body:                   io.NopCloser(bytes.NewReader(rb.body.Bytes())),
+			Options:                responseOptions,
+		})
+		if err != nil && strict {
+			h.serverErrorResponse(w, r, fmt.Errorf("response does not match openapi spec: %w", err))
+			return
+		}
+		if err != nil {
+			h.logError(r, fmt.Errorf("response does not match openapi spec: %w", err))
+		}
+
+		if err := rb.flush(); err != nil {
+			h.logError(r, err)
+		}
+	})
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"merch-shop/internal/config"
+	"merch-shop/internal/logger"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repository"
+	"merch-shop/internal/requestid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MiddlewareRequestID(t *testing.T) {
+	h := &Handler{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	var gotID string
+	next := h.MiddlewareRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = requestid.FromContext(r.Context())
+		h.apiErrorResponse(w, r, repository.ErrItemNotFound)
+	}))
+
+	tests := []struct {
+		name     string
+		header   string
+		wantSame bool
+	}{
+		{name: "client ID is kept", header: "client-id-42", wantSame: true},
+		{name: "missing ID is generated", header: ""},
+		{name: "malformed ID is replaced", header: "bad id\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/buy/beer", nil)
+			if tt.header != "" {
+				r.Header.Set(requestid.Header, tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			next.ServeHTTP(w, r)
+
+			require.NotEmpty(t, gotID)
+			if tt.wantSame {
+				assert.Equal(t, tt.header, gotID)
+			} else {
+				assert.NotEqual(t, tt.header, gotID)
+			}
+			assert.Equal(t, gotID, w.Header().Get(requestid.Header))
+
+			var errorResponse models.ErrorResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&errorResponse))
+			assert.Equal(t, gotID, errorResponse.RequestID)
+			assert.Equal(t, models.CodeItemNotFound, errorResponse.Code)
+		})
+	}
+}
+
+func Test_MiddlewareOpenAPI(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		body       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name: "documented response passes",
+			mode: config.ModeTest,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"id": 1, "item": "cup", "price": 20, "quantity": 1, "balance": 980}`))
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name: "undocumented status fails in test mode",
+			mode: config.ModeTest,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   models.CodeInternalError,
+		},
+		{
+			name: "wrong body type fails in test mode",
+			mode: config.ModeTest,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"id": "one"}`))
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   models.CodeInternalError,
+		},
+		{
+			name: "undocumented status is only logged in development mode",
+			mode: config.ModeDevelopment,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			},
+			wantStatus: http.StatusTeapot,
+		},
+		{
+			name: "request breaking the spec is rejected in test mode",
+			mode: config.ModeTest,
+			body: `{"item": 1}`,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				t.Error("handler called for a request breaking the spec")
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   models.CodeInvalidRequest,
+		},
+		{
+			name: "request breaking the spec is only logged in development mode",
+			mode: config.ModeDevelopment,
+			body: `{"item": 1}`,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Server.Mode = tt.mode
+			h := &Handler{cfg: cfg, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+			body := tt.body
+			if body == "" {
+				body = `{"item": "cup"}`
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/api/v2/purchases", strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			h.MiddlewareOpenAPI(tt.handler).ServeHTTP(w, r)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantCode != "" {
+				var errorResponse models.ErrorResponse
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&errorResponse))
+				assert.Equal(t, tt.wantCode, errorResponse.Code)
+			}
+		})
+	}
+}
+
+func Test_MiddlewareAccessLog(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		userID     int
+		requestID  string
+		wantStatus int
+		wantLevel  string
+	}{
+		{name: "implicit ok", wantStatus: http.StatusOK, wantLevel: "INFO"},
+		{name: "authorized request", status: http.StatusCreated, userID: 7, requestID: "req-1",
+			wantStatus: http.StatusCreated, wantLevel: "INFO"},
+		{name: "client error", status: http.StatusNotFound, wantStatus: http.StatusNotFound, wantLevel: "INFO"},
+		{name: "server error", status: http.StatusInternalServerError, wantStatus: http.StatusInternalServerError,
+			wantLevel: "ERROR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Log.Format = config.LogFormatJSON
+
+			var logs strings.Builder
+			log, err := logger.New(&logs, cfg)
+			require.NoError(t, err)
+
+			h := &Handler{logger: log}
+			next := h.MiddlewareAccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.userID != 0 {
+					requestLogFromContext(r.Context()).userID = tt.userID
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+			}))
+
+			r := httptest.NewRequest(http.MethodPost, "/api/sendCoin?x=1", nil)
+			if tt.requestID != "" {
+				r = r.WithContext(requestid.NewContext(r.Context(), tt.requestID))
+			}
+			w := httptest.NewRecorder()
+
+			next.ServeHTTP(w, r)
+
+			var record map[string]any
+			require.NoError(t, json.Unmarshal([]byte(logs.String()), &record))
+
+			assert.Equal(t, "request", record["msg"])
+			assert.Equal(t, tt.wantLevel, record["level"])
+			assert.Equal(t, http.MethodPost, record["method"])
+			assert.Equal(t, "/api/sendCoin", record["path"])
+			assert.EqualValues(t, tt.wantStatus, record["status"])
+			assert.EqualValues(t, tt.userID, record["user_id"])
+			assert.Contains(t, record, "duration")
+
+			if tt.requestID != "" {
+				assert.Equal(t, tt.requestID, record["request_id"])
+			} else {
+				assert.NotContains(t, record, "request_id")
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"bytes"
+	"merch-shop/api"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// loadSpec parses and validates the embedded OpenAPI spec.
+func loadSpec() (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+
+	doc, err := loader.LoadFromData(api.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// mustSpecRouter returns a router over the spec operations. The spec is embedded
+// and checked by tests, so failing to load it is a programming error.
+func mustSpecRouter() routers.Router {
+	doc, err := loadSpec()
+	if err != nil {
+		panic(err)
+	}
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		panic(err)
+	}
+
+	return router
+}
+
+// streams reports whether op answers with Server-Sent Events.
+func streams(op *openapi3.Operation) bool {
+	resp := op.Responses.Status(http.StatusOK)
+	return resp != nil && resp.Value != nil && resp.Value.Content.Get("text/event-stream") != nil
+}
+
+// specRequest returns the request to check against op. The deprecated unversioned routes
+// read the body as JSON whatever its Content-Type, so it is checked as JSON there too.
+func specRequest(r *http.Request, op *openapi3.Operation) *http.Request {
+	if !op.Deprecated || hasJSONBody(r) {
+		return r
+	}
+
+	sr := r.Clone(r.Context())
+	sr.Header.Set("Content-Type", "application/json")
+	return sr
+}
+
+// responseBuffer holds back the status and body written by a handler so the response
+// can be checked before it is sent. Headers go straight to the underlying writer.
+type responseBuffer struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rb *responseBuffer) WriteHeader(status int) {
+	if rb.status == 0 {
+		rb.status = status
+	}
+}
+
+func (rb *responseBuffer) Write(b []byte) (int, error) {
+	if rb.status == 0 {
+		rb.status = http.StatusOK
+	}
+	return rb.body.Write(b)
+}
+
+// flush sends the buffered response.
+func (rb *responseBuffer) flush() error {
+	if rb.status == 0 {
+		rb.status = http.StatusOK
+	}
+	rb.ResponseWriter.WriteHeader(rb.status)
+	_, err := rb.ResponseWriter.Write(rb.body.Bytes())
+	return err
+}
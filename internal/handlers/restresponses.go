@@ -1,30 +1,60 @@
 package handlers
 
 import (
+	"errors"
+	"log/slog"
 	"merch-shop/internal/models"
+	"merch-shop/internal/requestid"
 	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
-func (h *Handler) errorResponse(w http.ResponseWriter, r *http.Request, status int, message string) {
-	data := models.ErrorResponse{Errors: message}
+func (h *Handler) logError(r *http.Request, err error) {
+	h.logger.LogAttrs(r.Context(), slog.LevelError, "request failed",
+		slog.String("error", err.Error()),
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("user_id", requestLogFromContext(r.Context()).userID),
+	)
+}
+
+func (h *Handler) errorResponse(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	h.writeErrorResponse(w, r, status, models.ErrorResponse{Errors: message, Code: code})
+}
+
+func (h *Handler) writeErrorResponse(w http.ResponseWriter, r *http.Request, status int, data models.ErrorResponse) {
+	data.RequestID = requestid.FromContext(r.Context())
 
 	err := h.writeJSON(w, status, data, nil)
 	if err != nil {
-		h.logger.Print(err)
+		h.logError(r, err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
 
 func (h *Handler) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
-	h.logger.Print(err)
+	trace.SpanFromContext(r.Context()).RecordError(err)
+	h.logError(r, err)
 	message := "internal server error"
-	h.errorResponse(w, r, http.StatusInternalServerError, message)
+	h.errorResponse(w, r, http.StatusInternalServerError, models.CodeInternalError, message)
 }
 
-func (h *Handler) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	h.errorResponse(w, r, http.StatusBadRequest, err.Error())
-}
+// apiErrorResponse answers with the status and code mapped to err in apiErrors,
+// or with an internal server error if err is not known.
+func (h *Handler) apiErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	e, ok := lookupAPIError(err)
+	if !ok {
+		h.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := models.ErrorResponse{Errors: err.Error(), Code: e.code}
+
+	var validationErr *models.ValidationError
+	if errors.As(err, &validationErr) {
+		data.Fields = validationErr.Fields
+	}
 
-func (h *Handler) unauthorizedResponse(w http.ResponseWriter, r *http.Request, err error) {
-	h.errorResponse(w, r, http.StatusUnauthorized, err.Error())
+	h.writeErrorResponse(w, r, e.status, data)
 }
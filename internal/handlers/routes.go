@@ -1,14 +1,70 @@
 package handlers
 
-import "net/http"
+import (
+	"merch-shop/internal/config"
+	"net/http"
+)
+
+type route struct {
+	pattern string
+	handler http.HandlerFunc
+}
+
+// apiRoutes lists the routes described in api/openapi.yaml, Test_RoutesMatchSpec keeps the two in sync.
+func (h *Handler) apiRoutes() []route {
+	return []route{
+		{"POST /api/v2/auth", h.Auth},
+		{"GET /api/v2/info", h.MiddlewareAuth(h.Info)},
+		{"POST /api/v2/transfers", h.MiddlewareAuth(h.CreateTransfer)},
+		{"POST /api/v2/purchases", h.MiddlewareAuth(h.CreatePurchase)},
+
+		{"GET /api/users", h.MiddlewareAuth(h.ListUsers)},
+		{"GET /api/users/{username}", h.MiddlewareAuth(h.GetUser)},
+		{"PATCH /api/me", h.MiddlewareAuth(h.UpdateMe)},
+		{"GET /api/leaderboard", h.MiddlewareAuth(h.Leaderboard)},
+		{"GET /api/events", h.MiddlewareAuth(h.Events)},
+
+		{"GET /api/admin/webhooks/deliveries", h.MiddlewareAdmin(h.ListWebhookDeliveries)},
+		{"POST /api/admin/webhooks/deliveries/{id}/replay", h.MiddlewareAdmin(h.ReplayWebhookDelivery)},
+		{"POST /api/admin/users/{username}/coins", h.MiddlewareAdmin(h.GrantCoins)},
+		{"POST /api/admin/users/{username}/deactivate", h.MiddlewareAdmin(h.DeactivateUser)},
+		{"GET /api/admin/items", h.MiddlewareAdmin(h.ListItems)},
+		{"PUT /api/admin/items/{item}", h.MiddlewareAdmin(h.SetItemPrice)},
+
+		// The unversioned API is kept for existing clients until its sunset date.
+		{"POST /api/auth", h.MiddlewareDeprecation(h.Auth)},
+		{"GET /api/info", h.MiddlewareDeprecation(h.MiddlewareAuth(h.Info))},
+		{"POST /api/sendCoin", h.MiddlewareDeprecation(h.MiddlewareAuth(h.SendCoin))},
+		{"GET /api/buy/{item}", h.MiddlewareDeprecation(h.MiddlewareAuth(h.BuyItem))},
+	}
+}
 
 func (h *Handler) Routes() http.Handler {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("POST /api/auth", h.Auth)
-	mux.HandleFunc("GET /api/info", h.MiddlewareAuth(h.Info))
-	mux.HandleFunc("POST /api/sendCoin", h.MiddlewareAuth(h.SendCoin))
-	mux.HandleFunc("GET /api/buy/{item}", h.MiddlewareAuth(h.BuyItem))
+	for _, rt := range h.apiRoutes() {
+		mux.HandleFunc(rt.pattern, rt.handler)
+	}
+
+	mux.HandleFunc("GET /api/openapi.yaml", h.OpenAPISpec)
+	mux.Handle("GET /api/docs/", h.SwaggerUI())
+
+	if h.cfg.Metrics.Enabled && h.cfg.Metrics.Port == "" {
+		mux.Handle("GET /metrics", h.metrics.Handler())
+	}
+
+	var api http.Handler = mux
+	switch h.cfg.Server.Mode {
+	case config.ModeDevelopment, config.ModeTest:
+		api = h.MiddlewareOpenAPI(mux)
+	}
+
+	// Probes bypass the API middleware chain: they are polled every few seconds,
+	// must not be throttled and would only add noise to logs, metrics and traces.
+	root := http.NewServeMux()
+	root.HandleFunc("GET /healthz", h.Healthz)
+	root.HandleFunc("GET /readyz", h.Readyz)
+	root.Handle("/", h.MiddlewareAccessLog(h.MiddlewareTracing(h.MiddlewareMetrics(api))))
 
-	return mux
+	return h.MiddlewareRequestID(root)
 }
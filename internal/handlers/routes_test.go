@@ -0,0 +1,869 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"merch-shop/internal/config"
+	"merch-shop/internal/health"
+	"merch-shop/internal/metrics"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repository"
+	"merch-shop/internal/service"
+	"merch-shop/internal/stream"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminToken = "admin-0123456789abcdef0123456789abcdef"
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	return newTestServerWithRepo(t, repository.NewMemoryRepository())
+}
+
+func newTestServerWithRepo(t *testing.T, repo repository.Repository) *httptest.Server {
+	t.Helper()
+
+	// Every request and response of the route tests is checked against the OpenAPI spec.
+	return newTestServerWithMode(t, repo, config.ModeTest)
+}
+
+// newProductionServer serves repo without the OpenAPI checks, as in production. Requests
+// the spec forbids get to the handlers there, so their own validation can be tested.
+func newProductionServer(t *testing.T, repo repository.Repository) *httptest.Server {
+	t.Helper()
+
+	return newTestServerWithMode(t, repo, config.ModeProduction)
+}
+
+func newTestServerWithMode(t *testing.T, repo repository.Repository, mode string) *httptest.Server {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.JWT.SecretKey = "0123456789abcdef0123456789abcdef"
+	cfg.JWT.TokenExpiry = time.Hour
+	cfg.API.Sunset = "2027-06-30"
+	cfg.Admin.Token = testAdminToken
+	cfg.Events.Heartbeat = 50 * time.Millisecond
+	cfg.Events.PollInterval = 10 * time.Millisecond
+	cfg.Events.Retention = time.Hour
+	cfg.Server.Mode = mode
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	hub := stream.NewHub(repo, cfg.Events, logger)
+	go hub.Poll(ctx)
+
+	service := service.NewService(repo, cfg)
+	h := NewHandler(service, cfg, logger, metrics.New(), health.New(time.Second), hub)
+
+	srv := httptest.NewServer(h.Routes())
+	t.Cleanup(srv.Close)
+	// Cleanups run last first, so open event streams end before the server waits for them.
+	t.Cleanup(hub.Close)
+	t.Cleanup(cancel)
+
+	return srv
+}
+
+func doJSON(t *testing.T, srv *httptest.Server, method, path, token string, body any) *http.Response {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+
+	req, err := http.NewRequest(method, srv.URL+path, &buf)
+	require.NoError(t, err)
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+func login(t *testing.T, srv *httptest.Server, path, username string) string {
+	t.Helper()
+
+	resp := doJSON(t, srv, http.MethodPost, path, "", models.AuthRequest{Username: username, Password: "password"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var authResponse models.AuthResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&authResponse))
+
+	return authResponse.Token
+}
+
+func Test_RoutesMatchSpec(t *testing.T) {
+	doc, err := loadSpec()
+	require.NoError(t, err)
+
+	var documented []string
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented = append(documented, method+" "+path)
+		}
+	}
+
+	var registered []string
+	for _, rt := range (&Handler{cfg: &config.Config{}}).apiRoutes() {
+		registered = append(registered, rt.pattern)
+	}
+
+	assert.ElementsMatch(t, documented, registered, "routes and api/openapi.yaml disagree")
+
+	srv := newTestServer(t)
+
+	// The mux answers unknown routes with plain text, handlers always answer with JSON.
+	for _, op := range documented {
+		method, path, _ := strings.Cut(op, " ")
+		path = strings.NewReplacer("{", "", "}", "").Replace(path)
+
+		resp := doJSON(t, srv, method, path, "", nil)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"), "%s is not served", op)
+	}
+}
+
+func Test_Docs(t *testing.T) {
+	srv := newTestServer(t)
+
+	tests := []struct {
+		path        string
+		contentType string
+		contains    string
+	}{
+		{path: "/api/openapi.yaml", contentType: "application/yaml", contains: "/api/v2/purchases"},
+		{path: "/api/docs/", contentType: "text/html; charset=utf-8", contains: "swagger-ui"},
+		{path: "/api/docs/swagger-initializer.js", contentType: "text/javascript; charset=utf-8", contains: `"/api/openapi.yaml"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp := doJSON(t, srv, http.MethodGet, tt.path, "", nil)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, tt.contentType, resp.Header.Get("Content-Type"))
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(body), tt.contains)
+		})
+	}
+}
+
+func Test_RoutesV2(t *testing.T) {
+	srv := newTestServer(t)
+
+	token := login(t, srv, "/api/v2/auth", "alice")
+	login(t, srv, "/api/v2/auth", "bob")
+
+	t.Run("purchase", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodPost, "/api/v2/purchases", token, models.PurchaseRequest{Item: "cup"})
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Deprecation"))
+
+		var purchase models.Purchase
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&purchase))
+		assert.NotZero(t, purchase.ID)
+		assert.Equal(t, "cup", purchase.Item)
+		assert.Equal(t, 20, purchase.Price)
+		assert.Equal(t, 1, purchase.Quantity)
+		assert.Equal(t, 980, purchase.Balance)
+	})
+
+	t.Run("purchase of unknown item", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodPost, "/api/v2/purchases", token, models.PurchaseRequest{Item: "beer"})
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		var errorResponse models.ErrorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResponse))
+		assert.Equal(t, models.CodeItemNotFound, errorResponse.Code)
+	})
+
+	t.Run("purchase with GET is not allowed", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodGet, "/api/v2/purchases", token, nil)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+
+	t.Run("transfer", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodPost, "/api/v2/transfers", token, models.SendCoinRequest{ReceiverName: "bob", Amount: 30})
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		var transfer models.Transfer
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&transfer))
+		assert.NotZero(t, transfer.ID)
+		assert.Equal(t, "alice", transfer.FromUser)
+		assert.Equal(t, "bob", transfer.ToUser)
+		assert.Equal(t, 30, transfer.Amount)
+		assert.Equal(t, 950, transfer.Balance)
+		assert.False(t, transfer.CreatedAt.IsZero())
+	})
+
+	t.Run("transfer without token", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodPost, "/api/v2/transfers", "", models.SendCoinRequest{ReceiverName: "bob", Amount: 30})
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func Test_RoutesDeprecated(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	srv := newTestServerWithRepo(t, repo)
+	prod := newProductionServer(t, repo)
+
+	token := login(t, srv, "/api/auth", "carl")
+
+	login(t, srv, "/api/auth", "dave")
+
+	t.Run("deprecation headers", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodGet, "/api/info", token, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		assert.Equal(t, "true", resp.Header.Get("Deprecation"))
+		assert.Equal(t, "Wed, 30 Jun 2027 00:00:00 GMT", resp.Header.Get("Sunset"))
+		assert.Equal(t, `</api/v2>; rel="successor-version"`, resp.Header.Get("Link"))
+	})
+
+	t.Run("buy returns purchase", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodGet, "/api/buy/pen", token, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var purchase models.Purchase
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&purchase))
+		assert.Equal(t, "pen", purchase.Item)
+		assert.Equal(t, 10, purchase.Price)
+		assert.Equal(t, 1, purchase.Quantity)
+		assert.Equal(t, 990, purchase.Balance)
+	})
+
+	t.Run("sendCoin returns transfer", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodPost, "/api/sendCoin", token, models.SendCoinRequest{ReceiverName: "dave", Amount: 90})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var transfer models.Transfer
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&transfer))
+		assert.NotZero(t, transfer.ID)
+		assert.Equal(t, "dave", transfer.ToUser)
+		assert.Equal(t, 90, transfer.Amount)
+		assert.Equal(t, 900, transfer.Balance)
+		assert.False(t, transfer.CreatedAt.IsZero())
+	})
+
+	t.Run("body without json content type", func(t *testing.T) {
+		post := func(t *testing.T, srv *httptest.Server, path, contentType, body string) *http.Response {
+			t.Helper()
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader(body))
+			require.NoError(t, err)
+			if contentType != "" {
+				req.Header.Set("Content-Type", contentType)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			resp, err := srv.Client().Do(req)
+			require.NoError(t, err)
+			t.Cleanup(func() { resp.Body.Close() })
+
+			return resp
+		}
+
+		// Baseline clients send no Content-Type or curl's default one, the legacy routes
+		// keep reading their bodies as JSON in both modes.
+		for name, srv := range map[string]*httptest.Server{"test": srv, "production": prod} {
+			for _, contentType := range []string{"", "application/x-www-form-urlencoded"} {
+				t.Run(fmt.Sprintf("%s mode, content type %q", name, contentType), func(t *testing.T) {
+					resp := post(t, srv, "/api/auth", contentType, `{"username": "carl", "password": "password"}`)
+					assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+					resp = post(t, srv, "/api/sendCoin", contentType, `{"toUser": "dave", "amount": 1}`)
+					assert.Equal(t, http.StatusOK, resp.StatusCode)
+				})
+			}
+		}
+
+		resp := post(t, prod, "/api/v2/transfers", "", `{"toUser": "dave", "amount": 1}`)
+		assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	})
+}
+
+func Test_RoutesValidationErrors(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	srv := newTestServerWithRepo(t, repo)
+	prod := newProductionServer(t, repo)
+
+	token := login(t, srv, "/api/v2/auth", "frank")
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		body       any
+		wantCode   string
+		wantErrors string
+	}{
+		{
+			name:       "legacy auth",
+			path:       "/api/auth",
+			body:       models.AuthRequest{Username: "frank"},
+			wantCode:   models.CodeEmptyCredentials,
+			wantErrors: "empty name or password specified",
+		},
+		{
+			name:       "legacy sendCoin without receiver",
+			path:       "/api/sendCoin",
+			token:      token,
+			body:       models.SendCoinRequest{Amount: -50},
+			wantCode:   models.CodeEmptyReceiver,
+			wantErrors: "empty toUser field",
+		},
+		{
+			name:       "legacy sendCoin with negative amount",
+			path:       "/api/sendCoin",
+			token:      token,
+			body:       models.SendCoinRequest{ReceiverName: "bob", Amount: -50},
+			wantCode:   models.CodeInvalidAmount,
+			wantErrors: "amount to send should be positive",
+		},
+		{
+			name:       "v2 auth",
+			path:       "/api/v2/auth",
+			body:       models.AuthRequest{Username: "frank"},
+			wantCode:   models.CodeValidationFailed,
+			wantErrors: "password: must be provided",
+		},
+		{
+			name:       "v2 transfer",
+			path:       "/api/v2/transfers",
+			token:      token,
+			body:       models.SendCoinRequest{Amount: -50},
+			wantCode:   models.CodeValidationFailed,
+			wantErrors: "amount: must be positive; toUser: must be provided",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := doJSON(t, prod, http.MethodPost, tt.path, tt.token, tt.body)
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+			var errorResponse models.ErrorResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResponse))
+			assert.Equal(t, tt.wantCode, errorResponse.Code)
+			assert.Equal(t, tt.wantErrors, errorResponse.Errors)
+		})
+	}
+
+	t.Run("request breaking the spec in test mode", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodPost, "/api/v2/transfers", token, models.SendCoinRequest{Amount: -50})
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		var errorResponse models.ErrorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResponse))
+		assert.Equal(t, models.CodeInvalidRequest, errorResponse.Code)
+		assert.Contains(t, errorResponse.Errors, "amount")
+	})
+}
+
+func Test_RoutesUsers(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	srv := newTestServerWithRepo(t, repo)
+	prod := newProductionServer(t, repo)
+
+	token := login(t, srv, "/api/v2/auth", "erin")
+	login(t, srv, "/api/v2/auth", "eric")
+	login(t, srv, "/api/v2/auth", "ernest")
+	login(t, srv, "/api/v2/auth", "frank")
+
+	t.Run("search pages", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodGet, "/api/users?query=er&limit=2", token, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var list models.UserList
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+		require.Len(t, list.Users, 2)
+		assert.Equal(t, "eric", list.Users[0].Username)
+		assert.Equal(t, "erin", list.Users[1].Username)
+		assert.Equal(t, "erin", list.Next)
+
+		resp = doJSON(t, srv, http.MethodGet, "/api/users?query=er&limit=2&after="+list.Next, token, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		list = models.UserList{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+		require.Len(t, list.Users, 1)
+		assert.Equal(t, "ernest", list.Users[0].Username)
+		assert.Empty(t, list.Next)
+	})
+
+	t.Run("search with invalid limit", func(t *testing.T) {
+		resp := doJSON(t, prod, http.MethodGet, "/api/users?limit=many", token, nil)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		var errorResponse models.ErrorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResponse))
+		assert.Equal(t, models.CodeValidationFailed, errorResponse.Code)
+		assert.Equal(t, map[string]string{"limit": "must be an integer"}, errorResponse.Fields)
+	})
+
+	t.Run("update and get profile", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodPatch, "/api/me", token, map[string]string{"displayName": "Erin E."})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = doJSON(t, srv, http.MethodPatch, "/api/me", token, map[string]string{"avatarUrl": "https://example.com/erin.png"})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = doJSON(t, srv, http.MethodGet, "/api/users/erin", token, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var profile models.UserProfile
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&profile))
+		assert.Equal(t, "erin", profile.Username)
+		assert.Equal(t, "Erin E.", profile.DisplayName)
+		assert.Equal(t, "https://example.com/erin.png", profile.AvatarURL)
+		assert.False(t, profile.JoinedAt.IsZero())
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodGet, "/api/users/nobody", token, nil)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		var errorResponse models.ErrorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResponse))
+		assert.Equal(t, models.CodeUserNotFound, errorResponse.Code)
+	})
+
+	t.Run("invalid profile update", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodPatch, "/api/me", token, map[string]string{"avatarUrl": "ftp://example.com/erin.png"})
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func Test_RoutesLeaderboard(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	srv := newTestServerWithRepo(t, repo)
+	prod := newProductionServer(t, repo)
+
+	gina := login(t, srv, "/api/v2/auth", "gina")
+	hank := login(t, srv, "/api/v2/auth", "hank")
+	login(t, srv, "/api/v2/auth", "ivan")
+
+	resp := doJSON(t, srv, http.MethodPost, "/api/v2/transfers", gina, models.SendCoinRequest{ReceiverName: "ivan", Amount: 30})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp = doJSON(t, srv, http.MethodPost, "/api/v2/transfers", hank, models.SendCoinRequest{ReceiverName: "gina", Amount: 20})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	leaderboard := func(t *testing.T, query string) models.Leaderboard {
+		t.Helper()
+
+		resp := doJSON(t, srv, http.MethodGet, "/api/leaderboard"+query, gina, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var board models.Leaderboard
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&board))
+		return board
+	}
+
+	t.Run("top receivers", func(t *testing.T) {
+		board := leaderboard(t, "?period=week")
+		assert.Equal(t, models.LeaderboardReceived, board.Mode)
+		assert.Equal(t, models.PeriodWeek, board.Period)
+		assert.NotNil(t, board.Since)
+		assert.Equal(t, []*models.LeaderboardEntry{
+			{Rank: 1, Username: "ivan", Score: 30},
+			{Rank: 2, Username: "gina", Score: 20},
+		}, board.Entries)
+	})
+
+	t.Run("opt out", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodPatch, "/api/me", gina, map[string]bool{"leaderboardOptOut": true})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var profile models.UserProfile
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&profile))
+		require.NotNil(t, profile.LeaderboardOptOut)
+		assert.True(t, *profile.LeaderboardOptOut)
+
+		board := leaderboard(t, "?mode=sent&period=all")
+		assert.Nil(t, board.Since)
+		assert.Equal(t, []*models.LeaderboardEntry{
+			{Rank: 1, Username: "hank", Score: 20},
+		}, board.Entries)
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		resp := doJSON(t, prod, http.MethodGet, "/api/leaderboard?mode=richest", gina, nil)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		var errorResponse models.ErrorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResponse))
+		assert.Equal(t, map[string]string{"mode": "must be one of received, sent, items"}, errorResponse.Fields)
+	})
+}
+
+func Test_RoutesAdminWebhooks(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+	srv := newTestServerWithRepo(t, repo)
+
+	john := login(t, srv, "/api/v2/auth", "john")
+
+	_, err := repo.DispatchEvents(ctx, map[string][]string{models.EventUserRegistered: {"hr"}}, 10)
+	require.NoError(t, err)
+	deliveries, err := repo.ClaimDeliveries(ctx, time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.NoError(t, repo.MarkFailed(ctx, deliveries[0].ID, deliveries[0].LeaseID, "status 500", time.Minute, true))
+
+	replayPath := fmt.Sprintf("/api/admin/webhooks/deliveries/%d/replay", deliveries[0].ID)
+
+	t.Run("admin token required", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodGet, "/api/admin/webhooks/deliveries", "", nil)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		resp = doJSON(t, srv, http.MethodGet, "/api/admin/webhooks/deliveries", john, nil)
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		var errorResponse models.ErrorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResponse))
+		assert.Equal(t, models.CodeForbidden, errorResponse.Code)
+	})
+
+	t.Run("list dead deliveries", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodGet, "/api/admin/webhooks/deliveries", testAdminToken, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var list models.WebhookDeliveryList
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+		require.Len(t, list.Deliveries, 1)
+		assert.Equal(t, models.EventUserRegistered, list.Deliveries[0].EventType)
+		assert.Equal(t, "hr", list.Deliveries[0].Endpoint)
+		assert.Equal(t, "status 500", list.Deliveries[0].LastError)
+		assert.Zero(t, list.Next)
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodPost, replayPath, testAdminToken, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var delivery models.WebhookDelivery
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&delivery))
+		assert.Equal(t, models.DeliveryPending, delivery.Status)
+		assert.Zero(t, delivery.Attempts)
+
+		resp = doJSON(t, srv, http.MethodPost, replayPath, testAdminToken, nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, "only dead deliveries can be replayed")
+
+		resp = doJSON(t, srv, http.MethodPost, "/api/admin/webhooks/deliveries/first/replay", testAdminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func Test_RoutesAdminShop(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	srv := newTestServerWithRepo(t, repo)
+	prod := newProductionServer(t, repo)
+
+	john := login(t, srv, "/api/v2/auth", "john")
+	login(t, srv, "/api/v2/auth", "mary")
+
+	balanceOf := func(token string) int {
+		t.Helper()
+
+		resp := doJSON(t, srv, http.MethodGet, "/api/v2/info", token, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var info models.InfoResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+		return info.Coins
+	}
+
+	t.Run("admin token required", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodPost, "/api/admin/users/john/coins", john, models.GrantCoinsRequest{Amount: 100})
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		assert.Equal(t, 1000, balanceOf(john))
+	})
+
+	t.Run("grant coins", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodPost, "/api/admin/users/john/coins", testAdminToken, models.GrantCoinsRequest{Amount: 100})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var grant models.CoinGrant
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&grant))
+		assert.Equal(t, models.CoinGrant{Username: "john", Amount: 100, Balance: 1100}, grant)
+		assert.Equal(t, 1100, balanceOf(john))
+
+		resp = doJSON(t, srv, http.MethodGet, "/api/v2/info", john, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var info models.InfoResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+		require.Len(t, info.CoinHistory.Granted, 1)
+		assert.Equal(t, 100, info.CoinHistory.Granted[0].Amount)
+
+		resp = doJSON(t, srv, http.MethodPost, "/api/admin/users/john/coins", testAdminToken, models.GrantCoinsRequest{Amount: 0})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp = doJSON(t, srv, http.MethodPost, "/api/admin/users/nobody/coins", testAdminToken, models.GrantCoinsRequest{Amount: 100})
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("balance limit", func(t *testing.T) {
+		user, err := repo.GetByUsername(context.Background(), "mary")
+		require.NoError(t, err)
+		_, err = repo.GrantCoins(context.Background(), user.ID, math.MaxInt32-1000)
+		require.NoError(t, err)
+
+		resp := doJSON(t, srv, http.MethodPost, "/api/admin/users/mary/coins", testAdminToken, models.GrantCoinsRequest{Amount: 1})
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		var errorResponse models.ErrorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResponse))
+		assert.Equal(t, models.CodeBalanceLimit, errorResponse.Code)
+
+		resp = doJSON(t, srv, http.MethodPost, "/api/v2/transfers", john, models.SendCoinRequest{ReceiverName: "mary", Amount: 1})
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResponse))
+		assert.Equal(t, models.CodeBalanceLimit, errorResponse.Code)
+	})
+
+	t.Run("items", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodPut, "/api/admin/items/cup", testAdminToken, models.SetItemPriceRequest{Price: 25})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = doJSON(t, srv, http.MethodPut, "/api/admin/items/sticker", testAdminToken, models.SetItemPriceRequest{Price: 5})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var item models.Item
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&item))
+		assert.Equal(t, "sticker", item.Name)
+		assert.Equal(t, 5, item.Price)
+
+		resp = doJSON(t, srv, http.MethodPut, "/api/admin/items/sticker", testAdminToken, models.SetItemPriceRequest{Price: -1})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp = doJSON(t, prod, http.MethodPut, "/api/admin/items/sticker", testAdminToken, models.SetItemPriceRequest{Price: 1 << 31})
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		var errorResponse models.ErrorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResponse))
+		assert.Equal(t, models.CodeValidationFailed, errorResponse.Code)
+		assert.Equal(t, map[string]string{"price": models.Between(0, maxItemPrice)}, errorResponse.Fields)
+
+		resp = doJSON(t, srv, http.MethodGet, "/api/admin/items", testAdminToken, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var list models.ItemList
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+		prices := map[string]int{}
+		for _, item := range list.Items {
+			prices[item.Name] = item.Price
+		}
+		assert.Equal(t, 25, prices["cup"])
+		assert.Equal(t, 5, prices["sticker"])
+
+		resp = doJSON(t, srv, http.MethodPost, "/api/v2/purchases", john, models.PurchaseRequest{Item: "sticker"})
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		var purchase models.Purchase
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&purchase))
+		assert.Equal(t, 5, purchase.Price)
+	})
+
+	t.Run("deactivate", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodPost, "/api/admin/users/mary/deactivate", testAdminToken, nil)
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		resp = doJSON(t, srv, http.MethodPost, "/api/v2/transfers", john, models.SendCoinRequest{ReceiverName: "mary", Amount: 10})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp = doJSON(t, srv, http.MethodPost, "/api/admin/users/mary/deactivate", testAdminToken, nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp = doJSON(t, srv, http.MethodPost, "/api/v2/auth", "", models.AuthRequest{Username: "mary", Password: "wrong"})
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		resp = doJSON(t, srv, http.MethodPost, "/api/v2/auth", "", models.AuthRequest{Username: "mary", Password: "password"})
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		var errorResponse models.ErrorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResponse))
+		assert.Equal(t, models.CodeUserDeactivated, errorResponse.Code)
+	})
+}
+
+type sseEvent struct {
+	id        string
+	eventType string
+	data      string
+}
+
+// openEvents connects to /api/events and returns a function reading the next event.
+// Heartbeats and retry hints are returned as events of the "heartbeat" and "retry" types.
+func openEvents(t *testing.T, srv *httptest.Server, token, lastEventID string) func() sseEvent {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	lines := bufio.NewScanner(resp.Body)
+
+	return func() sseEvent {
+		t.Helper()
+
+		var e sseEvent
+		for lines.Scan() {
+			line := lines.Text()
+			if line == "" {
+				if e != (sseEvent{}) {
+					return e
+				}
+				continue
+			}
+
+			field, value, _ := strings.Cut(line, ": ")
+			switch field {
+			case "":
+				e.eventType = "heartbeat"
+				e.data = value
+			case "retry":
+				e.eventType = "retry"
+				e.data = value
+			case "id":
+				e.id = value
+			case "event":
+				e.eventType = value
+			case "data":
+				e.data = value
+			}
+		}
+
+		require.NoError(t, lines.Err())
+		require.FailNow(t, "event stream ended")
+		return e
+	}
+}
+
+// nextEvent skips heartbeats and retry hints.
+func nextEvent(t *testing.T, next func() sseEvent) sseEvent {
+	t.Helper()
+
+	for {
+		e := next()
+		if e.eventType != "heartbeat" && e.eventType != "retry" {
+			return e
+		}
+	}
+}
+
+func Test_RoutesEvents(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	srv := newTestServerWithRepo(t, repo)
+	prod := newProductionServer(t, repo)
+
+	alice := login(t, srv, "/api/v2/auth", "alice")
+	bob := login(t, srv, "/api/v2/auth", "bob")
+
+	t.Run("token required", func(t *testing.T) {
+		resp := doJSON(t, srv, http.MethodGet, "/api/events", "", nil)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("invalid last event id", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, prod.URL+"/api/events", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+alice)
+		req.Header.Set("Last-Event-ID", "last")
+
+		resp, err := prod.Client().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		var errorResponse models.ErrorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&errorResponse))
+		assert.Contains(t, errorResponse.Fields, "Last-Event-ID")
+	})
+
+	var events []sseEvent
+
+	t.Run("live", func(t *testing.T) {
+		next := openEvents(t, srv, alice, "")
+		assert.Equal(t, sseEvent{eventType: "retry", data: "3000"}, next(), "the stream starts with a retry hint")
+
+		resp := doJSON(t, srv, http.MethodPost, "/api/v2/transfers", bob, models.SendCoinRequest{ReceiverName: "alice", Amount: 10})
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		resp = doJSON(t, srv, http.MethodPost, "/api/v2/purchases", alice, models.PurchaseRequest{Item: "cup"})
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		for range 4 {
+			events = append(events, nextEvent(t, next))
+		}
+
+		types := []string{}
+		for _, e := range events {
+			types = append(types, e.eventType)
+		}
+		assert.Equal(t, []string{
+			models.UserEventTransferReceived,
+			models.UserEventBalanceChanged,
+			models.UserEventPurchaseCreated,
+			models.UserEventBalanceChanged,
+		}, types)
+
+		var received models.TransferReceivedEvent
+		require.NoError(t, json.Unmarshal([]byte(events[0].data), &received))
+		assert.Equal(t, "bob", received.FromUser)
+		assert.Equal(t, 10, received.Amount)
+
+		assert.JSONEq(t, `{"balance":1010}`, events[1].data)
+		assert.JSONEq(t, `{"balance":990}`, events[3].data)
+
+		for e := next(); e.eventType != "heartbeat"; e = next() {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		require.Len(t, events, 4)
+
+		next := openEvents(t, srv, alice, events[1].id)
+		assert.Equal(t, events[2], nextEvent(t, next))
+		assert.Equal(t, events[3], nextEvent(t, next))
+	})
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repository"
+	"net/http"
+	"unicode/utf8"
+)
+
+const (
+	defaultUsersLimit = 20
+	maxUsersLimit     = 100
+)
+
+// ListUsers searches active users by username prefix. Pages are ordered by username,
+// the next one is requested by passing the returned cursor as after.
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := models.NewValidator()
+
+	query := qs.Get("query")
+	after := qs.Get("after")
+	limit := h.readInt(qs, "limit", defaultUsersLimit, v)
+
+	v.Check(utf8.RuneCountInString(query) <= models.MaxUsernameLen, "query", models.NotLongerThan(models.MaxUsernameLen))
+	v.Check(limit >= 1 && limit <= maxUsersLimit, "limit", models.Between(1, maxUsersLimit))
+
+	if err := v.Err(); err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	list, err := h.service.SearchUsers(r.Context(), query, after, limit)
+	if err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	err = h.writeJSON(w, http.StatusOK, list, nil)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
+
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+	profile, err := h.service.GetProfile(r.Context(), r.PathValue("username"))
+	if err != nil {
+		// Unlike a missing transfer receiver, a missing profile is the requested resource itself.
+		if errors.Is(err, repository.ErrUserNotFound) {
+			h.errorResponse(w, r, http.StatusNotFound, models.CodeUserNotFound, err.Error())
+			return
+		}
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	err = h.writeJSON(w, http.StatusOK, profile, nil)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
+
+// UpdateMe changes the profile fields present in the body, the others are kept.
+func (h *Handler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	updateRequest := &models.UpdateProfileRequest{}
+	err := h.readJSON(w, r, updateRequest)
+	if err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	if err := updateRequest.Validate(); err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	userID := userIDFromContext(ctx)
+
+	profile, err := h.service.UpdateProfile(ctx, userID, updateRequest)
+	if err != nil {
+		h.apiErrorResponse(w, r, err)
+		return
+	}
+
+	err = h.writeJSON(w, http.StatusOK, profile, nil)
+	if err != nil {
+		h.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"merch-shop/internal/models"
+)
+
+// legacyAuthRequestError returns the error the unversioned /api/auth answered an incomplete
+// request with, its clients may rely on the code and message. Checks added later are
+// still reported with err.
+func legacyAuthRequestError(authRequest *models.AuthRequest, err error) error {
+	if authRequest.Username == "" || authRequest.Password == "" {
+		return ErrEmptyNamePassword
+	}
+
+	return err
+}
+
+// legacySendCoinRequestError is legacyAuthRequestError for /api/sendCoin, which reported
+// only the first invalid field.
+func legacySendCoinRequestError(sendCoinRequest *models.SendCoinRequest, err error) error {
+	switch {
+	case sendCoinRequest.ReceiverName == "":
+		return ErrEmptyToUser
+	case sendCoinRequest.Amount <= 0:
+		return ErrZeroOrNegativeAmount
+	}
+
+	return err
+}
@@ -0,0 +1,8 @@
+package health
+
+import "errors"
+
+var (
+	ErrShuttingDown = errors.New("shutting down")
+	ErrCheckFailed  = errors.New("health check failed")
+)
@@ -0,0 +1,87 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses reported for the whole service and each check. The reason of a failure is only
+// in the error returned by Check, probes may be reachable by anyone.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+type Checker struct {
+	timeout      time.Duration
+	mu           sync.RWMutex
+	checks       []check
+	shuttingDown atomic.Bool
+}
+
+func New(timeout time.Duration) *Checker {
+	return &Checker{
+		timeout: timeout,
+	}
+}
+
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// SetShuttingDown makes every following readiness check fail, so load balancers
+// stop routing new requests before the server starts draining.
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+func (c *Checker) ShuttingDown() bool {
+	return c.shuttingDown.Load()
+}
+
+// Check runs all registered checks within the configured timeout and returns the status of each one.
+// The error is non-nil if the service is shutting down or any check failed, it names the failed
+// checks with their errors.
+func (c *Checker) Check(ctx context.Context) (map[string]string, error) {
+	if c.ShuttingDown() {
+		return nil, ErrShuttingDown
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	results := make(map[string]string, len(c.checks))
+	var failures []string
+
+	for _, ch := range c.checks {
+		if err := ch.fn(ctx); err != nil {
+			results[ch.name] = StatusFail
+			failures = append(failures, fmt.Sprintf("%s: %v", ch.name, err))
+			continue
+		}
+		results[ch.name] = StatusOK
+	}
+
+	if len(failures) > 0 {
+		return results, fmt.Errorf("%w: %s", ErrCheckFailed, strings.Join(failures, "; "))
+	}
+
+	return results, nil
+}
@@ -0,0 +1,55 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Check(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("all checks pass", func(t *testing.T) {
+		c := New(time.Second)
+		c.Add("db", func(ctx context.Context) error { return nil })
+
+		results, err := c.Check(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"db": StatusOK}, results)
+	})
+
+	t.Run("failed check", func(t *testing.T) {
+		c := New(time.Second)
+		c.Add("db", func(ctx context.Context) error { return nil })
+		c.Add("migrations", func(ctx context.Context) error { return errors.New("version 1, want 2") })
+
+		results, err := c.Check(ctx)
+		assert.ErrorIs(t, err, ErrCheckFailed)
+		assert.Equal(t, StatusOK, results["db"])
+		assert.Equal(t, StatusFail, results["migrations"])
+		assert.EqualError(t, err, "health check failed: migrations: version 1, want 2")
+	})
+
+	t.Run("check times out", func(t *testing.T) {
+		c := New(10 * time.Millisecond)
+		c.Add("db", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		_, err := c.Check(ctx)
+		assert.ErrorIs(t, err, ErrCheckFailed)
+	})
+
+	t.Run("shutting down", func(t *testing.T) {
+		c := New(time.Second)
+		c.Add("db", func(ctx context.Context) error { return nil })
+		c.SetShuttingDown()
+
+		_, err := c.Check(ctx)
+		assert.ErrorIs(t, err, ErrShuttingDown)
+	})
+}
@@ -0,0 +1,65 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"merch-shop/internal/config"
+	"merch-shop/internal/requestid"
+	"strings"
+)
+
+func New(w io.Writer, cfg *config.Config) (*slog.Logger, error) {
+	level, err := parseLevel(cfg.Log.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Log.Format) {
+	case "", config.LogFormatText:
+		handler = slog.NewTextHandler(w, opts)
+	case config.LogFormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, config.ErrInvalidLogFormat
+	}
+
+	return slog.New(&contextHandler{handler}), nil
+}
+
+// contextHandler adds the request ID from the context to every record,
+// so any line logged with a request context can be traced back to it.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestid.FromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h.Handler.WithGroup(name)}
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, config.ErrInvalidLogLevel
+	}
+
+	return level, nil
+}
@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"merch-shop/internal/config"
+	"merch-shop/internal/requestid"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_New(t *testing.T) {
+	tests := []struct {
+		name      string
+		level     string
+		format    string
+		wantErr   error
+		wantDebug bool
+		wantJSON  bool
+	}{
+		{name: "defaults", wantDebug: false},
+		{name: "debug level", level: "debug", wantDebug: true},
+		{name: "warn level", level: "WARN", wantDebug: false},
+		{name: "text format", format: config.LogFormatText},
+		{name: "json format", format: config.LogFormatJSON, wantJSON: true},
+		{name: "format is case insensitive", format: "JSON", wantJSON: true},
+		{name: "unknown level", level: "verbose", wantErr: config.ErrInvalidLogLevel},
+		{name: "unknown format", format: "xml", wantErr: config.ErrInvalidLogFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Log.Level = tt.level
+			cfg.Log.Format = tt.format
+
+			var buf bytes.Buffer
+			log, err := New(&buf, cfg)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, log)
+				return
+			}
+			require.NoError(t, err)
+
+			ctx := context.Background()
+			assert.Equal(t, tt.wantDebug, log.Enabled(ctx, -4), "debug enabled")
+
+			log.Error("boom", "user_id", 7)
+			line := strings.TrimSpace(buf.String())
+
+			if tt.wantJSON {
+				var record map[string]any
+				require.NoError(t, json.Unmarshal([]byte(line), &record))
+				assert.Equal(t, "ERROR", record["level"])
+				assert.Equal(t, "boom", record["msg"])
+				assert.EqualValues(t, 7, record["user_id"])
+			} else {
+				assert.Contains(t, line, "level=ERROR")
+				assert.Contains(t, line, "msg=boom")
+				assert.Contains(t, line, "user_id=7")
+			}
+		})
+	}
+}
+
+func Test_New_RequestID(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Log.Format = config.LogFormatJSON
+
+	var buf bytes.Buffer
+	log, err := New(&buf, cfg)
+	require.NoError(t, err)
+
+	ctx := requestid.NewContext(context.Background(), "req-1")
+
+	log.InfoContext(ctx, "with request")
+	log.With("component", "hub").WithGroup("event").InfoContext(ctx, "derived", "id", 3)
+	log.Info("without request")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+
+	records := make([]map[string]any, len(lines))
+	for i, line := range lines {
+		require.NoError(t, json.Unmarshal([]byte(line), &records[i]))
+	}
+
+	assert.Equal(t, "req-1", records[0]["request_id"])
+
+	assert.Equal(t, "hub", records[1]["component"])
+	assert.Equal(t, map[string]any{"id": float64(3), "request_id": "req-1"}, records[1]["event"],
+		"derived loggers keep adding the request ID")
+
+	assert.NotContains(t, records[2], "request_id")
+}
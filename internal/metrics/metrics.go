@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "merch_shop"
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests     *prometheus.CounterVec
+	httpDuration     *prometheus.HistogramVec
+	coinsTransferred prometheus.Counter
+	purchases        *prometheus.CounterVec
+	failedLogins     prometheus.Counter
+	notEnoughCoins   *prometheus.CounterVec
+	webhookAttempts  *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		coinsTransferred: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "coins_transferred_total",
+			Help:      "Number of coins sent between users.",
+		}),
+		purchases: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "purchases_total",
+			Help:      "Number of purchased items by item type.",
+		}, []string{"item"}),
+		failedLogins: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "failed_logins_total",
+			Help:      "Number of login attempts rejected because of a wrong password.",
+		}),
+		notEnoughCoins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "not_enough_coins_total",
+			Help:      "Number of operations rejected because of insufficient balance.",
+		}, []string{"operation"}),
+		webhookAttempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhook_attempts_total",
+			Help:      "Number of webhook delivery attempts by endpoint and result: delivered, failed or dead.",
+		}, []string{"endpoint", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.coinsTransferred,
+		m.purchases,
+		m.failedLogins,
+		m.notEnoughCoins,
+		m.webhookAttempts,
+	)
+
+	return m
+}
+
+func (m *Metrics) RegisterDB(db *sql.DB, dbName string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+func (m *Metrics) CoinsTransferred(amount int) {
+	m.coinsTransferred.Add(float64(amount))
+}
+
+func (m *Metrics) ItemPurchased(item string) {
+	m.purchases.WithLabelValues(item).Inc()
+}
+
+func (m *Metrics) FailedLogin() {
+	m.failedLogins.Inc()
+}
+
+func (m *Metrics) NotEnoughCoins(operation string) {
+	m.notEnoughCoins.WithLabelValues(operation).Inc()
+}
+
+func (m *Metrics) WebhookAttempt(endpoint, result string) {
+	m.webhookAttempts.WithLabelValues(endpoint, result).Inc()
+}
@@ -0,0 +1,12 @@
+package migrate
+
+import "errors"
+
+var (
+	ErrInvalidFileName   = errors.New("invalid migration file name")
+	ErrDuplicateVersion  = errors.New("duplicate migration version")
+	ErrMissingUp         = errors.New("missing up migration")
+	ErrNoMigrations      = errors.New("no migrations found")
+	ErrNothingToRollback = errors.New("no applied migrations to roll back")
+	ErrVersionMismatch   = errors.New("schema version mismatch")
+)
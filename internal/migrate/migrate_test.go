@@ -0,0 +1,97 @@
+package migrate
+
+import (
+	"merch-shop/migrations"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_load(t *testing.T) {
+	tests := []struct {
+		name         string
+		fsys         fstest.MapFS
+		wantErr      error
+		wantVersions []int64
+	}{
+		{
+			name: "sorted by version",
+			fsys: fstest.MapFS{
+				"0002_items.up.sql":   {Data: []byte("CREATE TABLE b();")},
+				"0002_items.down.sql": {Data: []byte("DROP TABLE b;")},
+				"0001_init.up.sql":    {Data: []byte("CREATE TABLE a();")},
+				"0010_more.up.sql":    {Data: []byte("CREATE TABLE c();")},
+				"README.md":           {Data: []byte("not a migration")},
+			},
+			wantVersions: []int64{1, 2, 10},
+		},
+		{
+			name:    "no migrations",
+			fsys:    fstest.MapFS{},
+			wantErr: ErrNoMigrations,
+		},
+		{
+			name: "invalid name",
+			fsys: fstest.MapFS{
+				"init.up.sql": {Data: []byte("CREATE TABLE a();")},
+			},
+			wantErr: ErrInvalidFileName,
+		},
+		{
+			name: "duplicate version",
+			fsys: fstest.MapFS{
+				"0001_init.up.sql":  {Data: []byte("CREATE TABLE a();")},
+				"0001_other.up.sql": {Data: []byte("CREATE TABLE b();")},
+			},
+			wantErr: ErrDuplicateVersion,
+		},
+		{
+			name: "down without up",
+			fsys: fstest.MapFS{
+				"0001_init.down.sql": {Data: []byte("DROP TABLE a;")},
+			},
+			wantErr: ErrMissingUp,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := load(tt.fsys)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			versions := []int64{}
+			for _, m := range got {
+				versions = append(versions, m.Version)
+			}
+			assert.Equal(t, tt.wantVersions, versions)
+		})
+	}
+}
+
+func Test_embeddedMigrations(t *testing.T) {
+	postgres, err := load(migrations.Postgres)
+	assert.NoError(t, err)
+
+	sqlite, err := load(migrations.SQLite)
+	assert.NoError(t, err)
+
+	assert.Equal(t, len(postgres), len(sqlite), "dialects should have the same migrations")
+
+	for i, m := range postgres {
+		assert.NotEmpty(t, m.Up, "version %d", m.Version)
+		assert.NotEmpty(t, m.Down, "version %d", m.Version)
+
+		if i < len(sqlite) {
+			assert.Equal(t, m.Version, sqlite[i].Version)
+			assert.Equal(t, m.Name, sqlite[i].Name)
+			assert.NotEmpty(t, sqlite[i].Up, "sqlite version %d", m.Version)
+			assert.NotEmpty(t, sqlite[i].Down, "sqlite version %d", m.Version)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package models
+
+// Error codes returned in ErrorResponse.Code. Unlike the messages they are part of
+// the API contract and never change.
+const (
+	CodeInvalidRequest       = "INVALID_REQUEST"
+	CodeValidationFailed     = "VALIDATION_FAILED"
+	CodeUnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+	CodeBodyTooLarge         = "BODY_TOO_LARGE"
+	CodeEmptyCredentials     = "EMPTY_CREDENTIALS"
+	CodeEmptyReceiver        = "EMPTY_RECEIVER"
+	CodeInvalidAmount        = "INVALID_AMOUNT"
+	CodeEmptyItem            = "EMPTY_ITEM"
+	CodePasswordTooLong      = "PASSWORD_TOO_LONG"
+	CodeWrongPassword        = "WRONG_PASSWORD"
+	CodeMissingToken         = "MISSING_TOKEN"
+	CodeInvalidAuthHeader    = "INVALID_AUTH_HEADER"
+	CodeInvalidToken         = "INVALID_TOKEN"
+	CodeTokenExpired         = "TOKEN_EXPIRED"
+	CodeForbidden            = "FORBIDDEN"
+	CodeUserDeactivated      = "USER_DEACTIVATED"
+	CodeUserNotFound         = "USER_NOT_FOUND"
+	CodeReceiverNotFound     = "RECEIVER_NOT_FOUND"
+	CodeItemNotFound         = "ITEM_NOT_FOUND"
+	CodeNotFound             = "NOT_FOUND"
+	CodeAlreadyExists        = "ALREADY_EXISTS"
+	CodeInsufficientFunds    = "INSUFFICIENT_FUNDS"
+	CodeBalanceLimit         = "BALANCE_LIMIT"
+	CodeSelfTransfer         = "SELF_TRANSFER"
+	CodeInternalError        = "INTERNAL_ERROR"
+)
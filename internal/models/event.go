@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event types, sent to webhooks in Event.Type.
+const (
+	EventUserRegistered  = "user.registered"
+	EventTransferCreated = "transfer.created"
+	EventPurchaseCreated = "purchase.created"
+	EventCoinsGranted    = "coins.granted"
+)
+
+var EventTypes = []string{EventUserRegistered, EventTransferCreated, EventPurchaseCreated, EventCoinsGranted}
+
+// Event is the body of a webhook request. ID is unique per event and stays the same
+// across retries and replays, so receivers can use it to drop duplicates.
+type Event struct {
+	ID        int             `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type UserRegisteredEvent struct {
+	Username string    `json:"username"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+// TransferEvent is a completed transfer. Unlike Transfer it has no balance,
+// which is private to the sender.
+type TransferEvent struct {
+	ID        int       `json:"id"`
+	FromUser  string    `json:"fromUser"`
+	ToUser    string    `json:"toUser"`
+	Amount    int       `json:"amount"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type PurchaseEvent struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	Item      string    `json:"item"`
+	Price     int       `json:"price"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// GrantEvent is coins an admin added to the balance of a user.
+type GrantEvent struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	Amount    int       `json:"amount"`
+	CreatedAt time.Time `json:"createdAt"`
+}
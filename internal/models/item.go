@@ -3,5 +3,9 @@ package models
 type Item struct {
 	ID    int    `json:"-"`
 	Name  string `json:"type"`
-	Price int    `json:"-"`
+	Price int    `json:"price"`
+}
+
+type ItemList struct {
+	Items []*Item `json:"items"`
 }
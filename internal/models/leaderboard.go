@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Leaderboard modes.
+const (
+	LeaderboardReceived = "received"
+	LeaderboardSent     = "sent"
+	LeaderboardItems    = "items"
+)
+
+// Leaderboard periods, counted back from now.
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+	PeriodAll   = "all"
+)
+
+type LeaderboardEntry struct {
+	// Rank is shared by users with equal scores, the next rank skips accordingly (1, 2, 2, 4).
+	Rank        int    `json:"rank"`
+	Username    string `json:"username"`
+	DisplayName string `json:"displayName,omitempty"`
+	// Score is the number of coins received or sent, or the number of items owned.
+	Score int `json:"score"`
+}
+
+type Leaderboard struct {
+	Mode   string `json:"mode"`
+	Period string `json:"period"`
+	// Since is the start of the period, omitted when all time is counted.
+	Since   *time.Time          `json:"since,omitempty"`
+	Entries []*LeaderboardEntry `json:"entries"`
+}
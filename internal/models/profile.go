@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// UserProfile is the public part of a user account.
+type UserProfile struct {
+	Username    string    `json:"username"`
+	DisplayName string    `json:"displayName,omitempty"`
+	AvatarURL   string    `json:"avatarUrl,omitempty"`
+	JoinedAt    time.Time `json:"joinedAt"`
+	// LeaderboardOptOut is only filled in the user's own profile.
+	LeaderboardOptOut *bool `json:"leaderboardOptOut,omitempty"`
+}
+
+// UserList is a page of users ordered by username. Next is the cursor of the
+// following page, empty on the last one.
+type UserList struct {
+	Users []*UserProfile `json:"users"`
+	Next  string         `json:"next,omitempty"`
+}
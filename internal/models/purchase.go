@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Purchase is an order for a single item.
+type Purchase struct {
+	ID    int    `json:"id"`
+	Item  string `json:"item"`
+	Price int    `json:"price"`
+	// Quantity is how many of the item the user owns after the purchase.
+	Quantity  int       `json:"quantity"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Balance is the user's balance after the purchase.
+	Balance int `json:"balance"`
+}
@@ -5,7 +5,27 @@ type AuthRequest struct {
 	Password string `json:"password"`
 }
 
+type PurchaseRequest struct {
+	Item string `json:"item"`
+}
+
 type SendCoinRequest struct {
 	ReceiverName string `json:"toUser"`
 	Amount       int    `json:"amount"`
 }
+
+// UpdateProfileRequest changes the fields that are set, an empty string clears a field.
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"displayName"`
+	AvatarURL   *string `json:"avatarUrl"`
+	// LeaderboardOptOut hides the user from leaderboards.
+	LeaderboardOptOut *bool `json:"leaderboardOptOut"`
+}
+
+type GrantCoinsRequest struct {
+	Amount int `json:"amount"`
+}
+
+type SetItemPriceRequest struct {
+	Price int `json:"price"`
+}
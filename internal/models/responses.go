@@ -2,6 +2,16 @@ package models
 
 type ErrorResponse struct {
 	Errors string `json:"errors"`
+	Code   string `json:"code"`
+	// Fields maps each invalid request field to its problem when Code is VALIDATION_FAILED.
+	Fields map[string]string `json:"fields,omitempty"`
+	// RequestID identifies the request in the service logs.
+	RequestID string `json:"requestId,omitempty"`
+}
+
+type HealthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
 }
 
 type AuthResponse struct {
@@ -22,6 +32,8 @@ type InventoryItem struct {
 type CoinHistory struct {
 	Received []*CoinTransaction `json:"received"`
 	Sent     []*CoinTransaction `json:"sent"`
+	// Granted is the coins added by an admin, only their Amount is set.
+	Granted []*CoinTransaction `json:"granted"`
 }
 
 type CoinTransaction struct {
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Transfer is a completed coin transfer as seen by the sender.
+type Transfer struct {
+	ID        int       `json:"id"`
+	FromUser  string    `json:"fromUser"`
+	ToUser    string    `json:"toUser"`
+	Amount    int       `json:"amount"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Balance is the sender's balance after the transfer.
+	Balance int `json:"balance"`
+}
+
+// CoinGrant is coins an admin added to the balance of a user.
+type CoinGrant struct {
+	Username string `json:"username"`
+	Amount   int    `json:"amount"`
+	// Balance is the user's balance after the grant.
+	Balance int `json:"balance"`
+}
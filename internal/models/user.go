@@ -7,4 +7,5 @@ type User struct {
 	Username     string    `json:"username"`
 	PasswordHash string    `json:"password"`
 	CreatedAt    time.Time `json:"-"`
+	IsActive     bool      `json:"-"`
 }
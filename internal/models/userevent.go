@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// User event types, pushed to the live stream of the user in UserEvent.Type.
+const (
+	UserEventBalanceChanged   = "balance.changed"
+	UserEventTransferReceived = "transfer.received"
+	UserEventPurchaseCreated  = "purchase.created"
+)
+
+// UserEvent is a change pushed to the live stream of the user it belongs to. The events
+// of a user are written while their balance is locked, so their IDs grow in commit order
+// and a client resumes a dropped stream from the last ID it saw.
+type UserEvent struct {
+	ID        int             `json:"id"`
+	UserID    int             `json:"userId"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type BalanceChangedEvent struct {
+	Balance int `json:"balance"`
+}
+
+type TransferReceivedEvent struct {
+	ID        int       `json:"id"`
+	FromUser  string    `json:"fromUser"`
+	Amount    int       `json:"amount"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits of the request fields, shared by the HTTP and gRPC APIs.
+const (
+	MaxUsernameLen    = 50
+	MaxDisplayNameLen = 50
+	MaxAvatarURLLen   = 2048
+)
+
+var ErrValidation = errors.New("validation failed")
+
+// ValidationError holds a message for every invalid field of a request. Fields are named
+// as in the JSON requests.
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for field, message := range e.Fields {
+		messages = append(messages, fmt.Sprintf("%s: %s", field, message))
+	}
+	sort.Strings(messages)
+
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// Validator collects field errors, so a request with several problems is
+// rejected with all of them at once.
+type Validator struct {
+	errors map[string]string
+}
+
+func NewValidator() *Validator {
+	return &Validator{errors: map[string]string{}}
+}
+
+// Check records message for field if ok is false. Only the first failed check of a field is kept.
+func (v *Validator) Check(ok bool, field, message string) {
+	if ok {
+		return
+	}
+
+	if _, exists := v.errors[field]; !exists {
+		v.errors[field] = message
+	}
+}
+
+// Err returns a *ValidationError with the failed checks, or nil if there are none.
+func (v *Validator) Err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Fields: v.errors}
+}
+
+// NotLongerThan and Between format the messages of limit checks from the limits themselves.
+func NotLongerThan(n int) string {
+	return fmt.Sprintf("must not be longer than %d characters", n)
+}
+
+func Between(lo, hi int) string {
+	return fmt.Sprintf("must be between %d and %d", lo, hi)
+}
+
+func (r *AuthRequest) Validate() error {
+	v := NewValidator()
+
+	v.Check(r.Username != "", "username", "must be provided")
+	v.Check(utf8.RuneCountInString(r.Username) <= MaxUsernameLen, "username", NotLongerThan(MaxUsernameLen))
+	v.Check(r.Password != "", "password", "must be provided")
+
+	return v.Err()
+}
+
+func (r *PurchaseRequest) Validate() error {
+	v := NewValidator()
+
+	v.Check(r.Item != "", "item", "must be provided")
+
+	return v.Err()
+}
+
+func (r *SendCoinRequest) Validate() error {
+	v := NewValidator()
+
+	v.Check(r.ReceiverName != "", "toUser", "must be provided")
+	v.Check(r.Amount > 0, "amount", "must be positive")
+
+	return v.Err()
+}
+
+func (r *UpdateProfileRequest) Validate() error {
+	v := NewValidator()
+
+	if r.DisplayName != nil {
+		v.Check(utf8.RuneCountInString(*r.DisplayName) <= MaxDisplayNameLen, "displayName", NotLongerThan(MaxDisplayNameLen))
+	}
+
+	// An empty avatar URL removes the avatar.
+	if avatarURL := r.AvatarURL; avatarURL != nil && *avatarURL != "" {
+		v.Check(len(*avatarURL) <= MaxAvatarURLLen, "avatarUrl", NotLongerThan(MaxAvatarURLLen))
+		v.Check(isHTTPURL(*avatarURL), "avatarUrl", "must be an absolute http or https URL")
+	}
+
+	return v.Err()
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AuthRequest_Validate(t *testing.T) {
+	err := (&AuthRequest{Username: "alice", Password: "password"}).Validate()
+	assert.NoError(t, err)
+
+	err = (&AuthRequest{Username: strings.Repeat("a", MaxUsernameLen+1)}).Validate()
+	require.ErrorIs(t, err, ErrValidation)
+
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, map[string]string{
+		"username": "must not be longer than 50 characters",
+		"password": "must be provided",
+	}, validationErr.Fields)
+	assert.Equal(t, "password: must be provided; username: must not be longer than 50 characters", err.Error())
+}
+
+func Test_SendCoinRequest_Validate(t *testing.T) {
+	err := (&SendCoinRequest{ReceiverName: "bob", Amount: 1}).Validate()
+	assert.NoError(t, err)
+
+	err = (&SendCoinRequest{Amount: -5}).Validate()
+
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, map[string]string{
+		"toUser": "must be provided",
+		"amount": "must be positive",
+	}, validationErr.Fields)
+}
+
+func Test_UpdateProfileRequest_Validate(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+
+	err := (&UpdateProfileRequest{}).Validate()
+	assert.NoError(t, err)
+
+	err = (&UpdateProfileRequest{DisplayName: ptr(""), AvatarURL: ptr("")}).Validate()
+	assert.NoError(t, err, "empty values clear the fields")
+
+	err = (&UpdateProfileRequest{
+		DisplayName: ptr("Алиса"),
+		AvatarURL:   ptr("https://example.com/a.png"),
+	}).Validate()
+	assert.NoError(t, err)
+
+	err = (&UpdateProfileRequest{
+		DisplayName: ptr(strings.Repeat("я", MaxDisplayNameLen+1)),
+		AvatarURL:   ptr("javascript:alert(1)"),
+	}).Validate()
+
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, map[string]string{
+		"displayName": "must not be longer than 50 characters",
+		"avatarUrl":   "must be an absolute http or https URL",
+	}, validationErr.Fields)
+}
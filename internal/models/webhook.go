@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Webhook delivery statuses. A delivery is pending until the endpoint accepts it,
+// or until it runs out of attempts and becomes dead.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// WebhookDelivery is a single event sent to a single endpoint.
+type WebhookDelivery struct {
+	ID        int    `json:"id"`
+	EventID   int    `json:"eventId"`
+	EventType string `json:"eventType"`
+	Endpoint  string `json:"endpoint"`
+	Status    string `json:"status"`
+	Attempts  int    `json:"attempts"`
+	// LastError describes why the last attempt failed.
+	LastError     string     `json:"lastError,omitempty"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	DeliveredAt   *time.Time `json:"deliveredAt,omitempty"`
+	// Payload is the JSON encoded Event sent as the request body.
+	Payload []byte `json:"-"`
+	// LeaseID identifies the claim that returned the delivery. MarkDelivered and MarkFailed
+	// need it, so only the latest claim can record the result of an attempt.
+	LeaseID int `json:"-"`
+}
+
+// WebhookDeliveryList is a page of deliveries ordered by ID. Next is the cursor
+// of the following page, zero on the last one.
+type WebhookDeliveryList struct {
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+	Next       int                `json:"next,omitempty"`
+}
@@ -1,8 +1,26 @@
 package repository
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrRecordNotFound  = errors.New("record not found")
+	ErrNotEnoughCoins  = errors.New("not enough coins")
+	ErrDuplicateRecord = errors.New("record already exists")
+	// ErrBalanceLimit is returned when a grant or transfer would take a balance past maxBalance.
+	ErrBalanceLimit = errors.New("balance would exceed the limit")
+	// ErrLeaseLost is returned when the result of a webhook delivery attempt comes after the
+	// delivery was claimed again or stopped being pending.
+	ErrLeaseLost = errors.New("webhook delivery lease was lost")
+)
 
+// Not found errors for a specific record. They all match ErrRecordNotFound.
 var (
-	ErrRecordNotFound = errors.New("record not found")
-	ErrNotEnoughCoins = errors.New("not enough coins")
+	ErrUserNotFound     = fmt.Errorf("user: %w", ErrRecordNotFound)
+	ErrSenderNotFound   = fmt.Errorf("sender user: %w", ErrRecordNotFound)
+	ErrReceiverNotFound = fmt.Errorf("receiver user: %w", ErrRecordNotFound)
+	ErrItemNotFound     = fmt.Errorf("item: %w", ErrRecordNotFound)
+	ErrDeliveryNotFound = fmt.Errorf("dead webhook delivery: %w", ErrRecordNotFound)
 )
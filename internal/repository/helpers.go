@@ -1,8 +1,1403 @@
 package repository
 
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"merch-shop/internal/models"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// sqlRepository implements Repository on database/sql. Its SQL runs on both Postgres and
+// SQLite, dialect fills in the parts where they differ.
+type sqlRepository struct {
+	DB      *sql.DB
+	dialect *dialect
+}
+
+// dialect describes how a database differs from the SQL of sqlRepository.
+type dialect struct {
+	// name prefixes span names, system tags the spans.
+	name   string
+	system attribute.KeyValue
+
+	// writeIsolation is used by transactions that write, snapshotIsolation by reads that
+	// must see a single snapshot across several queries.
+	writeIsolation    sql.IsolationLevel
+	snapshotIsolation sql.IsolationLevel
+
+	// lockBalances ends the queries reading the coins rows a transaction is going to
+	// change, so concurrent changes of the same balance run one after another.
+	lockBalances string
+	// skipLocked ends the queries taking outbox events and deliveries to work on, so
+	// replicas working at once don't take the same rows.
+	skipLocked string
+
+	// collate follows usernames that are compared or ordered, so they sort byte by byte.
+	collate string
+	// matchPrefix returns the condition matching usernames that start with prefix, using
+	// $1 for the returned argument.
+	matchPrefix func(prefix string) (string, any)
+
+	// nowPlus returns an expression adding the duration bound to placeholder to the
+	// current time. duration formats the duration as that argument.
+	nowPlus  func(placeholder string) string
+	duration func(d time.Duration) any
+	// timestamp formats t to compare with timestamp columns.
+	timestamp func(t time.Time) any
+
+	isUniqueViolation func(err error) bool
+	// publishUserEvents writes events within tx and announces them to the live streams.
+	publishUserEvents func(ctx context.Context, tx *sql.Tx, events []userEvent) error
+}
+
+func (r *sqlRepository) GetByUsername(ctx context.Context, username string) (_ *models.User, err error) {
+	ctx, span := r.startSpan(ctx, "GetByUsername")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	    SELECT id, password_hash, created_at
+	    FROM active_users
+	    WHERE username = $1`
+
+	user := &models.User{
+		Username: username,
+		IsActive: true,
+	}
+
+	traceQuery(ctx, query)
+	err = r.DB.QueryRowContext(ctx, query, username).Scan(
+		&user.ID,
+		&user.PasswordHash,
+		&user.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (r *sqlRepository) GetCredentials(ctx context.Context, username string) (_ *models.User, err error) {
+	ctx, span := r.startSpan(ctx, "GetCredentials")
+	defer func() { endSpan(span, err) }()
+
+	// A NULL is_active counts as inactive, as in active_users.
+	query := `
+	    SELECT id, password_hash, created_at, COALESCE(is_active, FALSE)
+	    FROM users
+	    WHERE username = $1`
+
+	user := &models.User{
+		Username: username,
+	}
+
+	traceQuery(ctx, query)
+	err = r.DB.QueryRowContext(ctx, query, username).Scan(
+		&user.ID,
+		&user.PasswordHash,
+		&user.CreatedAt,
+		&user.IsActive,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (r *sqlRepository) Add(ctx context.Context, u *models.User) (err error) {
+	ctx, span := r.startSpan(ctx, "Add")
+	defer func() { endSpan(span, err) }()
+
+	return withTx(ctx, r.DB, r.dialect.writeIsolation, func(tx *sql.Tx) error {
+		query := `
+		    INSERT INTO users(username, password_hash)
+		    VALUES ($1, $2)
+		    RETURNING id, created_at`
+
+		args := []any{u.Username, u.PasswordHash}
+
+		traceQuery(ctx, query)
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&u.ID, &u.CreatedAt)
+		if err != nil {
+			if r.dialect.isUniqueViolation(err) {
+				return fmt.Errorf("user: %w", ErrDuplicateRecord)
+			}
+			return err
+		}
+
+		query = `
+		    INSERT INTO coins(user_id)
+		    VALUES ($1)`
+
+		traceQuery(ctx, query)
+		_, err = tx.ExecContext(ctx, query, u.ID)
+		if err != nil {
+			return err
+		}
+
+		return insertEvent(ctx, tx, models.EventUserRegistered, models.UserRegisteredEvent{
+			Username: u.Username,
+			JoinedAt: u.CreatedAt,
+		})
+	})
+}
+
+func (r *sqlRepository) DeactivateUser(ctx context.Context, userID int) (err error) {
+	ctx, span := r.startSpan(ctx, "DeactivateUser")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	    UPDATE users
+	    SET is_active = FALSE
+	    WHERE id = $1`
+
+	traceQuery(ctx, query)
+	result, err := r.DB.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+func (r *sqlRepository) GrantCoins(ctx context.Context, userID, amount int) (_ int, err error) {
+	ctx, span := r.startSpan(ctx, "GrantCoins")
+	defer func() { endSpan(span, err) }()
+
+	var balance int
+
+	err = withTx(ctx, r.DB, r.dialect.writeIsolation, func(tx *sql.Tx) error {
+		query := `
+		    SELECT balance
+		    FROM coins
+		    JOIN active_users ON coins.user_id = active_users.id
+		    WHERE user_id = $1
+		    ` + r.dialect.lockBalances
+
+		traceQuery(ctx, query)
+		err := tx.QueryRowContext(ctx, query, userID).Scan(&balance)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		if err := checkCredit(balance, amount); err != nil {
+			return err
+		}
+
+		query = `
+		    UPDATE coins
+		    SET balance = balance + $2
+		    WHERE user_id = $1
+		    RETURNING balance`
+
+		args := []any{userID, amount}
+
+		traceQuery(ctx, query)
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&balance)
+		if err != nil {
+			return err
+		}
+
+		if err := insertGrant(ctx, tx, userID, amount); err != nil {
+			return err
+		}
+
+		return r.dialect.publishUserEvents(ctx, tx, balanceUserEvents(userID, balance))
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+func (r *sqlRepository) ListItems(ctx context.Context) (_ []*models.Item, err error) {
+	ctx, span := r.startSpan(ctx, "ListItems")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	    SELECT id, type, price
+	    FROM item
+	    ORDER BY type`
+
+	traceQuery(ctx, query)
+	return queryItems(ctx, r.DB, query)
+}
+
+func (r *sqlRepository) SetItemPrice(ctx context.Context, itemName string, price int) (_ *models.Item, err error) {
+	ctx, span := r.startSpan(ctx, "SetItemPrice")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	    INSERT INTO item(type, price)
+	    VALUES ($1, $2)
+	    ON CONFLICT (type)
+	    DO UPDATE SET price = excluded.price
+	    RETURNING id`
+
+	item := &models.Item{
+		Name:  itemName,
+		Price: price,
+	}
+
+	traceQuery(ctx, query)
+	err = r.DB.QueryRowContext(ctx, query, itemName, price).Scan(&item.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (r *sqlRepository) BuyItem(ctx context.Context, userID int, itemName string) (_ *models.Purchase, err error) {
+	ctx, span := r.startSpan(ctx, "BuyItem")
+	defer func() { endSpan(span, err) }()
+
+	var purchase *models.Purchase
+
+	err = withTx(ctx, r.DB, r.dialect.writeIsolation, func(tx *sql.Tx) error {
+		query := `
+		     SELECT username, balance
+		     FROM coins
+		     JOIN active_users ON coins.user_id = active_users.id
+		     WHERE user_id = $1
+		     ` + r.dialect.lockBalances
+
+		var username string
+		var balance int
+		traceQuery(ctx, query)
+		err := tx.QueryRowContext(ctx, query, userID).Scan(&username, &balance)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		query = `
+		    SELECT id, price
+		    FROM item
+		    WHERE type = $1`
+
+		var item models.Item
+
+		traceQuery(ctx, query)
+		err = tx.QueryRowContext(ctx, query, itemName).Scan(
+			&item.ID,
+			&item.Price,
+		)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrItemNotFound
+			}
+			return err
+		}
+
+		if err := checkBalance(balance, item.Price); err != nil {
+			return err
+		}
+
+		purchase = &models.Purchase{
+			Item:  itemName,
+			Price: item.Price,
+		}
+
+		query = `
+		    INSERT INTO inventory(user_id, item_id)
+		    VALUES ($1, $2)
+		    ON CONFLICT (user_id, item_id)
+		    DO UPDATE SET quantity = inventory.quantity + 1
+		    RETURNING quantity`
+
+		args := []any{userID, item.ID}
+
+		traceQuery(ctx, query)
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&purchase.Quantity)
+		if err != nil {
+			return err
+		}
+
+		query = `
+		    INSERT INTO purchase(user_id, item_id, price)
+		    VALUES ($1, $2, $3)
+		    RETURNING id, created_at`
+
+		args = []any{userID, item.ID, item.Price}
+
+		traceQuery(ctx, query)
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&purchase.ID, &purchase.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		query = `
+		    UPDATE coins
+		    SET balance = balance - $2
+		    WHERE user_id = $1
+		    RETURNING balance`
+
+		args = []any{userID, item.Price}
+
+		traceQuery(ctx, query)
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&purchase.Balance)
+		if err != nil {
+			return err
+		}
+
+		err = insertEvent(ctx, tx, models.EventPurchaseCreated, models.PurchaseEvent{
+			ID:        purchase.ID,
+			Username:  username,
+			Item:      purchase.Item,
+			Price:     purchase.Price,
+			CreatedAt: purchase.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		return r.dialect.publishUserEvents(ctx, tx, purchaseUserEvents(userID, purchase))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return purchase, nil
+}
+
+func (r *sqlRepository) SendCoin(ctx context.Context, senderID, receiverID int, amount int) (_ *models.Transfer, err error) {
+	ctx, span := r.startSpan(ctx, "SendCoin")
+	defer func() { endSpan(span, err) }()
+
+	var transfer *models.Transfer
+
+	err = withTx(ctx, r.DB, r.dialect.writeIsolation, func(tx *sql.Tx) error {
+		// On Postgres both rows are locked in user_id order, so concurrent transfers
+		// between the same pair of users wait for each other instead of deadlocking.
+		query := `
+		     SELECT user_id, username, balance
+		     FROM coins
+		     JOIN active_users ON coins.user_id = active_users.id
+		     WHERE user_id IN ($1, $2)
+		     ORDER BY user_id
+		     ` + r.dialect.lockBalances
+
+		traceQuery(ctx, query)
+		accounts, err := queryAccounts(ctx, tx, query, senderID, receiverID)
+		if err != nil {
+			return err
+		}
+
+		sender, ok := accounts[senderID]
+		if !ok {
+			return ErrSenderNotFound
+		}
+
+		receiver, ok := accounts[receiverID]
+		if !ok {
+			return ErrReceiverNotFound
+		}
+
+		if err := checkBalance(sender.balance, amount); err != nil {
+			return err
+		}
+		if err := checkCredit(receiver.balance, amount); err != nil {
+			return err
+		}
+
+		transfer = &models.Transfer{
+			FromUser: sender.username,
+			ToUser:   receiver.username,
+			Amount:   amount,
+			Balance:  sender.balance - amount,
+		}
+
+		query = `
+		     INSERT INTO "transaction"(sender_id, receiver_id, amount)
+		     VALUES ($1, $2, $3)
+		     RETURNING id, created_at`
+
+		args := []any{senderID, receiverID, amount}
+
+		traceQuery(ctx, query)
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&transfer.ID, &transfer.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		query = `
+		    UPDATE coins
+		    SET balance = CASE
+		    WHEN user_id = $1 THEN balance - $3
+		    WHEN user_id = $2 THEN balance + $3
+		    ELSE balance
+		    END
+		    WHERE user_id in ($1, $2)`
+
+		traceQuery(ctx, query)
+		_, err = tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		err = insertEvent(ctx, tx, models.EventTransferCreated, models.TransferEvent{
+			ID:        transfer.ID,
+			FromUser:  transfer.FromUser,
+			ToUser:    transfer.ToUser,
+			Amount:    transfer.Amount,
+			CreatedAt: transfer.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		return r.dialect.publishUserEvents(ctx, tx, transferUserEvents(senderID, receiverID, receiver.balance+amount, transfer))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+func (r *sqlRepository) GetBalance(ctx context.Context, userID int) (_ int, err error) {
+	ctx, span := r.startSpan(ctx, "GetBalance")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	    SELECT balance
+	    FROM coins
+	    JOIN active_users ON coins.user_id = active_users.id
+	    WHERE user_id = $1`
+
+	var balance int
+
+	traceQuery(ctx, query)
+	err = r.DB.QueryRowContext(ctx, query, userID).Scan(&balance)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrUserNotFound
+		}
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+func (r *sqlRepository) GetInventory(ctx context.Context, userID int) (_ []*models.InventoryItem, err error) {
+	ctx, span := r.startSpan(ctx, "GetInventory")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	    SELECT it.type, i.quantity
+	    FROM inventory AS i
+	    JOIN item AS it ON i.item_id = it.id
+	    WHERE user_id = $1`
+
+	traceQuery(ctx, query)
+	rows, err := r.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inventory := []*models.InventoryItem{}
+
+	for rows.Next() {
+		var item models.InventoryItem
+		err := rows.Scan(
+			&item.Type,
+			&item.Quantity,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		inventory = append(inventory, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return inventory, nil
+}
+
+func (r *sqlRepository) GetCoinHistory(ctx context.Context, userID int) (_ *models.CoinHistory, err error) {
+	ctx, span := r.startSpan(ctx, "GetCoinHistory")
+	defer func() { endSpan(span, err) }()
+
+	var coinHistory *models.CoinHistory
+
+	// The queries share one snapshot, so a transfer committed in between can't show up
+	// in only one of the lists.
+	err = withReadOnlyTx(ctx, r.DB, r.dialect.snapshotIsolation, func(tx *sql.Tx) error {
+		query := `
+		    SELECT u1.username, t.amount
+		    FROM "transaction" AS t
+		    JOIN users AS u1 ON t.sender_id = u1.id
+		    JOIN users AS u2 ON t.receiver_id = u2.id
+		    WHERE t.receiver_id = $1`
+
+		traceQuery(ctx, query)
+		received, err := queryCoinTransactions(ctx, tx, query, userID, func(ct *models.CoinTransaction) any {
+			return &ct.FromUser
+		})
+		if err != nil {
+			return err
+		}
+
+		query = `
+		    SELECT u2.username, t.amount
+		    FROM "transaction" AS t
+		    JOIN users AS u1 ON t.sender_id = u1.id
+		    JOIN users AS u2 ON t.receiver_id = u2.id
+		    WHERE t.sender_id = $1`
+
+		traceQuery(ctx, query)
+		sent, err := queryCoinTransactions(ctx, tx, query, userID, func(ct *models.CoinTransaction) any {
+			return &ct.ToUser
+		})
+		if err != nil {
+			return err
+		}
+
+		query = `
+		    SELECT amount
+		    FROM coin_grant
+		    WHERE user_id = $1
+		    ORDER BY id`
+
+		traceQuery(ctx, query)
+		granted, err := queryCoinTransactions(ctx, tx, query, userID, nil)
+		if err != nil {
+			return err
+		}
+
+		coinHistory = &models.CoinHistory{
+			Received: received,
+			Sent:     sent,
+			Granted:  granted,
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return coinHistory, nil
+}
+
+func (r *sqlRepository) GetProfile(ctx context.Context, username string) (_ *models.UserProfile, err error) {
+	ctx, span := r.startSpan(ctx, "GetProfile")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	    SELECT username, display_name, avatar_url, created_at
+	    FROM active_users
+	    WHERE username = $1`
+
+	traceQuery(ctx, query)
+	profile, err := scanProfile(r.DB.QueryRowContext(ctx, query, username))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+func (r *sqlRepository) SearchUsers(ctx context.Context, prefix, after string, limit int) (_ []*models.UserProfile, err error) {
+	ctx, span := r.startSpan(ctx, "SearchUsers")
+	defer func() { endSpan(span, err) }()
+
+	match, pattern := r.dialect.matchPrefix(prefix)
+
+	query := `
+	    SELECT username, display_name, avatar_url, created_at
+	    FROM active_users
+	    WHERE ` + match + `
+	    AND username` + r.dialect.collate + ` > $2
+	    ORDER BY username` + r.dialect.collate + `
+	    LIMIT $3`
+
+	args := []any{pattern, after, limit}
+
+	traceQuery(ctx, query)
+	return queryProfiles(ctx, r.DB, query, args...)
+}
+
+func (r *sqlRepository) UpdateProfile(ctx context.Context, userID int, displayName, avatarURL *string, leaderboardOptOut *bool) (_ *models.UserProfile, err error) {
+	ctx, span := r.startSpan(ctx, "UpdateProfile")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	    UPDATE users
+	    SET display_name = COALESCE($2, display_name),
+	        avatar_url = COALESCE($3, avatar_url),
+	        leaderboard_opt_out = COALESCE($4, leaderboard_opt_out)
+	    WHERE id = $1 AND is_active = TRUE
+	    RETURNING username, display_name, avatar_url, created_at, leaderboard_opt_out`
+
+	args := []any{userID, displayName, avatarURL, leaderboardOptOut}
+
+	profile := &models.UserProfile{
+		LeaderboardOptOut: new(bool),
+	}
+
+	traceQuery(ctx, query)
+	err = r.DB.QueryRowContext(ctx, query, args...).Scan(
+		&profile.Username,
+		&profile.DisplayName,
+		&profile.AvatarURL,
+		&profile.JoinedAt,
+		profile.LeaderboardOptOut,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+func (r *sqlRepository) GetLeaderboard(ctx context.Context, mode string, since time.Time, limit int) (_ []*models.LeaderboardEntry, err error) {
+	ctx, span := r.startSpan(ctx, "GetLeaderboard")
+	defer func() { endSpan(span, err) }()
+
+	var query string
+	var args []any
+
+	switch mode {
+	case models.LeaderboardReceived, models.LeaderboardSent:
+		// The transfers in the window are summed from idx_transaction_created_at first,
+		// so only the users that made it into the window are joined.
+		column := "receiver_id"
+		if mode == models.LeaderboardSent {
+			column = "sender_id"
+		}
+
+		query = `
+		    SELECT u.username, u.display_name, s.score
+		    FROM (
+		        SELECT ` + column + ` AS user_id, SUM(amount) AS score
+		        FROM "transaction"
+		        WHERE created_at >= $1
+		        GROUP BY ` + column + `
+		    ) AS s
+		    JOIN active_users AS u ON s.user_id = u.id
+		    WHERE NOT u.leaderboard_opt_out
+		    ORDER BY s.score DESC, u.username` + r.dialect.collate + `
+		    LIMIT $2`
+
+		args = []any{r.dialect.timestamp(since), limit}
+	case models.LeaderboardItems:
+		query = `
+		    SELECT u.username, u.display_name, s.score
+		    FROM (
+		        SELECT user_id, SUM(quantity) AS score
+		        FROM inventory
+		        GROUP BY user_id
+		    ) AS s
+		    JOIN active_users AS u ON s.user_id = u.id
+		    WHERE NOT u.leaderboard_opt_out
+		    ORDER BY s.score DESC, u.username` + r.dialect.collate + `
+		    LIMIT $1`
+
+		args = []any{limit}
+	default:
+		return nil, fmt.Errorf("unknown leaderboard mode %q", mode)
+	}
+
+	traceQuery(ctx, query)
+	return queryLeaderboard(ctx, r.DB, query, args...)
+}
+
+func (r *sqlRepository) DispatchEvents(ctx context.Context, endpoints map[string][]string, limit int) (_ int, err error) {
+	ctx, span := r.startSpan(ctx, "DispatchEvents")
+	defer func() { endSpan(span, err) }()
+
+	var n int
+
+	err = withTx(ctx, r.DB, r.dialect.writeIsolation, func(tx *sql.Tx) error {
+		query := `
+		    SELECT id, event_type, payload, created_at
+		    FROM outbox
+		    ORDER BY id
+		    LIMIT $1
+		    ` + r.dialect.skipLocked
+
+		var err error
+		n, err = dispatchEvents(ctx, tx, query, endpoints, limit)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+func (r *sqlRepository) ClaimDeliveries(ctx context.Context, lease time.Duration, limit int) (_ []*models.WebhookDelivery, err error) {
+	ctx, span := r.startSpan(ctx, "ClaimDeliveries")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	    UPDATE webhook_delivery
+	    SET next_attempt_at = ` + r.dialect.nowPlus("$1") + `, lease_id = lease_id + 1
+	    WHERE id IN (
+	        SELECT id
+	        FROM webhook_delivery
+	        WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+	        ORDER BY next_attempt_at
+	        LIMIT $2
+	        ` + r.dialect.skipLocked + `
+	    )
+	    RETURNING ` + deliveryColumns
+
+	args := []any{r.dialect.duration(lease), limit}
+
+	traceQuery(ctx, query)
+	return queryDeliveries(ctx, r.DB, query, args...)
+}
+
+func (r *sqlRepository) MarkDelivered(ctx context.Context, deliveryID, leaseID int) (err error) {
+	ctx, span := r.startSpan(ctx, "MarkDelivered")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	    UPDATE webhook_delivery
+	    SET status = 'delivered', attempts = attempts + 1, last_error = '', delivered_at = CURRENT_TIMESTAMP
+	    WHERE id = $1 AND lease_id = $2 AND status = 'pending'`
+
+	traceQuery(ctx, query)
+	result, err := r.DB.ExecContext(ctx, query, deliveryID, leaseID)
+	if err != nil {
+		return err
+	}
+
+	return checkLease(result)
+}
+
+func (r *sqlRepository) MarkFailed(ctx context.Context, deliveryID, leaseID int, lastError string, retryIn time.Duration, dead bool) (err error) {
+	ctx, span := r.startSpan(ctx, "MarkFailed")
+	defer func() { endSpan(span, err) }()
+
+	status := models.DeliveryPending
+	if dead {
+		status = models.DeliveryDead
+	}
+
+	query := `
+	    UPDATE webhook_delivery
+	    SET status = $3, attempts = attempts + 1, last_error = $4, next_attempt_at = ` + r.dialect.nowPlus("$5") + `
+	    WHERE id = $1 AND lease_id = $2 AND status = 'pending'`
+
+	args := []any{deliveryID, leaseID, status, lastError, r.dialect.duration(retryIn)}
+
+	traceQuery(ctx, query)
+	result, err := r.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	return checkLease(result)
+}
+
+func (r *sqlRepository) ListDeliveries(ctx context.Context, status string, after, limit int) (_ []*models.WebhookDelivery, err error) {
+	ctx, span := r.startSpan(ctx, "ListDeliveries")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	    SELECT ` + deliveryColumns + `
+	    FROM webhook_delivery
+	    WHERE status = $1 AND id > $2
+	    ORDER BY id
+	    LIMIT $3`
+
+	args := []any{status, after, limit}
+
+	traceQuery(ctx, query)
+	return queryDeliveries(ctx, r.DB, query, args...)
+}
+
+func (r *sqlRepository) ReplayDelivery(ctx context.Context, deliveryID int) (_ *models.WebhookDelivery, err error) {
+	ctx, span := r.startSpan(ctx, "ReplayDelivery")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	    UPDATE webhook_delivery
+	    SET status = 'pending', attempts = 0, last_error = '', next_attempt_at = CURRENT_TIMESTAMP
+	    WHERE id = $1 AND status = 'dead'
+	    RETURNING ` + deliveryColumns
+
+	traceQuery(ctx, query)
+	delivery, err := scanDelivery(r.DB.QueryRowContext(ctx, query, deliveryID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDeliveryNotFound
+		}
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+func (r *sqlRepository) PurgeDeliveries(ctx context.Context, olderThan time.Duration) (_ int, err error) {
+	ctx, span := r.startSpan(ctx, "PurgeDeliveries")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	    DELETE FROM webhook_delivery
+	    WHERE status = 'delivered' AND delivered_at < ` + r.dialect.nowPlus("$1")
+
+	traceQuery(ctx, query)
+	result, err := r.DB.ExecContext(ctx, query, r.dialect.duration(-olderThan))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+func (r *sqlRepository) ListUserEvents(ctx context.Context, userID, after, limit int) (_ []*models.UserEvent, err error) {
+	ctx, span := r.startSpan(ctx, "ListUserEvents")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	    SELECT id, user_id, event_type, payload, created_at
+	    FROM user_event
+	    WHERE user_id = $1 AND id > $2
+	    ORDER BY id
+	    LIMIT $3`
+
+	traceQuery(ctx, query)
+	return queryUserEvents(ctx, r.DB, query, userID, after, limit)
+}
+
+func (r *sqlRepository) ListAllUserEvents(ctx context.Context, after, limit int) (_ []*models.UserEvent, err error) {
+	ctx, span := r.startSpan(ctx, "ListAllUserEvents")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	    SELECT id, user_id, event_type, payload, created_at
+	    FROM user_event
+	    WHERE id > $1
+	    ORDER BY id
+	    LIMIT $2`
+
+	traceQuery(ctx, query)
+	return queryUserEvents(ctx, r.DB, query, after, limit)
+}
+
+func (r *sqlRepository) LastUserEventID(ctx context.Context) (_ int, err error) {
+	ctx, span := r.startSpan(ctx, "LastUserEventID")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	    SELECT COALESCE(MAX(id), 0)
+	    FROM user_event`
+
+	var id int
+	traceQuery(ctx, query)
+	err = r.DB.QueryRowContext(ctx, query).Scan(&id)
+	return id, err
+}
+
+func (r *sqlRepository) PurgeUserEvents(ctx context.Context, olderThan time.Duration) (_ int, err error) {
+	ctx, span := r.startSpan(ctx, "PurgeUserEvents")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	    DELETE FROM user_event
+	    WHERE created_at < ` + r.dialect.nowPlus("$1")
+
+	traceQuery(ctx, query)
+	result, err := r.DB.ExecContext(ctx, query, r.dialect.duration(-olderThan))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// checkLease reports ErrLeaseLost if an update guarded by the lease ID changed no rows.
+func checkLease(result sql.Result) error {
+	err := checkRowsAffected(result)
+	if errors.Is(err, ErrRecordNotFound) {
+		return ErrLeaseLost
+	}
+
+	return err
+}
+
+func checkRowsAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
 func checkBalance(balance, amount int) error {
 	if balance < amount {
 		return ErrNotEnoughCoins
 	}
 	return nil
 }
+
+// maxBalance is the largest balance coins.balance holds, an INT on Postgres. The other
+// backends keep to it as well.
+const maxBalance = math.MaxInt32
+
+// checkCredit reports ErrBalanceLimit if adding amount to balance would pass maxBalance.
+func checkCredit(balance, amount int) error {
+	if balance > maxBalance-amount {
+		return ErrBalanceLimit
+	}
+	return nil
+}
+
+// queryCoinTransactions scans (username, amount) rows, counterpart picks the field the username goes to.
+// Without counterpart the rows are (amount), as for grants, which have no counterpart.
+func queryCoinTransactions(ctx context.Context, tx *sql.Tx, query string, userID int, counterpart func(*models.CoinTransaction) any) ([]*models.CoinTransaction, error) {
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []*models.CoinTransaction{}
+
+	for rows.Next() {
+		var ct models.CoinTransaction
+		dest := []any{&ct.Amount}
+		if counterpart != nil {
+			dest = []any{counterpart(&ct), &ct.Amount}
+		}
+
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+
+		transactions = append(transactions, &ct)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+type account struct {
+	username string
+	balance  int
+}
+
+// queryAccounts scans (user_id, username, balance) rows into accounts keyed by user ID.
+func queryAccounts(ctx context.Context, tx *sql.Tx, query string, args ...any) (map[int]account, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := map[int]account{}
+
+	for rows.Next() {
+		var userID int
+		var a account
+		if err := rows.Scan(&userID, &a.username, &a.balance); err != nil {
+			return nil, err
+		}
+		accounts[userID] = a
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+// likePrefix builds a LIKE pattern matching strings that start with prefix literally.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+}
+
+// queryItems scans (id, type, price) rows.
+func queryItems(ctx context.Context, db *sql.DB, query string, args ...any) ([]*models.Item, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*models.Item{}
+
+	for rows.Next() {
+		var item models.Item
+		if err := rows.Scan(&item.ID, &item.Name, &item.Price); err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// scanProfile scans a (username, display_name, avatar_url, created_at) row.
+func scanProfile(row *sql.Row) (*models.UserProfile, error) {
+	var p models.UserProfile
+	if err := row.Scan(&p.Username, &p.DisplayName, &p.AvatarURL, &p.JoinedAt); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// queryProfiles scans (username, display_name, avatar_url, created_at) rows.
+func queryProfiles(ctx context.Context, db *sql.DB, query string, args ...any) ([]*models.UserProfile, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []*models.UserProfile{}
+
+	for rows.Next() {
+		var p models.UserProfile
+		if err := rows.Scan(&p.Username, &p.DisplayName, &p.AvatarURL, &p.JoinedAt); err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, &p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+// queryLeaderboard scans (username, display_name, score) rows, ranks are left to the caller.
+func queryLeaderboard(ctx context.Context, db *sql.DB, query string, args ...any) ([]*models.LeaderboardEntry, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*models.LeaderboardEntry{}
+
+	for rows.Next() {
+		var e models.LeaderboardEntry
+		if err := rows.Scan(&e.Username, &e.DisplayName, &e.Score); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// insertEvent writes an event to the outbox within tx, so it is published only if tx commits.
+func insertEvent(ctx context.Context, tx *sql.Tx, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	    INSERT INTO outbox(event_type, payload)
+	    VALUES ($1, $2)`
+
+	traceQuery(ctx, query)
+	_, err = tx.ExecContext(ctx, query, eventType, string(payload))
+	return err
+}
+
+// insertGrant records the grant of amount coins to the user, with its outbox event.
+func insertGrant(ctx context.Context, tx *sql.Tx, userID, amount int) error {
+	query := `
+	    INSERT INTO coin_grant(user_id, amount)
+	    VALUES ($1, $2)
+	    RETURNING id, created_at`
+
+	grant := models.GrantEvent{Amount: amount}
+
+	traceQuery(ctx, query)
+	err := tx.QueryRowContext(ctx, query, userID, amount).Scan(&grant.ID, &grant.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	query = `
+	    SELECT username
+	    FROM users
+	    WHERE id = $1`
+
+	traceQuery(ctx, query)
+	err = tx.QueryRowContext(ctx, query, userID).Scan(&grant.Username)
+	if err != nil {
+		return err
+	}
+
+	return insertEvent(ctx, tx, models.EventCoinsGranted, grant)
+}
+
+// dispatchEvents reads (id, event_type, payload, created_at) outbox rows with query, turns every
+// event into a delivery per subscribed endpoint and deletes it from the outbox.
+func dispatchEvents(ctx context.Context, tx *sql.Tx, query string, endpoints map[string][]string, limit int) (int, error) {
+	traceQuery(ctx, query)
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	events := []*models.Event{}
+
+	for rows.Next() {
+		var e models.Event
+		var payload string
+		if err := rows.Scan(&e.ID, &e.Type, &payload, &e.CreatedAt); err != nil {
+			return 0, err
+		}
+		e.Data = json.RawMessage(payload)
+		events = append(events, &e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, e := range events {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, endpoint := range endpoints[e.Type] {
+			query := `
+			    INSERT INTO webhook_delivery(event_id, event_type, endpoint, payload)
+			    VALUES ($1, $2, $3, $4)
+			    ON CONFLICT (event_id, endpoint) DO NOTHING`
+
+			traceQuery(ctx, query)
+			if _, err := tx.ExecContext(ctx, query, e.ID, e.Type, endpoint, string(body)); err != nil {
+				return 0, err
+			}
+		}
+
+		query := `
+		    DELETE FROM outbox
+		    WHERE id = $1`
+
+		traceQuery(ctx, query)
+		if _, err := tx.ExecContext(ctx, query, e.ID); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(events), nil
+}
+
+// deliveryColumns are the webhook_delivery columns read by scanDelivery.
+const deliveryColumns = "id, event_id, event_type, endpoint, payload, status, attempts, last_error, next_attempt_at, created_at, delivered_at, lease_id"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload string
+	var deliveredAt sql.NullTime
+
+	err := row.Scan(
+		&d.ID,
+		&d.EventID,
+		&d.EventType,
+		&d.Endpoint,
+		&payload,
+		&d.Status,
+		&d.Attempts,
+		&d.LastError,
+		&d.NextAttemptAt,
+		&d.CreatedAt,
+		&deliveredAt,
+		&d.LeaseID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Payload = []byte(payload)
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+
+	return &d, nil
+}
+
+// queryDeliveries scans rows of deliveryColumns.
+func queryDeliveries(ctx context.Context, db *sql.DB, query string, args ...any) ([]*models.WebhookDelivery, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// userEvent is an event for the live stream of a user that is not stored yet.
+type userEvent struct {
+	userID    int
+	eventType string
+	data      any
+}
+
+// transferUserEvents returns the stream events of a transfer: the new balance of the sender,
+// and the transfer and the new balance of the receiver.
+func transferUserEvents(senderID, receiverID, receiverBalance int, transfer *models.Transfer) []userEvent {
+	return []userEvent{
+		{senderID, models.UserEventBalanceChanged, models.BalanceChangedEvent{Balance: transfer.Balance}},
+		{receiverID, models.UserEventTransferReceived, models.TransferReceivedEvent{
+			ID:        transfer.ID,
+			FromUser:  transfer.FromUser,
+			Amount:    transfer.Amount,
+			CreatedAt: transfer.CreatedAt,
+		}},
+		{receiverID, models.UserEventBalanceChanged, models.BalanceChangedEvent{Balance: receiverBalance}},
+	}
+}
+
+// purchaseUserEvents returns the stream events of a purchase: its confirmation and the new balance.
+func purchaseUserEvents(userID int, purchase *models.Purchase) []userEvent {
+	return []userEvent{
+		{userID, models.UserEventPurchaseCreated, purchase},
+		{userID, models.UserEventBalanceChanged, models.BalanceChangedEvent{Balance: purchase.Balance}},
+	}
+}
+
+// balanceUserEvents returns the stream event of a balance changed by an admin.
+func balanceUserEvents(userID, balance int) []userEvent {
+	return []userEvent{
+		{userID, models.UserEventBalanceChanged, models.BalanceChangedEvent{Balance: balance}},
+	}
+}
+
+// insertUserEvents writes events for the live streams within tx and returns them as stored.
+func insertUserEvents(ctx context.Context, tx *sql.Tx, events []userEvent) ([]*models.UserEvent, error) {
+	query := `
+	    INSERT INTO user_event(user_id, event_type, payload)
+	    VALUES ($1, $2, $3)
+	    RETURNING id, created_at`
+
+	stored := make([]*models.UserEvent, 0, len(events))
+
+	for _, e := range events {
+		payload, err := json.Marshal(e.data)
+		if err != nil {
+			return nil, err
+		}
+
+		ue := &models.UserEvent{
+			UserID: e.userID,
+			Type:   e.eventType,
+			Data:   payload,
+		}
+
+		traceQuery(ctx, query)
+		err = tx.QueryRowContext(ctx, query, e.userID, e.eventType, string(payload)).Scan(&ue.ID, &ue.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		stored = append(stored, ue)
+	}
+
+	return stored, nil
+}
+
+// queryUserEvents scans (id, user_id, event_type, payload, created_at) rows.
+func queryUserEvents(ctx context.Context, db *sql.DB, query string, args ...any) ([]*models.UserEvent, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*models.UserEvent{}
+
+	for rows.Next() {
+		var e models.UserEvent
+		var payload string
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Type, &payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Data = json.RawMessage(payload)
+		events = append(events, &e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,810 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"merch-shop/internal/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultBalance = 1000
+
+// defaultItems mirrors the items seeded by the initial migration.
+var defaultItems = []models.Item{
+	{Name: "t-shirt", Price: 80},
+	{Name: "cup", Price: 20},
+	{Name: "book", Price: 50},
+	{Name: "pen", Price: 10},
+	{Name: "powerbank", Price: 200},
+	{Name: "hoody", Price: 300},
+	{Name: "umbrella", Price: 200},
+	{Name: "socks", Price: 10},
+	{Name: "wallet", Price: 50},
+	{Name: "pink-hoody", Price: 500},
+}
+
+type memoryUser struct {
+	models.User
+	displayName       string
+	avatarURL         string
+	leaderboardOptOut bool
+	isActive          bool
+	balance           int
+	// inventory holds quantities by item ID.
+	inventory map[int]int
+}
+
+type memoryTransaction struct {
+	id         int
+	senderID   int
+	receiverID int
+	amount     int
+	createdAt  time.Time
+}
+
+type memoryGrant struct {
+	userID int
+	amount int
+}
+
+// MemoryRepository is a thread-safe Repository kept in process memory. It follows the
+// semantics of PostgresRepository and is meant for local development and tests.
+type MemoryRepository struct {
+	mu           sync.RWMutex
+	nextUserID   int
+	users        map[int]*memoryUser
+	usernames    map[string]int
+	items        map[string]*models.Item
+	itemNames    map[int]string
+	transactions []*memoryTransaction
+	purchases    int
+	grants       []*memoryGrant
+	// outbox holds events not dispatched yet, deliveries are ordered by ID.
+	outbox         []*models.Event
+	nextEventID    int
+	deliveries     []*models.WebhookDelivery
+	nextDeliveryID int
+	// userEvents are ordered by ID.
+	userEvents      []*models.UserEvent
+	nextUserEventID int
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	r := &MemoryRepository{
+		nextUserID: 1,
+		users:      map[int]*memoryUser{},
+		usernames:  map[string]int{},
+		items:      map[string]*models.Item{},
+		itemNames:  map[int]string{}}
+
+	for i, item := range defaultItems {
+		item.ID = i + 1
+		r.items[item.Name] = &item
+		r.itemNames[item.ID] = item.Name
+	}
+
+	return r
+}
+
+// activeUser must be called with r.mu held.
+func (r *MemoryRepository) activeUser(userID int) (*memoryUser, bool) {
+	u, ok := r.users[userID]
+	if !ok || !u.isActive {
+		return nil, false
+	}
+	return u, true
+}
+
+func (r *MemoryRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.activeUser(r.usernames[username])
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	user := u.User
+	user.IsActive = true
+	return &user, nil
+}
+
+func (r *MemoryRepository) GetCredentials(ctx context.Context, username string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.users[r.usernames[username]]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	user := u.User
+	user.IsActive = u.isActive
+	return &user, nil
+}
+
+func (r *MemoryRepository) Add(ctx context.Context, u *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.usernames[u.Username]; ok {
+		return fmt.Errorf("user: %w", ErrDuplicateRecord)
+	}
+
+	u.ID = r.nextUserID
+	u.CreatedAt = time.Now()
+	r.nextUserID++
+
+	r.users[u.ID] = &memoryUser{
+		User:      *u,
+		isActive:  true,
+		balance:   defaultBalance,
+		inventory: map[int]int{},
+	}
+	r.usernames[u.Username] = u.ID
+
+	r.addEvent(models.EventUserRegistered, models.UserRegisteredEvent{
+		Username: u.Username,
+		JoinedAt: u.CreatedAt,
+	})
+
+	return nil
+}
+
+func (r *MemoryRepository) DeactivateUser(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[userID]
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	u.isActive = false
+	return nil
+}
+
+func (r *MemoryRepository) GrantCoins(ctx context.Context, userID, amount int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.activeUser(userID)
+	if !ok {
+		return 0, ErrUserNotFound
+	}
+
+	if err := checkCredit(u.balance, amount); err != nil {
+		return 0, err
+	}
+
+	u.balance += amount
+	r.grants = append(r.grants, &memoryGrant{userID: userID, amount: amount})
+
+	r.addEvent(models.EventCoinsGranted, models.GrantEvent{
+		ID:        len(r.grants),
+		Username:  u.Username,
+		Amount:    amount,
+		CreatedAt: time.Now(),
+	})
+	r.addUserEvents(balanceUserEvents(userID, u.balance))
+
+	return u.balance, nil
+}
+
+func (r *MemoryRepository) ListItems(ctx context.Context) ([]*models.Item, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	items := make([]*models.Item, 0, len(r.items))
+	for _, item := range r.items {
+		i := *item
+		items = append(items, &i)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+
+	return items, nil
+}
+
+func (r *MemoryRepository) SetItemPrice(ctx context.Context, itemName string, price int) (*models.Item, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.items[itemName]
+	if !ok {
+		item = &models.Item{ID: len(r.itemNames) + 1, Name: itemName}
+		r.items[itemName] = item
+		r.itemNames[item.ID] = itemName
+	}
+
+	item.Price = price
+
+	i := *item
+	return &i, nil
+}
+
+func (r *MemoryRepository) BuyItem(ctx context.Context, userID int, itemName string) (*models.Purchase, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.activeUser(userID)
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	item, ok := r.items[itemName]
+	if !ok {
+		return nil, ErrItemNotFound
+	}
+
+	if err := checkBalance(u.balance, item.Price); err != nil {
+		return nil, err
+	}
+
+	u.inventory[item.ID]++
+	u.balance -= item.Price
+	r.purchases++
+
+	purchase := &models.Purchase{
+		ID:        r.purchases,
+		Item:      item.Name,
+		Price:     item.Price,
+		Quantity:  u.inventory[item.ID],
+		CreatedAt: time.Now(),
+		Balance:   u.balance,
+	}
+
+	r.addEvent(models.EventPurchaseCreated, models.PurchaseEvent{
+		ID:        purchase.ID,
+		Username:  u.Username,
+		Item:      purchase.Item,
+		Price:     purchase.Price,
+		CreatedAt: purchase.CreatedAt,
+	})
+	r.addUserEvents(purchaseUserEvents(userID, purchase))
+
+	return purchase, nil
+}
+
+func (r *MemoryRepository) SendCoin(ctx context.Context, senderID, receiverID int, amount int) (*models.Transfer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sender, ok := r.activeUser(senderID)
+	if !ok {
+		return nil, ErrSenderNotFound
+	}
+
+	receiver, ok := r.activeUser(receiverID)
+	if !ok {
+		return nil, ErrReceiverNotFound
+	}
+
+	if err := checkBalance(sender.balance, amount); err != nil {
+		return nil, err
+	}
+	if err := checkCredit(receiver.balance, amount); err != nil {
+		return nil, err
+	}
+
+	sender.balance -= amount
+	receiver.balance += amount
+
+	t := &memoryTransaction{
+		id:         len(r.transactions) + 1,
+		senderID:   senderID,
+		receiverID: receiverID,
+		amount:     amount,
+		createdAt:  time.Now(),
+	}
+	r.transactions = append(r.transactions, t)
+
+	r.addEvent(models.EventTransferCreated, models.TransferEvent{
+		ID:        t.id,
+		FromUser:  sender.Username,
+		ToUser:    receiver.Username,
+		Amount:    amount,
+		CreatedAt: t.createdAt,
+	})
+
+	transfer := &models.Transfer{
+		ID:        t.id,
+		FromUser:  sender.Username,
+		ToUser:    receiver.Username,
+		Amount:    amount,
+		CreatedAt: t.createdAt,
+		Balance:   sender.balance,
+	}
+	r.addUserEvents(transferUserEvents(senderID, receiverID, receiver.balance, transfer))
+
+	return transfer, nil
+}
+
+func (r *MemoryRepository) GetBalance(ctx context.Context, userID int) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.activeUser(userID)
+	if !ok {
+		return 0, ErrUserNotFound
+	}
+
+	return u.balance, nil
+}
+
+func (r *MemoryRepository) GetInventory(ctx context.Context, userID int) ([]*models.InventoryItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	inventory := []*models.InventoryItem{}
+
+	u, ok := r.users[userID]
+	if !ok {
+		return inventory, nil
+	}
+
+	itemIDs := make([]int, 0, len(u.inventory))
+	for itemID := range u.inventory {
+		itemIDs = append(itemIDs, itemID)
+	}
+	sort.Ints(itemIDs)
+
+	for _, itemID := range itemIDs {
+		inventory = append(inventory, &models.InventoryItem{
+			Type:     r.itemNames[itemID],
+			Quantity: u.inventory[itemID],
+		})
+	}
+
+	return inventory, nil
+}
+
+func (r *MemoryRepository) GetCoinHistory(ctx context.Context, userID int) (*models.CoinHistory, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	coinHistory := &models.CoinHistory{
+		Received: []*models.CoinTransaction{},
+		Sent:     []*models.CoinTransaction{},
+		Granted:  []*models.CoinTransaction{},
+	}
+
+	for _, g := range r.grants {
+		if g.userID == userID {
+			coinHistory.Granted = append(coinHistory.Granted, &models.CoinTransaction{Amount: g.amount})
+		}
+	}
+
+	for _, t := range r.transactions {
+		if t.receiverID == userID {
+			coinHistory.Received = append(coinHistory.Received, &models.CoinTransaction{
+				FromUser: r.users[t.senderID].Username,
+				Amount:   t.amount,
+			})
+		}
+
+		if t.senderID == userID {
+			coinHistory.Sent = append(coinHistory.Sent, &models.CoinTransaction{
+				ToUser: r.users[t.receiverID].Username,
+				Amount: t.amount,
+			})
+		}
+	}
+
+	return coinHistory, nil
+}
+
+// profile must be called with r.mu held.
+func (u *memoryUser) profile() *models.UserProfile {
+	return &models.UserProfile{
+		Username:    u.Username,
+		DisplayName: u.displayName,
+		AvatarURL:   u.avatarURL,
+		JoinedAt:    u.CreatedAt,
+	}
+}
+
+func (r *MemoryRepository) GetProfile(ctx context.Context, username string) (*models.UserProfile, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.activeUser(r.usernames[username])
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	return u.profile(), nil
+}
+
+func (r *MemoryRepository) SearchUsers(ctx context.Context, prefix, after string, limit int) ([]*models.UserProfile, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	profiles := []*models.UserProfile{}
+
+	for _, u := range r.users {
+		if u.isActive && strings.HasPrefix(u.Username, prefix) && u.Username > after {
+			profiles = append(profiles, u.profile())
+		}
+	}
+
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Username < profiles[j].Username
+	})
+
+	if len(profiles) > limit {
+		profiles = profiles[:limit]
+	}
+
+	return profiles, nil
+}
+
+func (r *MemoryRepository) UpdateProfile(ctx context.Context, userID int, displayName, avatarURL *string, leaderboardOptOut *bool) (*models.UserProfile, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.activeUser(userID)
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	if displayName != nil {
+		u.displayName = *displayName
+	}
+	if avatarURL != nil {
+		u.avatarURL = *avatarURL
+	}
+	if leaderboardOptOut != nil {
+		u.leaderboardOptOut = *leaderboardOptOut
+	}
+
+	profile := u.profile()
+	optOut := u.leaderboardOptOut
+	profile.LeaderboardOptOut = &optOut
+
+	return profile, nil
+}
+
+func (r *MemoryRepository) GetLeaderboard(ctx context.Context, mode string, since time.Time, limit int) ([]*models.LeaderboardEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	scores := map[int]int{}
+
+	switch mode {
+	case models.LeaderboardReceived, models.LeaderboardSent:
+		for _, t := range r.transactions {
+			if t.createdAt.Before(since) {
+				continue
+			}
+			if mode == models.LeaderboardReceived {
+				scores[t.receiverID] += t.amount
+			} else {
+				scores[t.senderID] += t.amount
+			}
+		}
+	case models.LeaderboardItems:
+		for _, u := range r.users {
+			for _, quantity := range u.inventory {
+				scores[u.ID] += quantity
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown leaderboard mode %q", mode)
+	}
+
+	entries := []*models.LeaderboardEntry{}
+
+	for userID, score := range scores {
+		u, ok := r.activeUser(userID)
+		if !ok || u.leaderboardOptOut {
+			continue
+		}
+
+		entries = append(entries, &models.LeaderboardEntry{
+			Username:    u.Username,
+			DisplayName: u.displayName,
+			Score:       score,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		return entries[i].Username < entries[j].Username
+	})
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries, nil
+}
+
+// addEvent must be called with r.mu held.
+func (r *MemoryRepository) addEvent(eventType string, data any) {
+	// The event types are plain structs, marshaling them can't fail.
+	payload, _ := json.Marshal(data)
+
+	r.nextEventID++
+	r.outbox = append(r.outbox, &models.Event{
+		ID:        r.nextEventID,
+		Type:      eventType,
+		CreatedAt: time.Now(),
+		Data:      payload,
+	})
+}
+
+// delivery must be called with r.mu held.
+func (r *MemoryRepository) delivery(deliveryID int) (*models.WebhookDelivery, bool) {
+	i, found := sort.Find(len(r.deliveries), func(i int) int {
+		return deliveryID - r.deliveries[i].ID
+	})
+	if !found {
+		return nil, false
+	}
+	return r.deliveries[i], true
+}
+
+// leased returns the delivery if it is pending under the claim with leaseID.
+func (r *MemoryRepository) leased(deliveryID, leaseID int) (*models.WebhookDelivery, bool) {
+	d, ok := r.delivery(deliveryID)
+	if !ok || d.LeaseID != leaseID || d.Status != models.DeliveryPending {
+		return nil, false
+	}
+	return d, true
+}
+
+func (r *MemoryRepository) DispatchEvents(ctx context.Context, endpoints map[string][]string, limit int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := min(limit, len(r.outbox))
+	now := time.Now()
+
+	for _, e := range r.outbox[:n] {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, endpoint := range endpoints[e.Type] {
+			r.nextDeliveryID++
+			r.deliveries = append(r.deliveries, &models.WebhookDelivery{
+				ID:            r.nextDeliveryID,
+				EventID:       e.ID,
+				EventType:     e.Type,
+				Endpoint:      endpoint,
+				Status:        models.DeliveryPending,
+				NextAttemptAt: now,
+				CreatedAt:     now,
+				Payload:       body,
+			})
+		}
+	}
+
+	r.outbox = r.outbox[n:]
+
+	return n, nil
+}
+
+func (r *MemoryRepository) ClaimDeliveries(ctx context.Context, lease time.Duration, limit int) ([]*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	due := []*models.WebhookDelivery{}
+
+	for _, d := range r.deliveries {
+		if d.Status == models.DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*models.WebhookDelivery, 0, len(due))
+	for _, d := range due {
+		d.NextAttemptAt = now.Add(lease)
+		d.LeaseID++
+		c := *d
+		claimed = append(claimed, &c)
+	}
+
+	return claimed, nil
+}
+
+func (r *MemoryRepository) MarkDelivered(ctx context.Context, deliveryID, leaseID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.leased(deliveryID, leaseID)
+	if !ok {
+		return ErrLeaseLost
+	}
+
+	now := time.Now()
+	d.Status = models.DeliveryDelivered
+	d.Attempts++
+	d.LastError = ""
+	d.DeliveredAt = &now
+
+	return nil
+}
+
+func (r *MemoryRepository) MarkFailed(ctx context.Context, deliveryID, leaseID int, lastError string, retryIn time.Duration, dead bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.leased(deliveryID, leaseID)
+	if !ok {
+		return ErrLeaseLost
+	}
+
+	d.Status = models.DeliveryPending
+	if dead {
+		d.Status = models.DeliveryDead
+	}
+	d.Attempts++
+	d.LastError = lastError
+	d.NextAttemptAt = time.Now().Add(retryIn)
+
+	return nil
+}
+
+func (r *MemoryRepository) ListDeliveries(ctx context.Context, status string, after, limit int) ([]*models.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deliveries := []*models.WebhookDelivery{}
+
+	for _, d := range r.deliveries {
+		if len(deliveries) == limit {
+			break
+		}
+		if d.Status == status && d.ID > after {
+			c := *d
+			deliveries = append(deliveries, &c)
+		}
+	}
+
+	return deliveries, nil
+}
+
+func (r *MemoryRepository) ReplayDelivery(ctx context.Context, deliveryID int) (*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.delivery(deliveryID)
+	if !ok || d.Status != models.DeliveryDead {
+		return nil, ErrDeliveryNotFound
+	}
+
+	d.Status = models.DeliveryPending
+	d.Attempts = 0
+	d.LastError = ""
+	d.NextAttemptAt = time.Now()
+
+	c := *d
+	return &c, nil
+}
+
+func (r *MemoryRepository) PurgeDeliveries(ctx context.Context, olderThan time.Duration) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cutoff := time.Now().Add(-olderThan)
+	kept := r.deliveries[:0]
+
+	for _, d := range r.deliveries {
+		if d.Status == models.DeliveryDelivered && d.DeliveredAt.Before(cutoff) {
+			continue
+		}
+		kept = append(kept, d)
+	}
+
+	n := len(r.deliveries) - len(kept)
+	r.deliveries = kept
+
+	return n, nil
+}
+
+// addUserEvents must be called with r.mu held.
+func (r *MemoryRepository) addUserEvents(events []userEvent) {
+	now := time.Now()
+
+	for _, e := range events {
+		// The event types are plain structs, marshaling them can't fail.
+		payload, _ := json.Marshal(e.data)
+
+		r.nextUserEventID++
+		r.userEvents = append(r.userEvents, &models.UserEvent{
+			ID:        r.nextUserEventID,
+			UserID:    e.userID,
+			Type:      e.eventType,
+			CreatedAt: now,
+			Data:      payload,
+		})
+	}
+}
+
+// listUserEvents must be called with r.mu held.
+func (r *MemoryRepository) listUserEvents(after, limit int, match func(*models.UserEvent) bool) []*models.UserEvent {
+	i, _ := sort.Find(len(r.userEvents), func(i int) int {
+		return after + 1 - r.userEvents[i].ID
+	})
+
+	events := []*models.UserEvent{}
+	for _, e := range r.userEvents[i:] {
+		if len(events) == limit {
+			break
+		}
+		if match(e) {
+			c := *e
+			events = append(events, &c)
+		}
+	}
+
+	return events
+}
+
+func (r *MemoryRepository) ListUserEvents(ctx context.Context, userID, after, limit int) ([]*models.UserEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.listUserEvents(after, limit, func(e *models.UserEvent) bool {
+		return e.UserID == userID
+	}), nil
+}
+
+func (r *MemoryRepository) ListAllUserEvents(ctx context.Context, after, limit int) ([]*models.UserEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.listUserEvents(after, limit, func(*models.UserEvent) bool {
+		return true
+	}), nil
+}
+
+func (r *MemoryRepository) LastUserEventID(ctx context.Context) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.userEvents) == 0 {
+		return 0, nil
+	}
+	return r.userEvents[len(r.userEvents)-1].ID, nil
+}
+
+func (r *MemoryRepository) PurgeUserEvents(ctx context.Context, olderThan time.Duration) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cutoff := time.Now().Add(-olderThan)
+	kept := r.userEvents[:0]
+
+	for _, e := range r.userEvents {
+		if e.CreatedAt.Before(cutoff) {
+			continue
+		}
+		kept = append(kept, e)
+	}
+
+	n := len(r.userEvents) - len(kept)
+	r.userEvents = kept
+
+	return n, nil
+}
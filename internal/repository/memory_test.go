@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"merch-shop/internal/models"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MemoryRepository_SendCoinConcurrent(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	alice := &models.User{Username: "alice", PasswordHash: "hash"}
+	bob := &models.User{Username: "bob", PasswordHash: "hash"}
+	assert.NoError(t, repo.Add(ctx, alice))
+	assert.NoError(t, repo.Add(ctx, bob))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			repo.SendCoin(ctx, alice.ID, bob.ID, 7)
+		}()
+		go func() {
+			defer wg.Done()
+			repo.SendCoin(ctx, bob.ID, alice.ID, 11)
+		}()
+	}
+	wg.Wait()
+
+	aliceBalance, err := repo.GetBalance(ctx, alice.ID)
+	assert.NoError(t, err)
+	bobBalance, err := repo.GetBalance(ctx, bob.ID)
+	assert.NoError(t, err)
+
+	assert.GreaterOrEqual(t, aliceBalance, 0)
+	assert.GreaterOrEqual(t, bobBalance, 0)
+	assert.Equal(t, 2*defaultBalance, aliceBalance+bobBalance)
+}
+
+func Test_MemoryRepository_Add(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	user := &models.User{Username: "alice", PasswordHash: "hash"}
+	assert.NoError(t, repo.Add(ctx, user))
+	assert.NotZero(t, user.ID)
+	assert.False(t, user.CreatedAt.IsZero())
+
+	err := repo.Add(ctx, &models.User{Username: "alice", PasswordHash: "other"})
+	assert.ErrorIs(t, err, ErrDuplicateRecord)
+
+	got, err := repo.GetByUsername(ctx, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+	assert.Equal(t, "hash", got.PasswordHash)
+}
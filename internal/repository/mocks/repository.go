@@ -7,6 +7,8 @@ import (
 	models "merch-shop/internal/models"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // Repository is an autogenerated mock type for the Repository type
@@ -33,16 +35,76 @@ func (_m *Repository) Add(ctx context.Context, u *models.User) error {
 }
 
 // BuyItem provides a mock function with given fields: ctx, userID, itemName
-func (_m *Repository) BuyItem(ctx context.Context, userID int, itemName string) error {
+func (_m *Repository) BuyItem(ctx context.Context, userID int, itemName string) (*models.Purchase, error) {
 	ret := _m.Called(ctx, userID, itemName)
 
 	if len(ret) == 0 {
 		panic("no return value specified for BuyItem")
 	}
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, int, string) error); ok {
+	var r0 *models.Purchase
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) (*models.Purchase, error)); ok {
+		return rf(ctx, userID, itemName)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) *models.Purchase); ok {
 		r0 = rf(ctx, userID, itemName)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Purchase)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string) error); ok {
+		r1 = rf(ctx, userID, itemName)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClaimDeliveries provides a mock function with given fields: ctx, lease, limit
+func (_m *Repository) ClaimDeliveries(ctx context.Context, lease time.Duration, limit int) ([]*models.WebhookDelivery, error) {
+	ret := _m.Called(ctx, lease, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimDeliveries")
+	}
+
+	var r0 []*models.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Duration, int) ([]*models.WebhookDelivery, error)); ok {
+		return rf(ctx, lease, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Duration, int) []*models.WebhookDelivery); ok {
+		r0 = rf(ctx, lease, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.WebhookDelivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Duration, int) error); ok {
+		r1 = rf(ctx, lease, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeactivateUser provides a mock function with given fields: ctx, userID
+func (_m *Repository) DeactivateUser(ctx context.Context, userID int) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeactivateUser")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, userID)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -50,6 +112,34 @@ func (_m *Repository) BuyItem(ctx context.Context, userID int, itemName string)
 	return r0
 }
 
+// DispatchEvents provides a mock function with given fields: ctx, endpoints, limit
+func (_m *Repository) DispatchEvents(ctx context.Context, endpoints map[string][]string, limit int) (int, error) {
+	ret := _m.Called(ctx, endpoints, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DispatchEvents")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, map[string][]string, int) (int, error)); ok {
+		return rf(ctx, endpoints, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, map[string][]string, int) int); ok {
+		r0 = rf(ctx, endpoints, limit)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, map[string][]string, int) error); ok {
+		r1 = rf(ctx, endpoints, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetBalance provides a mock function with given fields: ctx, userID
 func (_m *Repository) GetBalance(ctx context.Context, userID int) (int, error) {
 	ret := _m.Called(ctx, userID)
@@ -138,6 +228,36 @@ func (_m *Repository) GetCoinHistory(ctx context.Context, userID int) (*models.C
 	return r0, r1
 }
 
+// GetCredentials provides a mock function with given fields: ctx, username
+func (_m *Repository) GetCredentials(ctx context.Context, username string) (*models.User, error) {
+	ret := _m.Called(ctx, username)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCredentials")
+	}
+
+	var r0 *models.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.User, error)); ok {
+		return rf(ctx, username)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.User); ok {
+		r0 = rf(ctx, username)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, username)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetInventory provides a mock function with given fields: ctx, userID
 func (_m *Repository) GetInventory(ctx context.Context, userID int) ([]*models.InventoryItem, error) {
 	ret := _m.Called(ctx, userID)
@@ -168,22 +288,482 @@ func (_m *Repository) GetInventory(ctx context.Context, userID int) ([]*models.I
 	return r0, r1
 }
 
+// GetLeaderboard provides a mock function with given fields: ctx, mode, since, limit
+func (_m *Repository) GetLeaderboard(ctx context.Context, mode string, since time.Time, limit int) ([]*models.LeaderboardEntry, error) {
+	ret := _m.Called(ctx, mode, since, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetLeaderboard")
+	}
+
+	var r0 []*models.LeaderboardEntry
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, int) ([]*models.LeaderboardEntry, error)); ok {
+		return rf(ctx, mode, since, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, int) []*models.LeaderboardEntry); ok {
+		r0 = rf(ctx, mode, since, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.LeaderboardEntry)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time, int) error); ok {
+		r1 = rf(ctx, mode, since, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetProfile provides a mock function with given fields: ctx, username
+func (_m *Repository) GetProfile(ctx context.Context, username string) (*models.UserProfile, error) {
+	ret := _m.Called(ctx, username)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetProfile")
+	}
+
+	var r0 *models.UserProfile
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.UserProfile, error)); ok {
+		return rf(ctx, username)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.UserProfile); ok {
+		r0 = rf(ctx, username)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.UserProfile)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, username)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GrantCoins provides a mock function with given fields: ctx, userID, amount
+func (_m *Repository) GrantCoins(ctx context.Context, userID int, amount int) (int, error) {
+	ret := _m.Called(ctx, userID, amount)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GrantCoins")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) (int, error)); ok {
+		return rf(ctx, userID, amount)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) int); ok {
+		r0 = rf(ctx, userID, amount)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, int) error); ok {
+		r1 = rf(ctx, userID, amount)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LastUserEventID provides a mock function with given fields: ctx
+func (_m *Repository) LastUserEventID(ctx context.Context) (int, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LastUserEventID")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (int, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) int); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListAllUserEvents provides a mock function with given fields: ctx, after, limit
+func (_m *Repository) ListAllUserEvents(ctx context.Context, after int, limit int) ([]*models.UserEvent, error) {
+	ret := _m.Called(ctx, after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListAllUserEvents")
+	}
+
+	var r0 []*models.UserEvent
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) ([]*models.UserEvent, error)); ok {
+		return rf(ctx, after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) []*models.UserEvent); ok {
+		r0 = rf(ctx, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.UserEvent)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, int) error); ok {
+		r1 = rf(ctx, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListDeliveries provides a mock function with given fields: ctx, status, after, limit
+func (_m *Repository) ListDeliveries(ctx context.Context, status string, after int, limit int) ([]*models.WebhookDelivery, error) {
+	ret := _m.Called(ctx, status, after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListDeliveries")
+	}
+
+	var r0 []*models.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, int) ([]*models.WebhookDelivery, error)); ok {
+		return rf(ctx, status, after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, int) []*models.WebhookDelivery); ok {
+		r0 = rf(ctx, status, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.WebhookDelivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, int, int) error); ok {
+		r1 = rf(ctx, status, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListItems provides a mock function with given fields: ctx
+func (_m *Repository) ListItems(ctx context.Context) ([]*models.Item, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListItems")
+	}
+
+	var r0 []*models.Item
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]*models.Item, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []*models.Item); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Item)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListUserEvents provides a mock function with given fields: ctx, userID, after, limit
+func (_m *Repository) ListUserEvents(ctx context.Context, userID int, after int, limit int) ([]*models.UserEvent, error) {
+	ret := _m.Called(ctx, userID, after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListUserEvents")
+	}
+
+	var r0 []*models.UserEvent
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, int) ([]*models.UserEvent, error)); ok {
+		return rf(ctx, userID, after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, int) []*models.UserEvent); ok {
+		r0 = rf(ctx, userID, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.UserEvent)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, int, int) error); ok {
+		r1 = rf(ctx, userID, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkDelivered provides a mock function with given fields: ctx, deliveryID, leaseID
+func (_m *Repository) MarkDelivered(ctx context.Context, deliveryID int, leaseID int) error {
+	ret := _m.Called(ctx, deliveryID, leaseID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkDelivered")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) error); ok {
+		r0 = rf(ctx, deliveryID, leaseID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MarkFailed provides a mock function with given fields: ctx, deliveryID, leaseID, lastError, retryIn, dead
+func (_m *Repository) MarkFailed(ctx context.Context, deliveryID int, leaseID int, lastError string, retryIn time.Duration, dead bool) error {
+	ret := _m.Called(ctx, deliveryID, leaseID, lastError, retryIn, dead)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkFailed")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, string, time.Duration, bool) error); ok {
+		r0 = rf(ctx, deliveryID, leaseID, lastError, retryIn, dead)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PurgeDeliveries provides a mock function with given fields: ctx, olderThan
+func (_m *Repository) PurgeDeliveries(ctx context.Context, olderThan time.Duration) (int, error) {
+	ret := _m.Called(ctx, olderThan)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeDeliveries")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Duration) (int, error)); ok {
+		return rf(ctx, olderThan)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Duration) int); ok {
+		r0 = rf(ctx, olderThan)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Duration) error); ok {
+		r1 = rf(ctx, olderThan)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeUserEvents provides a mock function with given fields: ctx, olderThan
+func (_m *Repository) PurgeUserEvents(ctx context.Context, olderThan time.Duration) (int, error) {
+	ret := _m.Called(ctx, olderThan)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeUserEvents")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Duration) (int, error)); ok {
+		return rf(ctx, olderThan)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Duration) int); ok {
+		r0 = rf(ctx, olderThan)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Duration) error); ok {
+		r1 = rf(ctx, olderThan)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReplayDelivery provides a mock function with given fields: ctx, deliveryID
+func (_m *Repository) ReplayDelivery(ctx context.Context, deliveryID int) (*models.WebhookDelivery, error) {
+	ret := _m.Called(ctx, deliveryID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReplayDelivery")
+	}
+
+	var r0 *models.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (*models.WebhookDelivery, error)); ok {
+		return rf(ctx, deliveryID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) *models.WebhookDelivery); ok {
+		r0 = rf(ctx, deliveryID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.WebhookDelivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, deliveryID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SearchUsers provides a mock function with given fields: ctx, prefix, after, limit
+func (_m *Repository) SearchUsers(ctx context.Context, prefix string, after string, limit int) ([]*models.UserProfile, error) {
+	ret := _m.Called(ctx, prefix, after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SearchUsers")
+	}
+
+	var r0 []*models.UserProfile
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int) ([]*models.UserProfile, error)); ok {
+		return rf(ctx, prefix, after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, int) []*models.UserProfile); ok {
+		r0 = rf(ctx, prefix, after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.UserProfile)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, int) error); ok {
+		r1 = rf(ctx, prefix, after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SendCoin provides a mock function with given fields: ctx, senderID, receiverID, amount
-func (_m *Repository) SendCoin(ctx context.Context, senderID int, receiverID int, amount int) error {
+func (_m *Repository) SendCoin(ctx context.Context, senderID int, receiverID int, amount int) (*models.Transfer, error) {
 	ret := _m.Called(ctx, senderID, receiverID, amount)
 
 	if len(ret) == 0 {
 		panic("no return value specified for SendCoin")
 	}
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, int, int, int) error); ok {
+	var r0 *models.Transfer
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, int) (*models.Transfer, error)); ok {
+		return rf(ctx, senderID, receiverID, amount)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, int, int) *models.Transfer); ok {
 		r0 = rf(ctx, senderID, receiverID, amount)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Transfer)
+		}
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(context.Context, int, int, int) error); ok {
+		r1 = rf(ctx, senderID, receiverID, amount)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetItemPrice provides a mock function with given fields: ctx, itemName, price
+func (_m *Repository) SetItemPrice(ctx context.Context, itemName string, price int) (*models.Item, error) {
+	ret := _m.Called(ctx, itemName, price)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetItemPrice")
+	}
+
+	var r0 *models.Item
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) (*models.Item, error)); ok {
+		return rf(ctx, itemName, price)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) *models.Item); ok {
+		r0 = rf(ctx, itemName, price)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Item)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = rf(ctx, itemName, price)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateProfile provides a mock function with given fields: ctx, userID, displayName, avatarURL, leaderboardOptOut
+func (_m *Repository) UpdateProfile(ctx context.Context, userID int, displayName *string, avatarURL *string, leaderboardOptOut *bool) (*models.UserProfile, error) {
+	ret := _m.Called(ctx, userID, displayName, avatarURL, leaderboardOptOut)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateProfile")
+	}
+
+	var r0 *models.UserProfile
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, *string, *string, *bool) (*models.UserProfile, error)); ok {
+		return rf(ctx, userID, displayName, avatarURL, leaderboardOptOut)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, *string, *string, *bool) *models.UserProfile); ok {
+		r0 = rf(ctx, userID, displayName, avatarURL, leaderboardOptOut)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.UserProfile)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, *string, *string, *bool) error); ok {
+		r1 = rf(ctx, userID, displayName, avatarURL, leaderboardOptOut)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
 // NewRepository creates a new instance of Repository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
@@ -3,90 +3,121 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
-	"fmt"
 	"merch-shop/internal/models"
+	"time"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 type Repository interface {
 	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	// GetCredentials returns the user whatever their active state, with IsActive set, so a
+	// login can check the password before telling a deactivated user apart.
+	GetCredentials(ctx context.Context, username string) (*models.User, error)
 	Add(ctx context.Context, u *models.User) error
-	BuyItem(ctx context.Context, userID int, itemName string) error
-	SendCoin(ctx context.Context, senderID, receiverID int, amount int) error
+	BuyItem(ctx context.Context, userID int, itemName string) (*models.Purchase, error)
+	SendCoin(ctx context.Context, senderID, receiverID int, amount int) (*models.Transfer, error)
 	GetBalance(ctx context.Context, userID int) (int, error)
 	GetInventory(ctx context.Context, userID int) ([]*models.InventoryItem, error)
 	GetCoinHistory(ctx context.Context, userID int) (*models.CoinHistory, error)
+	GetProfile(ctx context.Context, username string) (*models.UserProfile, error)
+	// SearchUsers returns up to limit active users whose username starts with prefix and
+	// sorts after the given username, ordered by username byte by byte.
+	SearchUsers(ctx context.Context, prefix, after string, limit int) ([]*models.UserProfile, error)
+	// UpdateProfile sets the profile fields that are not nil and returns the updated profile.
+	UpdateProfile(ctx context.Context, userID int, displayName, avatarURL *string, leaderboardOptOut *bool) (*models.UserProfile, error)
+	// GetLeaderboard returns up to limit active users who did not opt out, ordered by their score
+	// in the given mode. Transfers made before since are not counted, items are counted regardless.
+	GetLeaderboard(ctx context.Context, mode string, since time.Time, limit int) ([]*models.LeaderboardEntry, error)
+
+	// DeactivateUser hides the user from the shop, their history is kept.
+	DeactivateUser(ctx context.Context, userID int) error
+	// GrantCoins adds amount coins to the balance of an active user and returns the new balance.
+	GrantCoins(ctx context.Context, userID, amount int) (int, error)
+	// ListItems returns the items on sale ordered by type.
+	ListItems(ctx context.Context) ([]*models.Item, error)
+	// SetItemPrice changes the price of an item, putting it on sale if it is new.
+	SetItemPrice(ctx context.Context, itemName string, price int) (*models.Item, error)
+
+	// Add, BuyItem and SendCoin write an event to the outbox in the same transaction as the change.
+	// DispatchEvents takes up to limit of them off the outbox, creating a pending delivery for every
+	// endpoint subscribed to the event type in endpoints, and returns how many events it took.
+	DispatchEvents(ctx context.Context, endpoints map[string][]string, limit int) (int, error)
+	// ClaimDeliveries returns up to limit pending deliveries that are due and postpones them by lease,
+	// so they are not claimed again while being sent. Every claim gives a delivery a new LeaseID.
+	ClaimDeliveries(ctx context.Context, lease time.Duration, limit int) ([]*models.WebhookDelivery, error)
+	// MarkDelivered and MarkFailed record the result of an attempt made under the claim with leaseID.
+	// They return ErrLeaseLost if the delivery was claimed again since, or is no longer pending.
+	MarkDelivered(ctx context.Context, deliveryID, leaseID int) error
+	// MarkFailed records a failed attempt. The delivery is retried after retryIn, or becomes dead if dead is set.
+	MarkFailed(ctx context.Context, deliveryID, leaseID int, lastError string, retryIn time.Duration, dead bool) error
+	// ListDeliveries returns up to limit deliveries in the given status with IDs greater than after, ordered by ID.
+	ListDeliveries(ctx context.Context, status string, after, limit int) ([]*models.WebhookDelivery, error)
+	// ReplayDelivery makes a dead delivery pending again with a fresh set of attempts.
+	ReplayDelivery(ctx context.Context, deliveryID int) (*models.WebhookDelivery, error)
+	// PurgeDeliveries removes deliveries that were delivered more than olderThan ago and returns how many.
+	PurgeDeliveries(ctx context.Context, olderThan time.Duration) (int, error)
+
+	// BuyItem and SendCoin also write events for the live streams of the users involved.
+	// PostgresRepository announces them on UserEventsChannel when the transaction commits.
+	// ListUserEvents returns up to limit events of the user with IDs greater than after, ordered by ID.
+	ListUserEvents(ctx context.Context, userID, after, limit int) ([]*models.UserEvent, error)
+	// ListAllUserEvents is ListUserEvents across all users.
+	ListAllUserEvents(ctx context.Context, after, limit int) ([]*models.UserEvent, error)
+	// LastUserEventID returns the greatest event ID, zero if there are no events.
+	LastUserEventID(ctx context.Context) (int, error)
+	// PurgeUserEvents removes events created more than olderThan ago and returns how many.
+	PurgeUserEvents(ctx context.Context, olderThan time.Duration) (int, error)
 }
 
+// UserEventsChannel is the Postgres NOTIFY channel the JSON encoded user events are sent to.
+const UserEventsChannel = "user_events"
+
 type PostgresRepository struct {
-	DB *sql.DB
+	sqlRepository
 }
 
 func NewPostgresRepository(db *sql.DB) *PostgresRepository {
 	return &PostgresRepository{
-		DB: db,
+		sqlRepository: sqlRepository{DB: db, dialect: postgresDialect},
 	}
 }
 
-func (r *PostgresRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
-	query := `
-	    SELECT id, password_hash, created_at
-	    FROM active_users
-	    WHERE username = $1`
-
-	user := &models.User{
-		Username: username,
-	}
-
-	err := r.DB.QueryRowContext(ctx, query, username).Scan(
-		&user.ID,
-		&user.PasswordHash,
-		&user.CreatedAt,
-	)
-
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrRecordNotFound
-		}
-		return nil, err
-	}
-
-	return user, nil
+// postgresDialect serializes balance changes with row locks, so writes run at read
+// committed and only the rows they touch wait for each other.
+var postgresDialect = &dialect{
+	name:   "PostgresRepository",
+	system: semconv.DBSystemPostgreSQL,
+
+	writeIsolation:    sql.LevelReadCommitted,
+	snapshotIsolation: sql.LevelRepeatableRead,
+
+	lockBalances: "FOR UPDATE OF coins",
+	skipLocked:   "FOR UPDATE SKIP LOCKED",
+
+	// COLLATE "C" matches idx_users_username_c, which serves both the prefix match and the keyset.
+	collate: ` COLLATE "C"`,
+	matchPrefix: func(prefix string) (string, any) {
+		return `username COLLATE "C" LIKE $1`, likePrefix(prefix)
+	},
+
+	nowPlus: func(placeholder string) string {
+		return "CURRENT_TIMESTAMP + " + placeholder + " * INTERVAL '1 millisecond'"
+	},
+	duration: func(d time.Duration) any { return d.Milliseconds() },
+	// created_at is a TIMESTAMP in UTC, a time in another zone would be compared by its wall clock.
+	timestamp: func(t time.Time) any { return t.UTC() },
+
+	isUniqueViolation: isUniqueViolation,
+	publishUserEvents: publishUserEvents,
 }
 
-func (r *PostgresRepository) Add(ctx context.Context, u *models.User) error {
-	tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	query := `
-	    INSERT INTO users(username, password_hash)
-	    VALUES ($1, $2)
-	    RETURNING id, created_at`
-
-	args := []any{u.Username, u.PasswordHash}
-
-	err = tx.QueryRowContext(ctx, query, args...).Scan(&u.ID, &u.CreatedAt)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-
-	query = `
-	    INSERT INTO coins(user_id)
-	    VALUES ($1)`
-
-	_, err = tx.ExecContext(ctx, query, u.ID)
-	if err != nil {
-		return err
-	}
-
-	return tx.Commit()
-}
+func (r *PostgresRepository) GetItemByName(ctx context.Context, itemName string) (_ *models.Item, err error) {
+	ctx, span := r.startSpan(ctx, "GetItemByName")
+	defer func() { endSpan(span, err) }()
 
-func (r *PostgresRepository) GetItemByName(ctx context.Context, itemName string) (*models.Item, error) {
 	query := `
 	    SELECT id, price
 	    FROM items
@@ -96,7 +127,8 @@ func (r *PostgresRepository) GetItemByName(ctx context.Context, itemName string)
 		Name: itemName,
 	}
 
-	err := r.DB.QueryRowContext(ctx, query, itemName).Scan(
+	traceQuery(ctx, query)
+	err = r.DB.QueryRowContext(ctx, query, itemName).Scan(
 		&item.ID,
 		&item.Price,
 	)
@@ -110,267 +142,28 @@ func (r *PostgresRepository) GetItemByName(ctx context.Context, itemName string)
 	return item, nil
 }
 
-func (r *PostgresRepository) BuyItem(ctx context.Context, userID int, itemName string) error {
-	tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+// publishUserEvents writes events within tx and notifies the listeners of every replica.
+// Postgres holds notifications back until tx commits and drops them if it rolls back.
+func publishUserEvents(ctx context.Context, tx *sql.Tx, events []userEvent) error {
+	stored, err := insertUserEvents(ctx, tx, events)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
 
-	query := `
-	     SELECT balance
-	     FROM coins
-	     JOIN active_users ON coins.user_id = active_users.id
-	     WHERE user_id = $1 FOR UPDATE`
-
-	var balance int
-	err = tx.QueryRowContext(ctx, query, userID).Scan(&balance)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("user: %w", ErrRecordNotFound)
-		}
-		return err
-	}
+	query := `SELECT pg_notify($1, $2)`
 
-	query = `
-	    SELECT id, price
-	    FROM item
-	    WHERE type = $1`
-
-	var item models.Item
-
-	err = tx.QueryRowContext(ctx, query, itemName).Scan(
-		&item.ID,
-		&item.Price,
-	)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("item: %w", ErrRecordNotFound)
-		}
-		return err
-	}
-
-	if err := checkBalance(balance, item.Price); err != nil {
-		return err
-	}
-
-	query = `
-	    INSERT INTO inventory(user_id, item_id)
-	    VALUES ($1, $2)
-	    ON CONFLICT (user_id, item_id)
-	    DO UPDATE SET quantity = inventory.quantity + 1`
-
-	args := []any{userID, item.ID}
-
-	_, err = tx.ExecContext(ctx, query, args...)
-	if err != nil {
-		return err
-	}
-
-	query = `
-	    UPDATE coins
-	    SET balance = balance - $2
-	    WHERE user_id = $1`
-
-	args = []any{userID, item.Price}
-
-	_, err = tx.ExecContext(ctx, query, args...)
-	if err != nil {
-		return err
-	}
-
-	return tx.Commit()
-}
-
-func (r *PostgresRepository) SendCoin(ctx context.Context, senderID, receiverID int, amount int) error {
-	tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	query := `
-	     SELECT balance
-	     FROM coins
-	     JOIN active_users ON coins.user_id = active_users.id
-	     WHERE user_id = $1 FOR UPDATE`
-
-	var balance int
-	err = tx.QueryRowContext(ctx, query, senderID).Scan(&balance)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("sender user: %w", ErrRecordNotFound)
-		}
-		return err
-	}
-
-	if err := checkBalance(balance, amount); err != nil {
-		return err
-	}
-
-	query = `
-	     INSERT INTO transaction(sender_id, receiver_id, amount)
-	     VALUES ($1, $2, $3)`
-
-	args := []any{senderID, receiverID, amount}
-
-	_, err = tx.ExecContext(ctx, query, args...)
-	if err != nil {
-		return err
-	}
-
-	query = `
-	    UPDATE coins
-	    SET balance = CASE
-	    WHEN user_id = $1 THEN balance - $3
-	    WHEN user_id = $2 THEN balance + $3
-	    ELSE balance
-	    END
-	    WHERE user_id in ($1, $2)`
-
-	_, err = tx.ExecContext(ctx, query, args...)
-	if err != nil {
-		return err
-	}
-
-	return tx.Commit()
-}
-
-func (r *PostgresRepository) GetBalance(ctx context.Context, userID int) (int, error) {
-	query := `
-	    SELECT balance
-	    FROM coins
-	    JOIN active_users ON coins.user_id = active_users.id
-	    WHERE user_id = $1`
-
-	var balance int
-
-	err := r.DB.QueryRowContext(ctx, query, userID).Scan(&balance)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return 0, fmt.Errorf("user: %w", ErrRecordNotFound)
-		}
-		return 0, err
-	}
-
-	return balance, nil
-}
-
-func (r *PostgresRepository) GetInventory(ctx context.Context, userID int) ([]*models.InventoryItem, error) {
-	query := `
-	    SELECT it.type, i.quantity
-	    FROM inventory AS i
-	    JOIN item AS it ON i.item_id = it.id
-	    WHERE user_id = $1`
-
-	rows, err := r.DB.QueryContext(ctx, query, userID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	inventory := []*models.InventoryItem{}
-
-	for rows.Next() {
-		var item models.InventoryItem
-		err := rows.Scan(
-			&item.Type,
-			&item.Quantity,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		inventory = append(inventory, &item)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return inventory, nil
-}
-
-func (r *PostgresRepository) GetCoinHistory(ctx context.Context, userID int) (*models.CoinHistory, error) {
-	tx, err := r.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback()
-
-	query := `
-	    SELECT u1.username, t.amount
-	    FROM transaction AS t
-	    JOIN users AS u1 ON t.sender_id = u1.id
-	    JOIN users AS u2 ON t.receiver_id = u2.id
-	    WHERE t.receiver_id = $1`
-
-	rows, err := tx.QueryContext(ctx, query, userID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	received := []*models.CoinTransaction{}
-
-	for rows.Next() {
-		var r models.CoinTransaction
-		err := rows.Scan(
-			&r.FromUser,
-			&r.Amount,
-		)
+	for _, e := range stored {
+		// A payload is limited to 8000 bytes, user events are far smaller.
+		payload, err := json.Marshal(e)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		received = append(received, &r)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-
-	query = `
-	    SELECT u2.username, t.amount
-	    FROM transaction AS t
-	    JOIN users AS u1 ON t.sender_id = u1.id
-	    JOIN users AS u2 ON t.receiver_id = u2.id
-	    WHERE t.sender_id = $1`
-
-	rows, err = tx.QueryContext(ctx, query, userID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	sent := []*models.CoinTransaction{}
-
-	for rows.Next() {
-		var s models.CoinTransaction
-		err := rows.Scan(
-			&s.ToUser,
-			&s.Amount,
-		)
-		if err != nil {
-			return nil, err
+		traceQuery(ctx, query)
+		if _, err := tx.ExecContext(ctx, query, UserEventsChannel, string(payload)); err != nil {
+			return err
 		}
-
-		sent = append(sent, &s)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-
-	coinHistory := &models.CoinHistory{
-		Received: received,
-		Sent:     sent,
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		return nil, err
 	}
 
-	return coinHistory, nil
+	return nil
 }
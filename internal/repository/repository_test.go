@@ -0,0 +1,14 @@
+package repository_test
+
+import (
+	"merch-shop/internal/repository/repotest"
+	"testing"
+)
+
+func Test_RepositoryContract(t *testing.T) {
+	for name, newRepo := range repotest.Backends {
+		t.Run(name, func(t *testing.T) {
+			repotest.Run(t, newRepo)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package repotest
+
+import (
+	"context"
+	"log/slog"
+	"merch-shop/internal/config"
+	"merch-shop/internal/dbinit"
+	"merch-shop/internal/migrate"
+	"merch-shop/internal/repository"
+	"merch-shop/migrations"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// PostgresURLEnv names the variable with the URL of a Postgres database the tests may write to.
+// Postgres backed tests are skipped when it is not set.
+const PostgresURLEnv = "TEST_POSTGRES_URL"
+
+// NewMemory returns an empty in-memory repository.
+func NewMemory(t *testing.T) Repository {
+	return repository.NewMemoryRepository()
+}
+
+// NewSQLite returns a repository backed by a migrated SQLite file in a temporary directory.
+func NewSQLite(t *testing.T) Repository {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.DB.SQLitePath = filepath.Join(t.TempDir(), "shop.db")
+
+	db, err := dbinit.OpenSQLite(context.Background(), cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrate.New(db, migrations.SQLite, migrate.DialectSQLite)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+
+	return repository.NewSQLiteRepository(db)
+}
+
+// NewPostgres returns a repository backed by the migrated database at PostgresURLEnv.
+// The database is shared between tests, so they must use unique usernames.
+func NewPostgres(t *testing.T) Repository {
+	t.Helper()
+
+	url := os.Getenv(PostgresURLEnv)
+	if url == "" {
+		t.Skipf("%s is not set", PostgresURLEnv)
+	}
+
+	cfg := &config.Config{}
+	cfg.DB.URL = url
+
+	db, err := dbinit.OpenDB(context.Background(), cfg, slog.Default())
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrate.New(db, migrations.Postgres, migrate.DialectPostgres)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+
+	return repository.NewPostgresRepository(db)
+}
+
+// Backends lists constructors for every Repository implementation by name.
+var Backends = map[string]func(t *testing.T) Repository{
+	"memory":   NewMemory,
+	"sqlite":   NewSQLite,
+	"postgres": NewPostgres,
+}
@@ -0,0 +1,933 @@
+// Package repotest is a conformance suite for repository.Repository implementations.
+package repotest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repository"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const defaultBalance = 1000
+
+// Repository is the interface under test.
+type Repository = repository.Repository
+
+var userSeq atomic.Int64
+
+// Run runs the whole suite against repositories built by newRepo. Every subtest gets
+// a fresh repository, but the users it creates have unique names, so newRepo may
+// return repositories sharing one database.
+func Run(t *testing.T, newRepo func(t *testing.T) Repository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo Repository)
+	}{
+		{name: "add and get user", fn: testAddAndGet},
+		{name: "duplicate user", fn: testDuplicateUser},
+		{name: "new user defaults", fn: testNewUserDefaults},
+		{name: "buy item", fn: testBuyItem},
+		{name: "buy item errors", fn: testBuyItemErrors},
+		{name: "send coin", fn: testSendCoin},
+		{name: "send coin errors", fn: testSendCoinErrors},
+		{name: "inactive users", fn: testInactiveUsers},
+		{name: "concurrent transfers", fn: testConcurrentTransfers},
+		{name: "coin history", fn: testCoinHistory},
+		{name: "profiles", fn: testProfiles},
+		{name: "search users", fn: testSearchUsers},
+		{name: "leaderboard", fn: testLeaderboard},
+		{name: "webhook outbox", fn: testWebhookOutbox},
+		{name: "user events", fn: testUserEvents},
+		{name: "grant coins", fn: testGrantCoins},
+		{name: "balance limit", fn: testBalanceLimit},
+		{name: "items", fn: testItems},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+func addUser(t *testing.T, repo Repository) *models.User {
+	t.Helper()
+
+	return addNamedUser(t, repo, fmt.Sprintf("user%d_%d", time.Now().UnixNano(), userSeq.Add(1)))
+}
+
+func addNamedUser(t *testing.T, repo Repository, username string) *models.User {
+	t.Helper()
+
+	user := &models.User{
+		Username:     username,
+		PasswordHash: "hash",
+	}
+
+	require.NoError(t, repo.Add(context.Background(), user))
+	return user
+}
+
+func buy(t *testing.T, repo Repository, userID int, itemName string) *models.Purchase {
+	t.Helper()
+
+	purchase, err := repo.BuyItem(context.Background(), userID, itemName)
+	require.NoError(t, err)
+	return purchase
+}
+
+func send(t *testing.T, repo Repository, senderID, receiverID, amount int) *models.Transfer {
+	t.Helper()
+
+	transfer, err := repo.SendCoin(context.Background(), senderID, receiverID, amount)
+	require.NoError(t, err)
+	return transfer
+}
+
+func requireBalance(t *testing.T, repo Repository, userID, want int) {
+	t.Helper()
+
+	balance, err := repo.GetBalance(context.Background(), userID)
+	require.NoError(t, err)
+	assert.Equal(t, want, balance)
+}
+
+func inventoryOf(t *testing.T, repo Repository, userID int) map[string]int {
+	t.Helper()
+
+	inventory, err := repo.GetInventory(context.Background(), userID)
+	require.NoError(t, err)
+
+	got := map[string]int{}
+	for _, item := range inventory {
+		got[item.Type] = item.Quantity
+	}
+	return got
+}
+
+func testAddAndGet(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	_, err := repo.GetByUsername(ctx, "missing_user")
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	user := addUser(t, repo)
+	assert.NotZero(t, user.ID)
+	assert.False(t, user.CreatedAt.IsZero())
+
+	got, err := repo.GetByUsername(ctx, user.Username)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+	assert.Equal(t, user.Username, got.Username)
+	assert.Equal(t, user.PasswordHash, got.PasswordHash)
+	assert.True(t, got.IsActive)
+
+	_, err = repo.GetCredentials(ctx, "missing_user")
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	got, err = repo.GetCredentials(ctx, user.Username)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+	assert.Equal(t, user.PasswordHash, got.PasswordHash)
+	assert.True(t, got.IsActive)
+}
+
+func testDuplicateUser(t *testing.T, repo Repository) {
+	user := addUser(t, repo)
+
+	err := repo.Add(context.Background(), &models.User{Username: user.Username, PasswordHash: "other"})
+	assert.ErrorIs(t, err, repository.ErrDuplicateRecord)
+}
+
+func testNewUserDefaults(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	user := addUser(t, repo)
+
+	requireBalance(t, repo, user.ID, defaultBalance)
+
+	inventory, err := repo.GetInventory(ctx, user.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, inventory)
+	assert.Empty(t, inventory)
+
+	history, err := repo.GetCoinHistory(ctx, user.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, history.Received)
+	assert.NotNil(t, history.Sent)
+	assert.Empty(t, history.Received)
+	assert.Empty(t, history.Sent)
+}
+
+func testBuyItem(t *testing.T, repo Repository) {
+	user := addUser(t, repo)
+
+	first := buy(t, repo, user.ID, "cup")
+	assert.NotZero(t, first.ID)
+	assert.False(t, first.CreatedAt.IsZero())
+	assert.Equal(t, "cup", first.Item)
+	assert.Equal(t, 20, first.Price)
+	assert.Equal(t, 1, first.Quantity)
+	assert.Equal(t, defaultBalance-20, first.Balance)
+
+	second := buy(t, repo, user.ID, "cup")
+	assert.NotEqual(t, first.ID, second.ID)
+	assert.Equal(t, 2, second.Quantity)
+	assert.Equal(t, defaultBalance-2*20, second.Balance)
+
+	hoody := buy(t, repo, user.ID, "hoody")
+	assert.Equal(t, 1, hoody.Quantity)
+	assert.Equal(t, defaultBalance-2*20-300, hoody.Balance)
+
+	requireBalance(t, repo, user.ID, defaultBalance-2*20-300)
+	assert.Equal(t, map[string]int{"cup": 2, "hoody": 1}, inventoryOf(t, repo, user.ID))
+}
+
+func testBuyItemErrors(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	user := addUser(t, repo)
+
+	_, err := repo.BuyItem(ctx, user.ID, "beer")
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	buy(t, repo, user.ID, "pink-hoody")
+	buy(t, repo, user.ID, "hoody")
+
+	// 200 coins left
+	_, err = repo.BuyItem(ctx, user.ID, "hoody")
+	assert.ErrorIs(t, err, repository.ErrNotEnoughCoins)
+
+	_, err = repo.BuyItem(ctx, -1, "cup")
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	requireBalance(t, repo, user.ID, 200)
+	assert.Equal(t, map[string]int{"pink-hoody": 1, "hoody": 1}, inventoryOf(t, repo, user.ID))
+}
+
+func testSendCoin(t *testing.T, repo Repository) {
+	sender := addUser(t, repo)
+	receiver := addUser(t, repo)
+
+	transfer := send(t, repo, sender.ID, receiver.ID, 300)
+	assert.NotZero(t, transfer.ID)
+	assert.False(t, transfer.CreatedAt.IsZero())
+	assert.Equal(t, sender.Username, transfer.FromUser)
+	assert.Equal(t, receiver.Username, transfer.ToUser)
+	assert.Equal(t, 300, transfer.Amount)
+	assert.Equal(t, defaultBalance-300, transfer.Balance)
+
+	back := send(t, repo, receiver.ID, sender.ID, 50)
+	assert.NotEqual(t, transfer.ID, back.ID)
+	assert.Equal(t, defaultBalance+300-50, back.Balance)
+
+	requireBalance(t, repo, sender.ID, defaultBalance-300+50)
+	requireBalance(t, repo, receiver.ID, defaultBalance+300-50)
+
+	// The whole balance can be sent.
+	send(t, repo, sender.ID, receiver.ID, defaultBalance-300+50)
+	requireBalance(t, repo, sender.ID, 0)
+}
+
+func testSendCoinErrors(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	sender := addUser(t, repo)
+	receiver := addUser(t, repo)
+
+	_, err := repo.SendCoin(ctx, sender.ID, receiver.ID, defaultBalance+1)
+	assert.ErrorIs(t, err, repository.ErrNotEnoughCoins)
+
+	_, err = repo.SendCoin(ctx, -1, receiver.ID, 10)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	_, err = repo.SendCoin(ctx, sender.ID, -1, 10)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	requireBalance(t, repo, sender.ID, defaultBalance)
+	requireBalance(t, repo, receiver.ID, defaultBalance)
+
+	history, err := repo.GetCoinHistory(ctx, sender.ID)
+	require.NoError(t, err)
+	assert.Empty(t, history.Sent)
+}
+
+func testInactiveUsers(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	active := addUser(t, repo)
+	inactive := addUser(t, repo)
+
+	require.NoError(t, repo.DeactivateUser(ctx, inactive.ID))
+	assert.ErrorIs(t, repo.DeactivateUser(ctx, -1), repository.ErrRecordNotFound)
+
+	_, err := repo.GetByUsername(ctx, inactive.Username)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	// Logins still find the user, so the name isn't registered again.
+	credentials, err := repo.GetCredentials(ctx, inactive.Username)
+	require.NoError(t, err)
+	assert.Equal(t, inactive.ID, credentials.ID)
+	assert.Equal(t, inactive.PasswordHash, credentials.PasswordHash)
+	assert.False(t, credentials.IsActive)
+
+	_, err = repo.GetBalance(ctx, inactive.ID)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	_, err = repo.BuyItem(ctx, inactive.ID, "cup")
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	_, err = repo.SendCoin(ctx, inactive.ID, active.ID, 10)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	_, err = repo.SendCoin(ctx, active.ID, inactive.ID, 10)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	requireBalance(t, repo, active.ID, defaultBalance)
+
+	// The username stays taken.
+	err = repo.Add(ctx, &models.User{Username: inactive.Username, PasswordHash: "hash"})
+	assert.ErrorIs(t, err, repository.ErrDuplicateRecord)
+}
+
+func testConcurrentTransfers(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	users := []*models.User{addUser(t, repo), addUser(t, repo), addUser(t, repo)}
+
+	const workers = 30
+	const transfersPerWorker = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*transfersPerWorker)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < transfersPerWorker; i++ {
+				sender := users[(w+i)%len(users)]
+				receiver := users[(w+i+1+w%2)%len(users)]
+				if sender.ID == receiver.ID {
+					continue
+				}
+
+				_, err := repo.SendCoin(ctx, sender.ID, receiver.ID, 45+w)
+				if err != nil && !errors.Is(err, repository.ErrNotEnoughCoins) {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err, "transfers should only fail with not enough coins")
+	}
+
+	total := 0
+	for _, user := range users {
+		balance, err := repo.GetBalance(ctx, user.ID)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, balance, 0)
+		total += balance
+	}
+	assert.Equal(t, len(users)*defaultBalance, total, "coins should not appear or vanish")
+}
+
+func testCoinHistory(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	alice := addUser(t, repo)
+	bob := addUser(t, repo)
+	carl := addUser(t, repo)
+
+	send(t, repo, alice.ID, bob.ID, 10)
+	send(t, repo, alice.ID, bob.ID, 20)
+	send(t, repo, bob.ID, alice.ID, 5)
+	send(t, repo, carl.ID, alice.ID, 7)
+	buy(t, repo, alice.ID, "pen")
+
+	history, err := repo.GetCoinHistory(ctx, alice.ID)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []models.CoinTransaction{
+		{FromUser: bob.Username, Amount: 5},
+		{FromUser: carl.Username, Amount: 7},
+	}, deref(history.Received))
+	assert.ElementsMatch(t, []models.CoinTransaction{
+		{ToUser: bob.Username, Amount: 10},
+		{ToUser: bob.Username, Amount: 20},
+	}, deref(history.Sent))
+
+	history, err = repo.GetCoinHistory(ctx, carl.ID)
+	require.NoError(t, err)
+	assert.Empty(t, history.Received)
+	assert.Equal(t, []models.CoinTransaction{{ToUser: alice.Username, Amount: 7}}, deref(history.Sent))
+}
+
+func testProfiles(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	user := addUser(t, repo)
+
+	profile, err := repo.GetProfile(ctx, user.Username)
+	require.NoError(t, err)
+	assert.Equal(t, user.Username, profile.Username)
+	assert.Empty(t, profile.DisplayName)
+	assert.Empty(t, profile.AvatarURL)
+	assert.False(t, profile.JoinedAt.IsZero())
+
+	displayName := "Alice A."
+	profile, err = repo.UpdateProfile(ctx, user.ID, &displayName, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, displayName, profile.DisplayName)
+	assert.Empty(t, profile.AvatarURL)
+
+	avatarURL := "https://example.com/alice.png"
+	profile, err = repo.UpdateProfile(ctx, user.ID, nil, &avatarURL, nil)
+	require.NoError(t, err)
+	assert.Equal(t, displayName, profile.DisplayName, "fields that are not set should be kept")
+	assert.Equal(t, avatarURL, profile.AvatarURL)
+
+	empty := ""
+	_, err = repo.UpdateProfile(ctx, user.ID, &empty, nil, nil)
+	require.NoError(t, err)
+
+	profile, err = repo.GetProfile(ctx, user.Username)
+	require.NoError(t, err)
+	assert.Empty(t, profile.DisplayName)
+	assert.Equal(t, avatarURL, profile.AvatarURL)
+
+	_, err = repo.GetProfile(ctx, "missing_user")
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	_, err = repo.UpdateProfile(ctx, -1, &displayName, nil, nil)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	require.NoError(t, repo.DeactivateUser(ctx, user.ID))
+
+	_, err = repo.GetProfile(ctx, user.Username)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	_, err = repo.UpdateProfile(ctx, user.ID, &displayName, nil, nil)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+}
+
+func testSearchUsers(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	// The underscore must match literally, so "searchN_x" matches and "searchNxa" does not.
+	prefix := fmt.Sprintf("search%d_", time.Now().UnixNano())
+	addNamedUser(t, repo, prefix+"c")
+	addNamedUser(t, repo, prefix+"a")
+	addNamedUser(t, repo, prefix+"b")
+	addNamedUser(t, repo, strings.TrimSuffix(prefix, "_")+"xa")
+	inactive := addNamedUser(t, repo, prefix+"d")
+	require.NoError(t, repo.DeactivateUser(ctx, inactive.ID))
+
+	usernames := func(profiles []*models.UserProfile) []string {
+		names := []string{}
+		for _, p := range profiles {
+			names = append(names, p.Username)
+		}
+		return names
+	}
+
+	profiles, err := repo.SearchUsers(ctx, prefix, "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{prefix + "a", prefix + "b", prefix + "c"}, usernames(profiles))
+
+	profiles, err = repo.SearchUsers(ctx, prefix, "", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{prefix + "a", prefix + "b"}, usernames(profiles))
+
+	profiles, err = repo.SearchUsers(ctx, prefix, prefix+"b", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{prefix + "c"}, usernames(profiles))
+
+	profiles, err = repo.SearchUsers(ctx, strings.ToUpper(prefix), "", 10)
+	require.NoError(t, err)
+	assert.NotNil(t, profiles)
+	assert.Empty(t, profiles, "search should be case sensitive")
+}
+
+func testLeaderboard(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	alice := addUser(t, repo)
+	bob := addUser(t, repo)
+	carl := addUser(t, repo)
+	dave := addUser(t, repo)
+
+	send(t, repo, alice.ID, bob.ID, 100)
+	send(t, repo, carl.ID, bob.ID, 50)
+	send(t, repo, alice.ID, carl.ID, 30)
+	send(t, repo, dave.ID, carl.ID, 10)
+	buy(t, repo, dave.ID, "pen")
+	buy(t, repo, dave.ID, "pen")
+	buy(t, repo, dave.ID, "socks")
+	buy(t, repo, alice.ID, "cup")
+
+	ours := map[string]bool{alice.Username: true, bob.Username: true, carl.Username: true, dave.Username: true}
+
+	// Other tests may share the database, so only the users of this test are compared.
+	board := func(mode string, since time.Time) []models.LeaderboardEntry {
+		t.Helper()
+
+		entries, err := repo.GetLeaderboard(ctx, mode, since, 1<<20)
+		require.NoError(t, err)
+
+		result := []models.LeaderboardEntry{}
+		for _, e := range entries {
+			if ours[e.Username] {
+				result = append(result, models.LeaderboardEntry{Username: e.Username, Score: e.Score})
+			}
+		}
+		return result
+	}
+
+	hourAgo := time.Now().Add(-time.Hour)
+
+	assert.Equal(t, []models.LeaderboardEntry{
+		{Username: bob.Username, Score: 150},
+		{Username: carl.Username, Score: 40},
+	}, board(models.LeaderboardReceived, hourAgo))
+
+	assert.Equal(t, []models.LeaderboardEntry{
+		{Username: alice.Username, Score: 130},
+		{Username: carl.Username, Score: 50},
+		{Username: dave.Username, Score: 10},
+	}, board(models.LeaderboardSent, hourAgo))
+
+	assert.Equal(t, []models.LeaderboardEntry{
+		{Username: dave.Username, Score: 3},
+		{Username: alice.Username, Score: 1},
+	}, board(models.LeaderboardItems, hourAgo))
+
+	assert.Empty(t, board(models.LeaderboardReceived, time.Now().Add(time.Hour)), "transfers before the window should not count")
+
+	// The window is the same instant whatever the zone of since.
+	east, west := time.FixedZone("UTC+5", 5*60*60), time.FixedZone("UTC-5", -5*60*60)
+	assert.Equal(t, []models.LeaderboardEntry{
+		{Username: bob.Username, Score: 150},
+		{Username: carl.Username, Score: 40},
+	}, board(models.LeaderboardReceived, hourAgo.In(east)))
+	assert.Empty(t, board(models.LeaderboardReceived, time.Now().Add(time.Hour).In(west)))
+
+	optOut := true
+	profile, err := repo.UpdateProfile(ctx, bob.ID, nil, nil, &optOut)
+	require.NoError(t, err)
+	require.NotNil(t, profile.LeaderboardOptOut)
+	assert.True(t, *profile.LeaderboardOptOut)
+
+	require.NoError(t, repo.DeactivateUser(ctx, dave.ID))
+
+	assert.Equal(t, []models.LeaderboardEntry{
+		{Username: carl.Username, Score: 40},
+	}, board(models.LeaderboardReceived, hourAgo), "users who opted out should be hidden")
+
+	assert.Equal(t, []models.LeaderboardEntry{
+		{Username: alice.Username, Score: 1},
+	}, board(models.LeaderboardItems, hourAgo), "inactive users should be hidden")
+
+	_, err = repo.GetLeaderboard(ctx, "richest", hourAgo, 10)
+	assert.Error(t, err)
+}
+
+func testWebhookOutbox(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	// Other tests may share the database, so deliveries are told apart by a unique endpoint name.
+	endpoint := fmt.Sprintf("hook%d_%d", time.Now().UnixNano(), userSeq.Add(1))
+	endpoints := map[string][]string{}
+	for _, eventType := range models.EventTypes {
+		endpoints[eventType] = []string{endpoint}
+	}
+
+	alice := addUser(t, repo)
+	bob := addUser(t, repo)
+	send(t, repo, alice.ID, bob.ID, 10)
+	buy(t, repo, alice.ID, "cup")
+
+	_, err := repo.BuyItem(ctx, alice.ID, "missing_item")
+	require.Error(t, err)
+
+	for {
+		n, err := repo.DispatchEvents(ctx, endpoints, 100)
+		require.NoError(t, err)
+		if n == 0 {
+			break
+		}
+	}
+
+	claim := func() map[string]*models.WebhookDelivery {
+		t.Helper()
+
+		deliveries, err := repo.ClaimDeliveries(ctx, time.Hour, 1<<20)
+		require.NoError(t, err)
+
+		ours := map[string]*models.WebhookDelivery{}
+		for _, d := range deliveries {
+			if d.Endpoint != endpoint {
+				continue
+			}
+
+			var event models.Event
+			require.NoError(t, json.Unmarshal(d.Payload, &event))
+			assert.Equal(t, d.EventID, event.ID)
+			assert.Equal(t, d.EventType, event.Type)
+
+			key := event.Type
+			if event.Type == models.EventUserRegistered {
+				var data models.UserRegisteredEvent
+				require.NoError(t, json.Unmarshal(event.Data, &data))
+				key += " " + data.Username
+			}
+			ours[key] = d
+		}
+		return ours
+	}
+
+	deliveries := claim()
+	require.Len(t, deliveries, 4, "failed operations should not publish events")
+
+	for _, key := range []string{models.EventUserRegistered + " " + alice.Username, models.EventUserRegistered + " " + bob.Username} {
+		require.Contains(t, deliveries, key)
+		assert.Equal(t, models.DeliveryPending, deliveries[key].Status)
+		assert.Zero(t, deliveries[key].Attempts)
+	}
+
+	var event models.Event
+	var transfer models.TransferEvent
+	require.NoError(t, json.Unmarshal(deliveries[models.EventTransferCreated].Payload, &event))
+	require.NoError(t, json.Unmarshal(event.Data, &transfer))
+	assert.Equal(t, alice.Username, transfer.FromUser)
+	assert.Equal(t, bob.Username, transfer.ToUser)
+	assert.Equal(t, 10, transfer.Amount)
+
+	var purchase models.PurchaseEvent
+	require.NoError(t, json.Unmarshal(deliveries[models.EventPurchaseCreated].Payload, &event))
+	require.NoError(t, json.Unmarshal(event.Data, &purchase))
+	assert.Equal(t, alice.Username, purchase.Username)
+	assert.Equal(t, "cup", purchase.Item)
+	assert.Equal(t, 20, purchase.Price)
+
+	assert.Empty(t, claim(), "claimed deliveries should not be claimed again until the lease ends")
+
+	transferDelivery := deliveries[models.EventTransferCreated]
+	purchaseDelivery := deliveries[models.EventPurchaseCreated]
+
+	require.NoError(t, repo.MarkDelivered(ctx, transferDelivery.ID, transferDelivery.LeaseID))
+	require.NoError(t, repo.MarkFailed(ctx, purchaseDelivery.ID, purchaseDelivery.LeaseID, "connection refused", 0, false))
+
+	assert.ErrorIs(t, repo.MarkDelivered(ctx, transferDelivery.ID, transferDelivery.LeaseID), repository.ErrLeaseLost,
+		"a delivered delivery should not take another result")
+
+	retried := claim()
+	require.Len(t, retried, 1)
+	require.Contains(t, retried, models.EventPurchaseCreated)
+	assert.Equal(t, 1, retried[models.EventPurchaseCreated].Attempts)
+	assert.Equal(t, "connection refused", retried[models.EventPurchaseCreated].LastError)
+	assert.NotEqual(t, purchaseDelivery.LeaseID, retried[models.EventPurchaseCreated].LeaseID)
+
+	// The first claim's lease is gone, its late result must not overwrite the current attempt.
+	assert.ErrorIs(t, repo.MarkDelivered(ctx, purchaseDelivery.ID, purchaseDelivery.LeaseID), repository.ErrLeaseLost)
+	assert.ErrorIs(t, repo.MarkFailed(ctx, purchaseDelivery.ID, purchaseDelivery.LeaseID, "timeout", 0, false), repository.ErrLeaseLost)
+
+	require.NoError(t, repo.MarkFailed(ctx, purchaseDelivery.ID, retried[models.EventPurchaseCreated].LeaseID, "status 500", 0, true))
+	assert.Empty(t, claim(), "dead deliveries should not be retried")
+
+	find := func(status string, deliveryID int) *models.WebhookDelivery {
+		t.Helper()
+
+		deliveries, err := repo.ListDeliveries(ctx, status, deliveryID-1, 1)
+		require.NoError(t, err)
+		if len(deliveries) == 0 || deliveries[0].ID != deliveryID {
+			return nil
+		}
+		return deliveries[0]
+	}
+
+	dead := find(models.DeliveryDead, purchaseDelivery.ID)
+	require.NotNil(t, dead)
+	assert.Equal(t, 2, dead.Attempts)
+	assert.Equal(t, "status 500", dead.LastError)
+	assert.Nil(t, dead.DeliveredAt)
+
+	delivered := find(models.DeliveryDelivered, transferDelivery.ID)
+	require.NotNil(t, delivered)
+	require.NotNil(t, delivered.DeliveredAt)
+	assert.Empty(t, delivered.LastError)
+
+	replayed, err := repo.ReplayDelivery(ctx, purchaseDelivery.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeliveryPending, replayed.Status)
+	assert.Zero(t, replayed.Attempts)
+	assert.Equal(t, purchaseDelivery.Payload, replayed.Payload)
+
+	_, err = repo.ReplayDelivery(ctx, purchaseDelivery.ID)
+	assert.ErrorIs(t, err, repository.ErrDeliveryNotFound, "only dead deliveries can be replayed")
+
+	_, err = repo.ReplayDelivery(ctx, -1)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	assert.Contains(t, claim(), models.EventPurchaseCreated, "replayed deliveries should be due right away")
+
+	_, err = repo.PurgeDeliveries(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.NotNil(t, find(models.DeliveryDelivered, transferDelivery.ID), "recent deliveries should be kept")
+
+	n, err := repo.PurgeDeliveries(ctx, -time.Minute)
+	require.NoError(t, err)
+	assert.Positive(t, n)
+	assert.Nil(t, find(models.DeliveryDelivered, transferDelivery.ID))
+	assert.NotNil(t, find(models.DeliveryPending, purchaseDelivery.ID), "only delivered deliveries should be purged")
+}
+
+func testUserEvents(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	start, err := repo.LastUserEventID(ctx)
+	require.NoError(t, err)
+
+	alice := addUser(t, repo)
+	bob := addUser(t, repo)
+	transfer := send(t, repo, alice.ID, bob.ID, 10)
+	purchase := buy(t, repo, bob.ID, "cup")
+
+	_, err = repo.SendCoin(ctx, alice.ID, bob.ID, defaultBalance)
+	require.ErrorIs(t, err, repository.ErrNotEnoughCoins)
+
+	type event struct {
+		Type string
+		Data string
+	}
+
+	eventsOf := func(userID, after int) []event {
+		t.Helper()
+
+		events, err := repo.ListUserEvents(ctx, userID, after, 100)
+		require.NoError(t, err)
+
+		got := []event{}
+		for i, e := range events {
+			assert.Equal(t, userID, e.UserID)
+			assert.Greater(t, e.ID, after)
+			if i > 0 {
+				assert.Greater(t, e.ID, events[i-1].ID)
+			}
+			got = append(got, event{e.Type, string(e.Data)})
+		}
+		return got
+	}
+
+	marshal := func(v any) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	// Timestamps come back from the database in its own precision, the stored ones are used.
+	bobEvents, err := repo.ListUserEvents(ctx, bob.ID, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, bobEvents, 4)
+
+	var received models.TransferReceivedEvent
+	require.NoError(t, json.Unmarshal(bobEvents[0].Data, &received))
+	assert.Equal(t, transfer.ID, received.ID)
+	assert.Equal(t, alice.Username, received.FromUser)
+	assert.Equal(t, 10, received.Amount)
+
+	var purchased models.Purchase
+	require.NoError(t, json.Unmarshal(bobEvents[2].Data, &purchased))
+	assert.Equal(t, purchase.ID, purchased.ID)
+	assert.Equal(t, "cup", purchased.Item)
+	assert.Equal(t, 1, purchased.Quantity)
+	assert.Equal(t, defaultBalance+10-20, purchased.Balance)
+
+	assert.Equal(t, []event{
+		{models.UserEventBalanceChanged, marshal(models.BalanceChangedEvent{Balance: defaultBalance - 10})},
+	}, eventsOf(alice.ID, 0))
+
+	assert.Equal(t, []event{
+		{models.UserEventTransferReceived, string(bobEvents[0].Data)},
+		{models.UserEventBalanceChanged, marshal(models.BalanceChangedEvent{Balance: defaultBalance + 10})},
+		{models.UserEventPurchaseCreated, string(bobEvents[2].Data)},
+		{models.UserEventBalanceChanged, marshal(models.BalanceChangedEvent{Balance: defaultBalance + 10 - 20})},
+	}, eventsOf(bob.ID, 0))
+
+	// Resuming after an event returns only the later ones.
+	assert.Equal(t, []event{
+		{models.UserEventBalanceChanged, marshal(models.BalanceChangedEvent{Balance: defaultBalance + 10 - 20})},
+	}, eventsOf(bob.ID, bobEvents[2].ID))
+
+	limited, err := repo.ListUserEvents(ctx, bob.ID, 0, 2)
+	require.NoError(t, err)
+	assert.Len(t, limited, 2)
+
+	last, err := repo.LastUserEventID(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, last, bobEvents[3].ID)
+
+	all, err := repo.ListAllUserEvents(ctx, start, 1<<20)
+	require.NoError(t, err)
+
+	ours := 0
+	for _, e := range all {
+		assert.Greater(t, e.ID, start)
+		if e.UserID == alice.ID || e.UserID == bob.ID {
+			ours++
+		}
+	}
+	assert.Equal(t, 5, ours)
+
+	_, err = repo.PurgeUserEvents(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Len(t, eventsOf(bob.ID, 0), 4, "recent events are kept")
+
+	// The clock of the database may run ahead of ours, so anything created a minute from now counts as old.
+	_, err = repo.PurgeUserEvents(ctx, -time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, eventsOf(bob.ID, 0))
+}
+
+func deref(transactions []*models.CoinTransaction) []models.CoinTransaction {
+	result := make([]models.CoinTransaction, 0, len(transactions))
+	for _, t := range transactions {
+		result = append(result, *t)
+	}
+	return result
+}
+
+func testBalanceLimit(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	rich := addUser(t, repo)
+	sender := addUser(t, repo)
+
+	balance, err := repo.GrantCoins(ctx, rich.ID, math.MaxInt32-defaultBalance)
+	require.NoError(t, err)
+	assert.Equal(t, math.MaxInt32, balance)
+
+	_, err = repo.GrantCoins(ctx, rich.ID, 1)
+	assert.ErrorIs(t, err, repository.ErrBalanceLimit)
+
+	_, err = repo.SendCoin(ctx, sender.ID, rich.ID, 1)
+	assert.ErrorIs(t, err, repository.ErrBalanceLimit)
+
+	requireBalance(t, repo, rich.ID, math.MaxInt32)
+	requireBalance(t, repo, sender.ID, defaultBalance)
+
+	// Spending brings the balance back under the limit.
+	_, err = repo.SendCoin(ctx, rich.ID, sender.ID, 10)
+	require.NoError(t, err)
+	_, err = repo.GrantCoins(ctx, rich.ID, 10)
+	require.NoError(t, err)
+	requireBalance(t, repo, rich.ID, math.MaxInt32)
+}
+
+func testGrantCoins(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	user := addUser(t, repo)
+	inactive := addUser(t, repo)
+	require.NoError(t, repo.DeactivateUser(ctx, inactive.ID))
+
+	balance, err := repo.GrantCoins(ctx, user.ID, 50)
+	require.NoError(t, err)
+	assert.Equal(t, defaultBalance+50, balance)
+	requireBalance(t, repo, user.ID, defaultBalance+50)
+
+	events, err := repo.ListUserEvents(ctx, user.ID, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.UserEventBalanceChanged, events[0].Type)
+	assert.JSONEq(t, fmt.Sprintf(`{"balance":%d}`, defaultBalance+50), string(events[0].Data))
+
+	_, err = repo.GrantCoins(ctx, user.ID, 20)
+	require.NoError(t, err)
+
+	// Grants are kept in the history and published to webhooks.
+	history, err := repo.GetCoinHistory(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []models.CoinTransaction{{Amount: 50}, {Amount: 20}}, deref(history.Granted))
+	assert.Empty(t, history.Received)
+
+	endpoint := fmt.Sprintf("hook%d_%d", time.Now().UnixNano(), userSeq.Add(1))
+	for {
+		n, err := repo.DispatchEvents(ctx, map[string][]string{models.EventCoinsGranted: {endpoint}}, 100)
+		require.NoError(t, err)
+		if n == 0 {
+			break
+		}
+	}
+
+	deliveries, err := repo.ClaimDeliveries(ctx, time.Hour, 1<<20)
+	require.NoError(t, err)
+
+	granted := []int{}
+	for _, d := range deliveries {
+		if d.Endpoint != endpoint {
+			continue
+		}
+
+		var event models.Event
+		require.NoError(t, json.Unmarshal(d.Payload, &event))
+		assert.Equal(t, models.EventCoinsGranted, event.Type)
+
+		var grant models.GrantEvent
+		require.NoError(t, json.Unmarshal(event.Data, &grant))
+		if grant.Username == user.Username {
+			assert.NotZero(t, grant.ID)
+			assert.False(t, grant.CreatedAt.IsZero())
+			granted = append(granted, grant.Amount)
+		}
+	}
+	assert.ElementsMatch(t, []int{50, 20}, granted)
+
+	_, err = repo.GrantCoins(ctx, inactive.ID, 50)
+	assert.ErrorIs(t, err, repository.ErrUserNotFound)
+
+	_, err = repo.GrantCoins(ctx, -1, 50)
+	assert.ErrorIs(t, err, repository.ErrUserNotFound)
+}
+
+func testItems(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	// Items are shared by every test using the database, so only a new one is changed.
+	name := fmt.Sprintf("sticker-%d", userSeq.Add(1))
+
+	item, err := repo.SetItemPrice(ctx, name, 30)
+	require.NoError(t, err)
+	assert.Equal(t, name, item.Name)
+	assert.Equal(t, 30, item.Price)
+
+	changed, err := repo.SetItemPrice(ctx, name, 40)
+	require.NoError(t, err)
+	assert.Equal(t, item.ID, changed.ID)
+	assert.Equal(t, 40, changed.Price)
+
+	items, err := repo.ListItems(ctx)
+	require.NoError(t, err)
+
+	prices := map[string]int{}
+	for i, item := range items {
+		prices[item.Name] = item.Price
+		if i > 0 {
+			assert.Less(t, items[i-1].Name, item.Name)
+		}
+	}
+	assert.Equal(t, 20, prices["cup"])
+	assert.Equal(t, 40, prices[name])
+
+	user := addUser(t, repo)
+	purchase := buy(t, repo, user.ID, name)
+	assert.Equal(t, 40, purchase.Price)
+	assert.Equal(t, map[string]int{name: 1}, inventoryOf(t, repo, user.ID))
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLiteRepository stores data in a single SQLite file. The connection must be opened
+// with _txlock=immediate, so every writing transaction takes the write lock up front: that
+// gives BuyItem and SendCoin the same atomic balance checks as the row locks in
+// PostgresRepository. Read-only transactions begin deferred and don't block writers.
+type SQLiteRepository struct {
+	sqlRepository
+}
+
+func NewSQLiteRepository(db *sql.DB) *SQLiteRepository {
+	return &SQLiteRepository{
+		sqlRepository: sqlRepository{DB: db, dialect: sqliteDialect},
+	}
+}
+
+// sqliteDialect has no row locks: the database-wide write lock an immediate transaction
+// takes when it begins already runs writing transactions one after another.
+var sqliteDialect = &dialect{
+	name:   "SQLiteRepository",
+	system: semconv.DBSystemSqlite,
+
+	writeIsolation:    sql.LevelSerializable,
+	snapshotIsolation: sql.LevelSerializable,
+
+	// SQLite compares text byte by byte unless told otherwise. Its LIKE ignores case,
+	// so the prefix is compared directly to stay consistent with the other backends.
+	matchPrefix: func(prefix string) (string, any) {
+		return "substr(username, 1, length($1)) = $1", prefix
+	},
+
+	nowPlus: func(placeholder string) string {
+		return "datetime('now', " + placeholder + ")"
+	},
+	duration: func(d time.Duration) any { return sqliteModifier(d) },
+	// created_at holds CURRENT_TIMESTAMP text in UTC, so a bound must be formatted the same way to compare.
+	timestamp: func(t time.Time) any { return t.UTC().Format(time.DateTime) },
+
+	isUniqueViolation: isSQLiteUniqueViolation,
+	publishUserEvents: func(ctx context.Context, tx *sql.Tx, events []userEvent) error {
+		_, err := insertUserEvents(ctx, tx, events)
+		return err
+	},
+}
+
+// sqliteModifier formats d as a datetime() modifier. datetime() returns the same UTC text
+// as CURRENT_TIMESTAMP, so the results compare correctly with timestamp columns.
+func sqliteModifier(d time.Duration) string {
+	return fmt.Sprintf("%+.3f seconds", d.Seconds())
+}
+
+func isSQLiteUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
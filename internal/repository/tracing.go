@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"merch-shop/internal/requestid"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("merch-shop/internal/repository")
+
+// dbStatement is the attribute tracing backends index SQL under. Newer semantic
+// conventions renamed it to db.query.text, which Jaeger and Tempo don't show yet.
+const dbStatement = attribute.Key("db.statement")
+
+type statementsKey struct{}
+
+// statements collects the SQL run under a span, so a method running several
+// statements in one transaction reports all of them in order.
+type statements struct {
+	mu    sync.Mutex
+	items []string
+}
+
+// startSpan also tags the span with the request ID, so a failed query can be found
+// from the ID the client got in the error response.
+func startSpan(ctx context.Context, name string, dbSystem attribute.KeyValue) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{dbSystem}
+	if id := requestid.FromContext(ctx); id != "" {
+		attrs = append(attrs, attribute.String("request.id", id))
+	}
+
+	ctx, span := tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+
+	return context.WithValue(ctx, statementsKey{}, &statements{}), span
+}
+
+// endSpan marks the span failed if the method returned an error and ends it.
+// Methods defer it with their named error result, so every return path is covered.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+func (r *sqlRepository) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return startSpan(ctx, r.dialect.name+"."+name, r.dialect.system)
+}
+
+// traceQuery adds the statement to the db.statement attribute of the current span.
+func traceQuery(ctx context.Context, query string) {
+	s, ok := ctx.Value(statementsKey{}).(*statements)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	s.items = append(s.items, strings.TrimSpace(query))
+	statement := strings.Join(s.items, ";\n")
+	s.mu.Unlock()
+
+	trace.SpanFromContext(ctx).SetAttributes(dbStatement.String(statement))
+}
@@ -0,0 +1,61 @@
+package repository_test
+
+import (
+	"context"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repository"
+	"merch-shop/internal/repository/repotest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func Test_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	ctx := context.Background()
+	repo := repotest.NewSQLite(t)
+
+	require.NoError(t, repo.Add(ctx, &models.User{Username: "alice", PasswordHash: "hash"}))
+	_, err := repo.GetByUsername(ctx, "bob")
+	require.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	add, get := spans[0], spans[1]
+	assert.Equal(t, "SQLiteRepository.Add", add.Name())
+	assert.Equal(t, codes.Unset, add.Status().Code)
+	assert.Empty(t, add.Events())
+
+	statement := attributeValue(add, "db.statement")
+	assert.Contains(t, statement, "INSERT INTO users")
+	assert.Contains(t, statement, "INSERT INTO coins")
+	assert.Less(t, strings.Index(statement, "INSERT INTO users"), strings.Index(statement, "INSERT INTO coins"))
+
+	assert.Equal(t, "SQLiteRepository.GetByUsername", get.Name())
+	assert.Equal(t, codes.Error, get.Status().Code)
+	require.Len(t, get.Events(), 1)
+	assert.Equal(t, "exception", get.Events()[0].Name)
+	assert.Contains(t, attributeValue(get, "db.statement"), "FROM active_users")
+}
+
+func attributeValue(span sdktrace.ReadOnlySpan, key string) string {
+	for _, attr := range span.Attributes() {
+		if string(attr.Key) == key {
+			return attr.Value.AsString()
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	maxTxAttempts    = 5
+	txInitialBackoff = 10 * time.Millisecond
+)
+
+const (
+	pqSerializationFailure = "40001"
+	pqDeadlockDetected     = "40P01"
+	pqUniqueViolation      = "23505"
+)
+
+// withTx runs fn inside a transaction with the given isolation level and commits it.
+// Serialization failures, deadlocks and busy SQLite databases roll the transaction back
+// and run fn again after a jittered backoff, so fn must not leak state between attempts.
+func withTx(ctx context.Context, db *sql.DB, isolation sql.IsolationLevel, fn func(tx *sql.Tx) error) error {
+	return retryTx(ctx, db, &sql.TxOptions{Isolation: isolation}, fn)
+}
+
+// withReadOnlyTx is withTx for transactions that only read. SQLite begins them deferred
+// despite _txlock=immediate, so they don't wait for the write lock.
+func withReadOnlyTx(ctx context.Context, db *sql.DB, isolation sql.IsolationLevel, fn func(tx *sql.Tx) error) error {
+	return retryTx(ctx, db, &sql.TxOptions{Isolation: isolation, ReadOnly: true}, fn)
+}
+
+func retryTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	var err error
+
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = runTx(ctx, db, opts, fn)
+		if err == nil || !isRetryable(err) || attempt == maxTxAttempts {
+			return err
+		}
+
+		trace.SpanFromContext(ctx).AddEvent("retry transaction", trace.WithAttributes(
+			attribute.Int("attempt", attempt),
+			attribute.String("error", err.Error()),
+		))
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(txBackoff(attempt)):
+		}
+	}
+
+	return err
+}
+
+func runTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation
+}
+
+func isRetryable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == pqSerializationFailure || pqErr.Code == pqDeadlockDetected
+	}
+
+	// busy_timeout already waits for the lock, but a deferred transaction that has
+	// to upgrade it, or a timeout under heavy load, still fails with SQLITE_BUSY.
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code()&0xff == sqlite3.SQLITE_BUSY
+	}
+
+	return false
+}
+
+// txBackoff returns a random delay in [0, txInitialBackoff*2^(attempt-1)).
+func txBackoff(attempt int) time.Duration {
+	ceiling := txInitialBackoff << (attempt - 1)
+	return time.Duration(rand.Int64N(int64(ceiling)))
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_isRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "serialization failure", err: &pq.Error{Code: "40001"}, want: true},
+		{name: "deadlock", err: &pq.Error{Code: "40P01"}, want: true},
+		{name: "wrapped deadlock", err: fmt.Errorf("send coin: %w", &pq.Error{Code: "40P01"}), want: true},
+		{name: "check violation", err: &pq.Error{Code: "23514"}, want: false},
+		{name: "not enough coins", err: ErrNotEnoughCoins, want: false},
+		{name: "other error", err: errors.New("connection refused"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isRetryable(tt.err))
+		})
+	}
+}
+
+func Test_isRetryableSQLiteBusy(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "busy.db")
+
+	// Without busy_timeout the second writer fails at once instead of waiting.
+	open := func() *sql.DB {
+		db, err := sql.Open("sqlite", "file:"+path+"?_txlock=immediate")
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	holder, waiter := open(), open()
+
+	tx, err := holder.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	_, err = waiter.BeginTx(ctx, nil)
+	require.Error(t, err)
+	assert.True(t, isRetryable(fmt.Errorf("buy item: %w", err)))
+
+	// A read-only transaction doesn't ask for the write lock.
+	readTx, err := waiter.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	require.NoError(t, err)
+	assert.NoError(t, readTx.Rollback())
+}
+
+func Test_txBackoff(t *testing.T) {
+	for attempt := 1; attempt < maxTxAttempts; attempt++ {
+		got := txBackoff(attempt)
+		assert.GreaterOrEqual(t, got, 0*txInitialBackoff)
+		assert.Less(t, got, txInitialBackoff<<(attempt-1))
+	}
+}
+
+// stubConnector opens connections whose commits fail with the queued errors, so the
+// retry loop can be driven without a real serialization conflict.
+type stubConnector struct {
+	commitErrs []error
+	begins     int
+	commits    int
+	rollbacks  int
+}
+
+func (c *stubConnector) Connect(context.Context) (driver.Conn, error) { return stubConn{c}, nil }
+func (c *stubConnector) Driver() driver.Driver                        { return nil }
+
+type stubConn struct{ c *stubConnector }
+
+func (conn stubConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (conn stubConn) Close() error                        { return nil }
+func (conn stubConn) Begin() (driver.Tx, error) {
+	return conn.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (conn stubConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	conn.c.begins++
+	return stubTx(conn), nil
+}
+
+type stubTx struct{ c *stubConnector }
+
+func (tx stubTx) Commit() error {
+	tx.c.commits++
+	if len(tx.c.commitErrs) == 0 {
+		return nil
+	}
+	err := tx.c.commitErrs[0]
+	tx.c.commitErrs = tx.c.commitErrs[1:]
+	return err
+}
+
+func (tx stubTx) Rollback() error {
+	tx.c.rollbacks++
+	return nil
+}
+
+func Test_withTx_Retry(t *testing.T) {
+	serialization := &pq.Error{Code: pqSerializationFailure}
+	deadlock := &pq.Error{Code: pqDeadlockDetected}
+	checkViolation := &pq.Error{Code: "23514"}
+
+	tests := []struct {
+		name       string
+		commitErrs []error
+		fnErrs     []error
+		wantErr    error
+		wantCalls  int
+	}{
+		{name: "commits at once", wantCalls: 1},
+		{name: "retries commit conflicts", commitErrs: []error{serialization, deadlock}, wantCalls: 3},
+		{name: "retries conflicts from fn", fnErrs: []error{fmt.Errorf("send coin: %w", deadlock)}, wantCalls: 2},
+		{name: "gives up after max attempts",
+			commitErrs: []error{serialization, serialization, serialization, serialization, serialization, serialization},
+			wantErr:    serialization, wantCalls: maxTxAttempts},
+		{name: "does not retry other commit errors", commitErrs: []error{checkViolation}, wantErr: checkViolation, wantCalls: 1},
+		{name: "does not retry other fn errors", fnErrs: []error{ErrNotEnoughCoins}, wantErr: ErrNotEnoughCoins, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connector := &stubConnector{commitErrs: tt.commitErrs}
+			db := sql.OpenDB(connector)
+			t.Cleanup(func() { db.Close() })
+
+			calls := 0
+			err := withTx(context.Background(), db, sql.LevelSerializable, func(tx *sql.Tx) error {
+				calls++
+				if calls <= len(tt.fnErrs) {
+					return tt.fnErrs[calls-1]
+				}
+				return nil
+			})
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantCalls, calls)
+			assert.Equal(t, tt.wantCalls, connector.begins)
+			assert.Equal(t, connector.begins, connector.commits+connector.rollbacks,
+				"every attempt ends in a commit or a rollback")
+		})
+	}
+}
+
+func Test_withTx_RetryStopsOnCancel(t *testing.T) {
+	connector := &stubConnector{}
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+	err := withTx(ctx, db, sql.LevelSerializable, func(tx *sql.Tx) error {
+		calls++
+		cancel()
+		return &pq.Error{Code: pqSerializationFailure}
+	})
+
+	assert.True(t, isRetryable(err))
+	assert.Equal(t, 1, calls)
+}
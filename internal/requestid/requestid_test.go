@@ -0,0 +1,45 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Context(t *testing.T) {
+	assert.Empty(t, FromContext(context.Background()))
+
+	ctx := NewContext(context.Background(), "abc-123")
+	assert.Equal(t, "abc-123", FromContext(ctx))
+}
+
+func Test_New(t *testing.T) {
+	id := New()
+	assert.Len(t, id, 32)
+	assert.True(t, Valid(id))
+	assert.NotEqual(t, id, New())
+}
+
+func Test_Valid(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{id: "3f2b8c1e-8d4a-4e0b-9c55-0a1b2c3d4e5f", want: true},
+		{id: "req_42", want: true},
+		{id: "", want: false},
+		{id: "with space", want: false},
+		{id: "line\nbreak", want: false},
+		{id: "привет", want: false},
+		{id: strings.Repeat("a", 128), want: true},
+		{id: strings.Repeat("a", 129), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			assert.Equal(t, tt.want, Valid(tt.id))
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"errors"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repository"
+	"merch-shop/internal/utils"
+)
+
+// errorCodes maps the errors of the service and the layers below it to the codes of
+// models.ErrorResponse. It is matched with errors.Is in order, so specific errors must
+// come before the ones they wrap. The HTTP and gRPC APIs answer with the same codes,
+// each choosing its own status for them.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{models.ErrValidation, models.CodeValidationFailed},
+
+	{utils.ErrTooLongPassword, models.CodePasswordTooLong},
+	{utils.ErrMismatchHashPassword, models.CodeWrongPassword},
+	{utils.ErrNoAuthorizationHeader, models.CodeMissingToken},
+	{utils.ErrInvalidAuthorizationHeader, models.CodeInvalidAuthHeader},
+	{utils.ErrExpiredToken, models.CodeTokenExpired},
+	{utils.ErrInvalidToken, models.CodeInvalidToken},
+	{utils.ErrInvalidSigningMethod, models.CodeInvalidToken},
+	{utils.ErrInvalidClaims, models.CodeInvalidToken},
+	{utils.ErrInvalidUserID, models.CodeInvalidToken},
+
+	{ErrSendToYourself, models.CodeSelfTransfer},
+	{ErrUnknownPeriod, models.CodeInvalidRequest},
+	{ErrUserDeactivated, models.CodeUserDeactivated},
+
+	{repository.ErrNotEnoughCoins, models.CodeInsufficientFunds},
+	{repository.ErrBalanceLimit, models.CodeBalanceLimit},
+	{repository.ErrItemNotFound, models.CodeItemNotFound},
+	{repository.ErrReceiverNotFound, models.CodeReceiverNotFound},
+	{repository.ErrSenderNotFound, models.CodeUserNotFound},
+	{repository.ErrUserNotFound, models.CodeUserNotFound},
+	{repository.ErrRecordNotFound, models.CodeNotFound},
+	{repository.ErrDuplicateRecord, models.CodeAlreadyExists},
+}
+
+// ErrorCode returns the code of err, or false if err is unexpected and should be
+// reported as an internal error.
+func ErrorCode(err error) (string, bool) {
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return e.code, true
+		}
+	}
+
+	return "", false
+}
+
+// ErrorCodes returns every code ErrorCode may return, so the transports can check
+// they have a status for each of them.
+func ErrorCodes() []string {
+	codes := make([]string, 0, len(errorCodes))
+	for _, e := range errorCodes {
+		codes = append(codes, e.code)
+	}
+
+	return codes
+}
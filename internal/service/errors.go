@@ -3,5 +3,7 @@ package service
 import "errors"
 
 var (
-	ErrSendToYourself = errors.New("can't send coins to yourself")
+	ErrSendToYourself  = errors.New("can't send coins to yourself")
+	ErrUnknownPeriod   = errors.New("unknown leaderboard period")
+	ErrUserDeactivated = errors.New("user is deactivated")
 )
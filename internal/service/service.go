@@ -3,13 +3,25 @@ package service
 import (
 	"context"
 	"errors"
-	"fmt"
 	"merch-shop/internal/config"
 	"merch-shop/internal/models"
 	"merch-shop/internal/repository"
 	"merch-shop/internal/utils"
+	"time"
+
+	"go.opentelemetry.io/otel"
 )
 
+var tracer = otel.Tracer("merch-shop/internal/service")
+
+// leaderboardPeriods maps periods to how far back they reach, zero counts all time.
+var leaderboardPeriods = map[string]time.Duration{
+	models.PeriodDay:   24 * time.Hour,
+	models.PeriodWeek:  7 * 24 * time.Hour,
+	models.PeriodMonth: 30 * 24 * time.Hour,
+	models.PeriodAll:   0,
+}
+
 type Service struct {
 	repo repository.Repository
 	cfg  *config.Config
@@ -23,11 +35,16 @@ func NewService(repo repository.Repository, cfg *config.Config) *Service {
 }
 
 func (s *Service) Login(ctx context.Context, username, password string) (string, error) {
+	ctx, span := tracer.Start(ctx, "Service.Login")
+	defer span.End()
+
 	if err := utils.ValidatePassword(password); err != nil {
 		return "", err
 	}
 
-	user, err := s.repo.GetByUsername(ctx, username)
+	// Deactivated users keep their name, so they are found here rather than registered
+	// again, and are told apart only after the password matches.
+	user, err := s.repo.GetCredentials(ctx, username)
 	if err != nil {
 		if errors.Is(err, repository.ErrRecordNotFound) {
 			return s.Add(ctx, username, password)
@@ -39,10 +56,17 @@ func (s *Service) Login(ctx context.Context, username, password string) (string,
 		return "", err
 	}
 
+	if !user.IsActive {
+		return "", ErrUserDeactivated
+	}
+
 	return utils.GenerateToken(user.ID, s.cfg.JWT.SecretKey, s.cfg.JWT.TokenExpiry)
 }
 
 func (s *Service) Add(ctx context.Context, username, password string) (string, error) {
+	ctx, span := tracer.Start(ctx, "Service.Add")
+	defer span.End()
+
 	hashedPassword, err := utils.HashPassword(password)
 	if err != nil {
 		return "", err
@@ -61,27 +85,36 @@ func (s *Service) Add(ctx context.Context, username, password string) (string, e
 	return utils.GenerateToken(user.ID, s.cfg.JWT.SecretKey, s.cfg.JWT.TokenExpiry)
 }
 
-func (s *Service) SendCoin(ctx context.Context, senderID int, receiverName string, amount int) error {
+func (s *Service) SendCoin(ctx context.Context, senderID int, receiverName string, amount int) (*models.Transfer, error) {
+	ctx, span := tracer.Start(ctx, "Service.SendCoin")
+	defer span.End()
+
 	receiver, err := s.repo.GetByUsername(ctx, receiverName)
 	if err != nil {
 		if errors.Is(err, repository.ErrRecordNotFound) {
-			return fmt.Errorf("receiver user: %w", err)
+			return nil, repository.ErrReceiverNotFound
 		}
-		return err
+		return nil, err
 	}
 
 	if receiver.ID == senderID {
-		return ErrSendToYourself
+		return nil, ErrSendToYourself
 	}
 
 	return s.repo.SendCoin(ctx, senderID, receiver.ID, amount)
 }
 
-func (s *Service) BuyItem(ctx context.Context, userID int, itemName string) error {
+func (s *Service) BuyItem(ctx context.Context, userID int, itemName string) (*models.Purchase, error) {
+	ctx, span := tracer.Start(ctx, "Service.BuyItem")
+	defer span.End()
+
 	return s.repo.BuyItem(ctx, userID, itemName)
 }
 
 func (s *Service) Info(ctx context.Context, userID int) (*models.InfoResponse, error) {
+	ctx, span := tracer.Start(ctx, "Service.Info")
+	defer span.End()
+
 	coins, err := s.repo.GetBalance(ctx, userID)
 	if err != nil {
 		return nil, err
@@ -105,3 +138,182 @@ func (s *Service) Info(ctx context.Context, userID int) (*models.InfoResponse, e
 
 	return infoResponse, nil
 }
+
+func (s *Service) GetProfile(ctx context.Context, username string) (*models.UserProfile, error) {
+	ctx, span := tracer.Start(ctx, "Service.GetProfile")
+	defer span.End()
+
+	return s.repo.GetProfile(ctx, username)
+}
+
+// SearchUsers returns a page of at most limit active users whose username starts with query.
+// after is the Next cursor of the previous page, empty for the first one.
+func (s *Service) SearchUsers(ctx context.Context, query, after string, limit int) (*models.UserList, error) {
+	ctx, span := tracer.Start(ctx, "Service.SearchUsers")
+	defer span.End()
+
+	// One extra row tells whether there is a next page.
+	users, err := s.repo.SearchUsers(ctx, query, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &models.UserList{Users: users}
+	if len(users) > limit {
+		list.Users = users[:limit]
+		list.Next = users[limit-1].Username
+	}
+
+	return list, nil
+}
+
+func (s *Service) UpdateProfile(ctx context.Context, userID int, req *models.UpdateProfileRequest) (*models.UserProfile, error) {
+	ctx, span := tracer.Start(ctx, "Service.UpdateProfile")
+	defer span.End()
+
+	return s.repo.UpdateProfile(ctx, userID, req.DisplayName, req.AvatarURL, req.LeaderboardOptOut)
+}
+
+// Leaderboard ranks at most limit users by coins received or sent over period, or by items owned.
+// Items have no history, so they are always counted over all time.
+func (s *Service) Leaderboard(ctx context.Context, mode, period string, limit int) (*models.Leaderboard, error) {
+	ctx, span := tracer.Start(ctx, "Service.Leaderboard")
+	defer span.End()
+
+	window, ok := leaderboardPeriods[period]
+	if !ok {
+		return nil, ErrUnknownPeriod
+	}
+
+	board := &models.Leaderboard{Mode: mode, Period: period}
+
+	var since time.Time
+	switch {
+	case mode == models.LeaderboardItems:
+		board.Period = models.PeriodAll
+	case window > 0:
+		since = time.Now().Add(-window)
+		board.Since = &since
+	}
+
+	entries, err := s.repo.GetLeaderboard(ctx, mode, since, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, e := range entries {
+		e.Rank = i + 1
+		if i > 0 && e.Score == entries[i-1].Score {
+			e.Rank = entries[i-1].Rank
+		}
+	}
+	board.Entries = entries
+
+	return board, nil
+}
+
+// WebhookDeliveries returns a page of at most limit deliveries in the given status.
+// after is the Next cursor of the previous page, zero for the first one.
+func (s *Service) WebhookDeliveries(ctx context.Context, status string, after, limit int) (*models.WebhookDeliveryList, error) {
+	ctx, span := tracer.Start(ctx, "Service.WebhookDeliveries")
+	defer span.End()
+
+	// One extra row tells whether there is a next page.
+	deliveries, err := s.repo.ListDeliveries(ctx, status, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &models.WebhookDeliveryList{Deliveries: deliveries}
+	if len(deliveries) > limit {
+		list.Deliveries = deliveries[:limit]
+		list.Next = deliveries[limit-1].ID
+	}
+
+	return list, nil
+}
+
+// ReplayWebhookDelivery schedules a dead delivery to be sent again right away.
+func (s *Service) ReplayWebhookDelivery(ctx context.Context, deliveryID int) (*models.WebhookDelivery, error) {
+	ctx, span := tracer.Start(ctx, "Service.ReplayWebhookDelivery")
+	defer span.End()
+
+	return s.repo.ReplayDelivery(ctx, deliveryID)
+}
+
+// UserEvents returns up to limit events of the user after the given ID, so a dropped event
+// stream can be resumed without losing any.
+func (s *Service) UserEvents(ctx context.Context, userID, after, limit int) ([]*models.UserEvent, error) {
+	ctx, span := tracer.Start(ctx, "Service.UserEvents")
+	defer span.End()
+
+	return s.repo.ListUserEvents(ctx, userID, after, limit)
+}
+
+// GrantCoins adds amount coins to the balance of the user named username.
+func (s *Service) GrantCoins(ctx context.Context, username string, amount int) (*models.CoinGrant, error) {
+	ctx, span := tracer.Start(ctx, "Service.GrantCoins")
+	defer span.End()
+
+	user, err := s.activeUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := s.repo.GrantCoins(ctx, user.ID, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CoinGrant{
+		Username: username,
+		Amount:   amount,
+		Balance:  balance,
+	}, nil
+}
+
+// DeactivateUser hides the user named username from the shop. Their history is kept
+// and the name stays taken.
+func (s *Service) DeactivateUser(ctx context.Context, username string) error {
+	ctx, span := tracer.Start(ctx, "Service.DeactivateUser")
+	defer span.End()
+
+	user, err := s.activeUser(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.DeactivateUser(ctx, user.ID)
+}
+
+func (s *Service) Items(ctx context.Context) (*models.ItemList, error) {
+	ctx, span := tracer.Start(ctx, "Service.Items")
+	defer span.End()
+
+	items, err := s.repo.ListItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ItemList{Items: items}, nil
+}
+
+// SetItemPrice changes the price of an item, putting it on sale if it is new.
+func (s *Service) SetItemPrice(ctx context.Context, itemName string, price int) (*models.Item, error) {
+	ctx, span := tracer.Start(ctx, "Service.SetItemPrice")
+	defer span.End()
+
+	return s.repo.SetItemPrice(ctx, itemName, price)
+}
+
+func (s *Service) activeUser(ctx context.Context, username string) (*models.User, error) {
+	user, err := s.repo.GetByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return nil, repository.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
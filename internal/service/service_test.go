@@ -3,19 +3,63 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"merch-shop/internal/config"
 	"merch-shop/internal/models"
 	"merch-shop/internal/repository"
 	"merch-shop/internal/repository/mocks"
+	"merch-shop/internal/repository/repotest"
 	"merch-shop/internal/utils"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
+type testCtxKey struct{}
+
+// derivedCtx matches contexts derived from testContext, since the service wraps the caller's
+// context with its own tracing spans before passing it to the repository.
+var derivedCtx = mock.MatchedBy(func(ctx context.Context) bool {
+	return ctx.Value(testCtxKey{}) != nil
+})
+
+func testContext() context.Context {
+	return context.WithValue(context.Background(), testCtxKey{}, true)
+}
+
+// forEachBackend runs fn against a service over every repository backend, so the cases
+// checked with mocks are also checked with the real queries. unique suffixes usernames,
+// so a shared Postgres database can be reused.
+func forEachBackend(t *testing.T, fn func(t *testing.T, service *Service, unique func(string) string)) {
+	cfg := &config.Config{}
+	cfg.JWT.SecretKey = "0123456789abcdef0123456789abcdef"
+	cfg.JWT.TokenExpiry = time.Hour
+
+	for name, newRepo := range repotest.Backends {
+		t.Run(name, func(t *testing.T) {
+			suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+			fn(t, NewService(newRepo(t), cfg), func(s string) string { return s + suffix })
+		})
+	}
+}
+
+// login signs username up and returns its id.
+func login(t *testing.T, service *Service, username string) int {
+	t.Helper()
+
+	token, err := service.Login(context.Background(), username, "password")
+	require.NoError(t, err)
+	userID, err := utils.ValidateToken(token, service.cfg.JWT.SecretKey)
+	require.NoError(t, err)
+
+	return userID
+}
+
 func Test_Login(t *testing.T) {
-	ctx := context.Background()
+	ctx := testContext()
 	cfg := &config.Config{}
 	mockRepo := new(mocks.Repository)
 	service := NewService(mockRepo, cfg)
@@ -25,6 +69,7 @@ func Test_Login(t *testing.T) {
 		username   string
 		password   string
 		wantErr    bool
+		wantErrIs  error
 		mockRepoFn func()
 	}{
 		{
@@ -40,7 +85,7 @@ func Test_Login(t *testing.T) {
 			password: "password",
 			mockRepoFn: func() {
 				hashedPassword, _ := utils.HashPassword("password")
-				mockRepo.On("GetByUsername", ctx, "bob").Return(&models.User{Username: "bob", PasswordHash: hashedPassword}, nil)
+				mockRepo.On("GetCredentials", derivedCtx, "bob").Return(&models.User{Username: "bob", PasswordHash: hashedPassword, IsActive: true}, nil)
 			},
 		},
 		{
@@ -48,8 +93,8 @@ func Test_Login(t *testing.T) {
 			username: "alice",
 			password: "password",
 			mockRepoFn: func() {
-				mockRepo.On("GetByUsername", ctx, "alice").Return(nil, repository.ErrRecordNotFound)
-				mockRepo.On("Add", ctx, mock.MatchedBy(func(u *models.User) bool {
+				mockRepo.On("GetCredentials", derivedCtx, "alice").Return(nil, repository.ErrRecordNotFound)
+				mockRepo.On("Add", derivedCtx, mock.MatchedBy(func(u *models.User) bool {
 					return u.Username == "alice" && len(u.PasswordHash) > 0
 				})).Return(nil)
 			},
@@ -60,7 +105,7 @@ func Test_Login(t *testing.T) {
 			password: "password",
 			wantErr:  true,
 			mockRepoFn: func() {
-				mockRepo.On("GetByUsername", ctx, "carl").Return(nil, errors.New("db fails"))
+				mockRepo.On("GetCredentials", derivedCtx, "carl").Return(nil, errors.New("db fails"))
 			},
 		},
 		{
@@ -70,7 +115,29 @@ func Test_Login(t *testing.T) {
 			wantErr:  true,
 			mockRepoFn: func() {
 				hashedPassword, _ := utils.HashPassword("password")
-				mockRepo.On("GetByUsername", ctx, "sarah").Return(&models.User{Username: "sarah", PasswordHash: hashedPassword}, nil)
+				mockRepo.On("GetCredentials", derivedCtx, "sarah").Return(&models.User{Username: "sarah", PasswordHash: hashedPassword, IsActive: true}, nil)
+			},
+		},
+		{
+			name:      "user deactivated, password check success",
+			username:  "dave",
+			password:  "password",
+			wantErr:   true,
+			wantErrIs: ErrUserDeactivated,
+			mockRepoFn: func() {
+				hashedPassword, _ := utils.HashPassword("password")
+				mockRepo.On("GetCredentials", derivedCtx, "dave").Return(&models.User{Username: "dave", PasswordHash: hashedPassword}, nil)
+			},
+		},
+		{
+			name:      "user deactivated, password check fails",
+			username:  "erin",
+			password:  "wrong password",
+			wantErr:   true,
+			wantErrIs: utils.ErrMismatchHashPassword,
+			mockRepoFn: func() {
+				hashedPassword, _ := utils.HashPassword("password")
+				mockRepo.On("GetCredentials", derivedCtx, "erin").Return(&models.User{Username: "erin", PasswordHash: hashedPassword}, nil)
 			},
 		},
 	}
@@ -83,6 +150,9 @@ func Test_Login(t *testing.T) {
 
 			if tt.wantErr {
 				assert.Error(t, err)
+				if tt.wantErrIs != nil {
+					assert.ErrorIs(t, err, tt.wantErrIs)
+				}
 				assert.Empty(t, token)
 			} else {
 				assert.NoError(t, err)
@@ -92,10 +162,45 @@ func Test_Login(t *testing.T) {
 			mockRepo.AssertExpectations(t)
 		})
 	}
+
+	forEachBackend(t, func(t *testing.T, service *Service, unique func(string) string) {
+		alice := unique("alice")
+
+		tests := []struct {
+			name       string
+			deactivate bool
+			password   string
+			wantErr    error
+		}{
+			{name: "user not exists, signed up", password: "password"},
+			{name: "user exists, password check success", password: "password"},
+			{name: "user exists, password check fails", password: "wrong password", wantErr: utils.ErrMismatchHashPassword},
+			{name: "user deactivated, password check fails", deactivate: true, password: "wrong password", wantErr: utils.ErrMismatchHashPassword},
+			{name: "user deactivated, password check success", password: "password", wantErr: ErrUserDeactivated},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if tt.deactivate {
+					require.NoError(t, service.DeactivateUser(context.Background(), alice))
+				}
+
+				token, err := service.Login(context.Background(), alice, tt.password)
+
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+					assert.Empty(t, token)
+				} else {
+					assert.NoError(t, err)
+					assert.NotEmpty(t, token)
+				}
+			})
+		}
+	})
 }
 
 func Test_Add(t *testing.T) {
-	ctx := context.Background()
+	ctx := testContext()
 	cfg := &config.Config{}
 	mockRepo := new(mocks.Repository)
 	service := NewService(mockRepo, cfg)
@@ -119,7 +224,7 @@ func Test_Add(t *testing.T) {
 			username: "alice",
 			password: "password",
 			mockRepoFn: func() {
-				mockRepo.On("Add", ctx, mock.MatchedBy(func(u *models.User) bool {
+				mockRepo.On("Add", derivedCtx, mock.MatchedBy(func(u *models.User) bool {
 					return u.Username == "alice" && len(u.PasswordHash) > 0
 				})).Return(nil)
 			},
@@ -130,7 +235,7 @@ func Test_Add(t *testing.T) {
 			password: "password",
 			wantErr:  true,
 			mockRepoFn: func() {
-				mockRepo.On("Add", ctx, mock.Anything).Return(errors.New("db fails"))
+				mockRepo.On("Add", derivedCtx, mock.Anything).Return(errors.New("db fails"))
 			},
 		},
 	}
@@ -155,7 +260,7 @@ func Test_Add(t *testing.T) {
 }
 
 func Test_SendCoin(t *testing.T) {
-	ctx := context.Background()
+	ctx := testContext()
 	cfg := &config.Config{}
 	mockRepo := new(mocks.Repository)
 	service := NewService(mockRepo, cfg)
@@ -173,7 +278,7 @@ func Test_SendCoin(t *testing.T) {
 			receiverName: "bob",
 			wantErr:      true,
 			mockRepoFn: func() {
-				mockRepo.On("GetByUsername", ctx, "bob").Return(nil, repository.ErrRecordNotFound)
+				mockRepo.On("GetByUsername", derivedCtx, "bob").Return(nil, repository.ErrRecordNotFound)
 			},
 		},
 		{
@@ -181,7 +286,7 @@ func Test_SendCoin(t *testing.T) {
 			receiverName: "carl",
 			wantErr:      true,
 			mockRepoFn: func() {
-				mockRepo.On("GetByUsername", ctx, "carl").Return(nil, errors.New("db fails"))
+				mockRepo.On("GetByUsername", derivedCtx, "carl").Return(nil, errors.New("db fails"))
 			},
 		},
 		{
@@ -191,7 +296,7 @@ func Test_SendCoin(t *testing.T) {
 			wantErr:      true,
 			mockRepoFn: func() {
 				hashedPassword, _ := utils.HashPassword("password")
-				mockRepo.On("GetByUsername", ctx, "alice").Return(&models.User{ID: 1, Username: "alice", PasswordHash: hashedPassword}, nil)
+				mockRepo.On("GetByUsername", derivedCtx, "alice").Return(&models.User{ID: 1, Username: "alice", PasswordHash: hashedPassword}, nil)
 			},
 		},
 		{
@@ -201,8 +306,8 @@ func Test_SendCoin(t *testing.T) {
 			amount:       100,
 			mockRepoFn: func() {
 				hashedPassword, _ := utils.HashPassword("password")
-				mockRepo.On("GetByUsername", ctx, "sarah").Return(&models.User{ID: 2, Username: "sarah", PasswordHash: hashedPassword}, nil)
-				mockRepo.On("SendCoin", ctx, 1, 2, 100).Return(nil)
+				mockRepo.On("GetByUsername", derivedCtx, "sarah").Return(&models.User{ID: 2, Username: "sarah", PasswordHash: hashedPassword}, nil)
+				mockRepo.On("SendCoin", derivedCtx, 1, 2, 100).Return(&models.Transfer{ID: 1, ToUser: "sarah", Amount: 100, Balance: 900}, nil)
 			},
 		},
 		{
@@ -213,8 +318,8 @@ func Test_SendCoin(t *testing.T) {
 			wantErr:      true,
 			mockRepoFn: func() {
 				hashedPassword, _ := utils.HashPassword("password")
-				mockRepo.On("GetByUsername", ctx, "sarah").Return(&models.User{ID: 2, Username: "sarah", PasswordHash: hashedPassword}, nil)
-				mockRepo.On("SendCoin", ctx, 1, 2, 10000).Return(repository.ErrNotEnoughCoins)
+				mockRepo.On("GetByUsername", derivedCtx, "sarah").Return(&models.User{ID: 2, Username: "sarah", PasswordHash: hashedPassword}, nil)
+				mockRepo.On("SendCoin", derivedCtx, 1, 2, 10000).Return(nil, repository.ErrNotEnoughCoins)
 			},
 		},
 	}
@@ -223,21 +328,56 @@ func Test_SendCoin(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockRepoFn()
 
-			err := service.SendCoin(ctx, tt.senderID, tt.receiverName, tt.amount)
+			transfer, err := service.SendCoin(ctx, tt.senderID, tt.receiverName, tt.amount)
 
 			if tt.wantErr {
 				assert.Error(t, err)
+				assert.Nil(t, transfer)
 			} else {
 				assert.NoError(t, err)
+				assert.Equal(t, tt.receiverName, transfer.ToUser)
 			}
 
 			mockRepo.AssertExpectations(t)
 		})
 	}
+
+	forEachBackend(t, func(t *testing.T, service *Service, unique func(string) string) {
+		alice, bob := unique("alice"), unique("bob")
+		aliceID := login(t, service, alice)
+		login(t, service, bob)
+
+		tests := []struct {
+			name         string
+			receiverName string
+			amount       int
+			wantErr      error
+		}{
+			{name: "receiver not exists", receiverName: unique("nobody"), amount: 10, wantErr: repository.ErrRecordNotFound},
+			{name: "receiver exists, fail to send yourself", receiverName: alice, amount: 10, wantErr: ErrSendToYourself},
+			{name: "receiver exists, success to send", receiverName: bob, amount: 100},
+			{name: "receiver exists, failed to send", receiverName: bob, amount: 10000, wantErr: repository.ErrNotEnoughCoins},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				transfer, err := service.SendCoin(context.Background(), aliceID, tt.receiverName, tt.amount)
+
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+					assert.Nil(t, transfer)
+				} else {
+					require.NoError(t, err)
+					assert.Equal(t, tt.receiverName, transfer.ToUser)
+					assert.Equal(t, 1000-tt.amount, transfer.Balance)
+				}
+			})
+		}
+	})
 }
 
 func Test_BuyItem(t *testing.T) {
-	ctx := context.Background()
+	ctx := testContext()
 	cfg := &config.Config{}
 	mockRepo := new(mocks.Repository)
 	service := NewService(mockRepo, cfg)
@@ -254,7 +394,7 @@ func Test_BuyItem(t *testing.T) {
 			userID:   1,
 			itemName: "cup",
 			mockRepoFn: func() {
-				mockRepo.On("BuyItem", ctx, 1, "cup").Return(nil)
+				mockRepo.On("BuyItem", derivedCtx, 1, "cup").Return(&models.Purchase{ID: 1, Item: "cup", Price: 20, Quantity: 1, Balance: 980}, nil)
 			},
 		},
 		{
@@ -263,7 +403,7 @@ func Test_BuyItem(t *testing.T) {
 			itemName: "cup",
 			wantErr:  true,
 			mockRepoFn: func() {
-				mockRepo.On("BuyItem", ctx, 2, "cup").Return(repository.ErrNotEnoughCoins)
+				mockRepo.On("BuyItem", derivedCtx, 2, "cup").Return(nil, repository.ErrNotEnoughCoins)
 			},
 		},
 	}
@@ -272,21 +412,53 @@ func Test_BuyItem(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mockRepoFn()
 
-			err := service.BuyItem(ctx, tt.userID, tt.itemName)
+			purchase, err := service.BuyItem(ctx, tt.userID, tt.itemName)
 
 			if tt.wantErr {
 				assert.Error(t, err)
+				assert.Nil(t, purchase)
 			} else {
 				assert.NoError(t, err)
+				assert.Equal(t, tt.itemName, purchase.Item)
 			}
 
 			mockRepo.AssertExpectations(t)
 		})
 	}
+
+	forEachBackend(t, func(t *testing.T, service *Service, unique func(string) string) {
+		aliceID := login(t, service, unique("alice"))
+
+		tests := []struct {
+			name     string
+			itemName string
+			wantErr  error
+		}{
+			{name: "success to buy item", itemName: "cup"},
+			{name: "success to buy item again", itemName: "cup"},
+			{name: "item not exists", itemName: "beer", wantErr: repository.ErrRecordNotFound},
+			{name: "success to buy expensive item", itemName: "pink-hoody"},
+			{name: "fails to buy item", itemName: "pink-hoody", wantErr: repository.ErrNotEnoughCoins},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				purchase, err := service.BuyItem(context.Background(), aliceID, tt.itemName)
+
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+					assert.Nil(t, purchase)
+				} else {
+					require.NoError(t, err)
+					assert.Equal(t, tt.itemName, purchase.Item)
+				}
+			})
+		}
+	})
 }
 
 func Test_Info(t *testing.T) {
-	ctx := context.Background()
+	ctx := testContext()
 	cfg := &config.Config{}
 	mockRepo := new(mocks.Repository)
 	service := NewService(mockRepo, cfg)
@@ -302,7 +474,7 @@ func Test_Info(t *testing.T) {
 			userID:  1,
 			wantErr: true,
 			mockRepoFn: func() {
-				mockRepo.On("GetBalance", ctx, 1).Return(0, repository.ErrRecordNotFound)
+				mockRepo.On("GetBalance", derivedCtx, 1).Return(0, repository.ErrRecordNotFound)
 			},
 		},
 		{
@@ -310,8 +482,8 @@ func Test_Info(t *testing.T) {
 			userID:  2,
 			wantErr: true,
 			mockRepoFn: func() {
-				mockRepo.On("GetBalance", ctx, 2).Return(0, nil)
-				mockRepo.On("GetInventory", ctx, 2).Return(nil, errors.New("db fails"))
+				mockRepo.On("GetBalance", derivedCtx, 2).Return(0, nil)
+				mockRepo.On("GetInventory", derivedCtx, 2).Return(nil, errors.New("db fails"))
 			},
 		},
 		{
@@ -319,18 +491,18 @@ func Test_Info(t *testing.T) {
 			userID:  3,
 			wantErr: true,
 			mockRepoFn: func() {
-				mockRepo.On("GetBalance", ctx, 3).Return(0, nil)
-				mockRepo.On("GetInventory", ctx, 3).Return(nil, nil)
-				mockRepo.On("GetCoinHistory", ctx, 3).Return(nil, errors.New("db fails"))
+				mockRepo.On("GetBalance", derivedCtx, 3).Return(0, nil)
+				mockRepo.On("GetInventory", derivedCtx, 3).Return(nil, nil)
+				mockRepo.On("GetCoinHistory", derivedCtx, 3).Return(nil, errors.New("db fails"))
 			},
 		},
 		{
 			name:   "user exists, success",
 			userID: 4,
 			mockRepoFn: func() {
-				mockRepo.On("GetBalance", ctx, 4).Return(0, nil)
-				mockRepo.On("GetInventory", ctx, 4).Return(nil, nil)
-				mockRepo.On("GetCoinHistory", ctx, 4).Return(nil, nil)
+				mockRepo.On("GetBalance", derivedCtx, 4).Return(0, nil)
+				mockRepo.On("GetInventory", derivedCtx, 4).Return(nil, nil)
+				mockRepo.On("GetCoinHistory", derivedCtx, 4).Return(nil, nil)
 			},
 		},
 	}
@@ -352,4 +524,205 @@ func Test_Info(t *testing.T) {
 			mockRepo.AssertExpectations(t)
 		})
 	}
+
+	forEachBackend(t, func(t *testing.T, service *Service, unique func(string) string) {
+		ctx := context.Background()
+		alice, bob := unique("alice"), unique("bob")
+		aliceID := login(t, service, alice)
+		login(t, service, bob)
+
+		_, err := service.SendCoin(ctx, aliceID, bob, 100)
+		require.NoError(t, err)
+		_, err = service.BuyItem(ctx, aliceID, "cup")
+		require.NoError(t, err)
+		_, err = service.BuyItem(ctx, aliceID, "cup")
+		require.NoError(t, err)
+
+		infoResponse, err := service.Info(ctx, aliceID)
+		require.NoError(t, err)
+
+		assert.Equal(t, 1000-100-2*20, infoResponse.Coins)
+
+		inventory := map[string]int{}
+		for _, item := range infoResponse.Inventory {
+			inventory[item.Type] = item.Quantity
+		}
+		assert.Equal(t, map[string]int{"cup": 2}, inventory)
+
+		require.Len(t, infoResponse.CoinHistory.Sent, 1)
+		assert.Equal(t, bob, infoResponse.CoinHistory.Sent[0].ToUser)
+		assert.Equal(t, 100, infoResponse.CoinHistory.Sent[0].Amount)
+		assert.Empty(t, infoResponse.CoinHistory.Received)
+	})
+}
+
+func Test_SearchUsers(t *testing.T) {
+	ctx := testContext()
+	cfg := &config.Config{}
+
+	profiles := func(names ...string) []*models.UserProfile {
+		result := []*models.UserProfile{}
+		for _, name := range names {
+			result = append(result, &models.UserProfile{Username: name})
+		}
+		return result
+	}
+
+	tests := []struct {
+		name      string
+		after     string
+		found     []*models.UserProfile
+		wantUsers []*models.UserProfile
+		wantNext  string
+		wantErr   error
+	}{
+		{
+			name:      "more pages",
+			found:     profiles("ann", "anna", "anton"),
+			wantUsers: profiles("ann", "anna"),
+			wantNext:  "anna",
+		},
+		{
+			name:      "last page",
+			after:     "anna",
+			found:     profiles("anton"),
+			wantUsers: profiles("anton"),
+		},
+		{
+			name:      "no users",
+			found:     profiles(),
+			wantUsers: profiles(),
+		},
+		{
+			name:    "repository error",
+			wantErr: errors.New("db is down"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.Repository)
+			service := NewService(mockRepo, cfg)
+
+			mockRepo.On("SearchUsers", derivedCtx, "an", tt.after, 3).Return(tt.found, tt.wantErr)
+
+			list, err := service.SearchUsers(ctx, "an", tt.after, 2)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, list)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantUsers, list.Users)
+				assert.Equal(t, tt.wantNext, list.Next)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_Leaderboard(t *testing.T) {
+	ctx := testContext()
+	cfg := &config.Config{}
+
+	t.Run("ranks ties equally", func(t *testing.T) {
+		mockRepo := new(mocks.Repository)
+		service := NewService(mockRepo, cfg)
+
+		since := mock.MatchedBy(func(since time.Time) bool {
+			return time.Since(since).Round(time.Hour) == 7*24*time.Hour
+		})
+		mockRepo.On("GetLeaderboard", derivedCtx, models.LeaderboardReceived, since, 10).Return([]*models.LeaderboardEntry{
+			{Username: "ann", Score: 50},
+			{Username: "bob", Score: 30},
+			{Username: "carl", Score: 30},
+			{Username: "dave", Score: 10},
+		}, nil)
+
+		board, err := service.Leaderboard(ctx, models.LeaderboardReceived, models.PeriodWeek, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, models.PeriodWeek, board.Period)
+		assert.NotNil(t, board.Since)
+
+		var ranks []int
+		for _, e := range board.Entries {
+			ranks = append(ranks, e.Rank)
+		}
+		assert.Equal(t, []int{1, 2, 2, 4}, ranks)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("items are counted over all time", func(t *testing.T) {
+		mockRepo := new(mocks.Repository)
+		service := NewService(mockRepo, cfg)
+
+		mockRepo.On("GetLeaderboard", derivedCtx, models.LeaderboardItems, time.Time{}, 10).Return([]*models.LeaderboardEntry{}, nil)
+
+		board, err := service.Leaderboard(ctx, models.LeaderboardItems, models.PeriodDay, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, models.PeriodAll, board.Period)
+		assert.Nil(t, board.Since)
+		assert.NotNil(t, board.Entries)
+
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("unknown period", func(t *testing.T) {
+		service := NewService(new(mocks.Repository), cfg)
+
+		_, err := service.Leaderboard(ctx, models.LeaderboardSent, "decade", 10)
+		assert.ErrorIs(t, err, ErrUnknownPeriod)
+	})
+}
+
+func Test_WebhookDeliveries(t *testing.T) {
+	ctx := testContext()
+	cfg := &config.Config{}
+
+	deliveries := func(ids ...int) []*models.WebhookDelivery {
+		result := []*models.WebhookDelivery{}
+		for _, id := range ids {
+			result = append(result, &models.WebhookDelivery{ID: id, Status: models.DeliveryDead})
+		}
+		return result
+	}
+
+	tests := []struct {
+		name           string
+		after          int
+		found          []*models.WebhookDelivery
+		wantDeliveries []*models.WebhookDelivery
+		wantNext       int
+	}{
+		{
+			name:           "more pages",
+			found:          deliveries(3, 7, 9),
+			wantDeliveries: deliveries(3, 7),
+			wantNext:       7,
+		},
+		{
+			name:           "last page",
+			after:          7,
+			found:          deliveries(9),
+			wantDeliveries: deliveries(9),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.Repository)
+			service := NewService(mockRepo, cfg)
+
+			mockRepo.On("ListDeliveries", derivedCtx, models.DeliveryDead, tt.after, 3).Return(tt.found, nil)
+
+			list, err := service.WebhookDeliveries(ctx, models.DeliveryDead, tt.after, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantDeliveries, list.Deliveries)
+			assert.Equal(t, tt.wantNext, list.Next)
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
 }
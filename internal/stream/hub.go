@@ -0,0 +1,299 @@
+// Package stream fans user events out to the live event streams of the users they belong to.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"merch-shop/internal/config"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repository"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// bufferSize is how many events a subscriber may fall behind before it is dropped.
+	bufferSize    = 64
+	batchSize     = 100
+	purgeInterval = time.Hour
+	// catchUpWindow is how many IDs below the last published event are read again on
+	// catch-up. Event IDs are taken when a transaction writes the event, not when it
+	// commits, so an event may become visible after ones with greater IDs.
+	catchUpWindow = 100
+	// pingInterval is how often the listener connection is checked, a dead one is
+	// otherwise only noticed when the kernel gives up on it.
+	pingInterval = time.Minute
+	minReconnect = time.Second
+	maxReconnect = time.Minute
+)
+
+// Subscription receives the events of a single user on C. C is closed when the subscriber
+// falls too far behind or the hub is closed; the client is expected to reconnect and resume
+// from the last event it got.
+type Subscription struct {
+	C <-chan *models.UserEvent
+
+	c      chan *models.UserEvent
+	hub    *Hub
+	userID int
+}
+
+// Close stops the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.remove(s)
+}
+
+// Hub keeps the subscriptions of this process. It is fed by a single Listen or Poll
+// loop, which sees the events written by every replica.
+type Hub struct {
+	repo   repository.Repository
+	cfg    config.Events
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	subs   map[int]map[*Subscription]struct{}
+	closed bool
+
+	// lastID, published and started are only used by the feeding loop. published keeps
+	// the IDs within catchUpWindow of lastID, so events read again are not sent twice.
+	lastID    int
+	published map[int]struct{}
+	started   bool
+	lastPurge time.Time
+}
+
+func NewHub(repo repository.Repository, cfg config.Events, logger *slog.Logger) *Hub {
+	return &Hub{
+		repo:      repo,
+		cfg:       cfg,
+		logger:    logger,
+		subs:      map[int]map[*Subscription]struct{}{},
+		published: map[int]struct{}{},
+	}
+}
+
+// Subscribe starts receiving the events of userID. Once the hub is closed it returns
+// subscriptions that are closed already.
+func (h *Hub) Subscribe(userID int) *Subscription {
+	c := make(chan *models.UserEvent, bufferSize)
+	s := &Subscription{C: c, c: c, hub: h, userID: userID}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(c)
+		return s
+	}
+
+	if h.subs[userID] == nil {
+		h.subs[userID] = map[*Subscription]struct{}{}
+	}
+	h.subs[userID][s] = struct{}{}
+
+	return s
+}
+
+// Publish sends e to the subscriptions of its user without waiting for them.
+func (h *Hub) Publish(e *models.UserEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subs[e.UserID] {
+		select {
+		case s.c <- e:
+		default:
+			h.remove(s)
+		}
+	}
+}
+
+// Close closes all subscriptions, so the streams end and the server can shut down.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subs := range h.subs {
+		for s := range subs {
+			h.remove(s)
+		}
+	}
+}
+
+// remove must be called with h.mu held.
+func (h *Hub) remove(s *Subscription) {
+	subs, ok := h.subs[s.userID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[s]; !ok {
+		return
+	}
+
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(h.subs, s.userID)
+	}
+	close(s.c)
+}
+
+// Listen feeds the hub from Postgres NOTIFY on repository.UserEventsChannel until ctx
+// is canceled. Events committed while the connection was down are looked up when it
+// comes back.
+func (h *Hub) Listen(ctx context.Context, dsn string) {
+	listener := pq.NewListener(dsn, minReconnect, maxReconnect, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			h.logger.Warn("event listener", slog.Int("event", int(event)), slog.String("error", err.Error()))
+		}
+	})
+	defer listener.Close()
+
+	// Listen blocks until the server acknowledges it, closing the listener gives up on that.
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+
+	if err := listener.Listen(repository.UserEventsChannel); err != nil {
+		if ctx.Err() == nil {
+			h.logger.Error("listen for user events", slog.String("error", err.Error()))
+		}
+		return
+	}
+
+	h.catchUp(ctx)
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n, ok := <-listener.Notify:
+			if !ok {
+				return
+			}
+
+			// A nil notification means the connection was reestablished, anything sent
+			// in the meantime is lost.
+			if n == nil {
+				h.catchUp(ctx)
+				continue
+			}
+
+			var e models.UserEvent
+			if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+				h.logger.Error("decode user event", slog.String("error", err.Error()))
+				continue
+			}
+			h.publish(&e)
+		case <-ticker.C:
+			if err := listener.Ping(); err != nil {
+				h.logger.Warn("ping event listener", slog.String("error", err.Error()))
+			}
+			h.purge(ctx)
+		}
+	}
+}
+
+// Poll feeds the hub by looking up new events every cfg.PollInterval until ctx is canceled.
+// It is used with storage that can't push events.
+func (h *Hub) Poll(ctx context.Context) {
+	ticker := time.NewTicker(h.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		h.catchUp(ctx)
+		h.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// catchUp publishes the events written after the last one published, and the ones within
+// catchUpWindow below it that were committed late. The events found by the first call are
+// not published, streams look up older events themselves.
+func (h *Hub) catchUp(ctx context.Context) {
+	if err := h.tryCatchUp(ctx); err != nil && ctx.Err() == nil {
+		h.logger.Error("look up user events", slog.String("error", err.Error()))
+	}
+}
+
+func (h *Hub) tryCatchUp(ctx context.Context) error {
+	// Events up to start were written before the hub started, they only fill the window.
+	start := -1
+	if !h.started {
+		last, err := h.repo.LastUserEventID(ctx)
+		if err != nil {
+			return err
+		}
+		h.lastID, start = last, last
+	}
+
+	after := max(h.lastID-catchUpWindow, 0)
+	for {
+		events, err := h.repo.ListAllUserEvents(ctx, after, batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			after = e.ID
+			if _, ok := h.published[e.ID]; ok {
+				continue
+			}
+			if e.ID <= start {
+				h.published[e.ID] = struct{}{}
+				continue
+			}
+			h.publish(e)
+		}
+
+		h.started = true
+
+		if len(events) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (h *Hub) publish(e *models.UserEvent) {
+	h.lastID = max(h.lastID, e.ID)
+	h.published[e.ID] = struct{}{}
+	for id := range h.published {
+		if id <= h.lastID-catchUpWindow {
+			delete(h.published, id)
+		}
+	}
+
+	h.Publish(e)
+}
+
+func (h *Hub) purge(ctx context.Context) {
+	if time.Since(h.lastPurge) < purgeInterval {
+		return
+	}
+
+	n, err := h.repo.PurgeUserEvents(ctx, h.cfg.Retention)
+	if err != nil {
+		if ctx.Err() == nil {
+			h.logger.Error("purge user events", slog.String("error", err.Error()))
+		}
+		return
+	}
+
+	h.lastPurge = time.Now()
+	if n > 0 {
+		h.logger.Info("purged user events", slog.Int("count", n))
+	}
+}
@@ -0,0 +1,189 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"merch-shop/internal/config"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repository"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHub(repo repository.Repository) *Hub {
+	cfg := config.Events{
+		Heartbeat:    time.Second,
+		PollInterval: 10 * time.Millisecond,
+		Retention:    time.Hour,
+	}
+	return NewHub(repo, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func receive(t *testing.T, sub *Subscription) *models.UserEvent {
+	t.Helper()
+
+	select {
+	case e, ok := <-sub.C:
+		require.True(t, ok, "subscription is closed")
+		return e
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no event received")
+		return nil
+	}
+}
+
+func requireClosed(t *testing.T, sub *Subscription) {
+	t.Helper()
+
+	for {
+		select {
+		case _, ok := <-sub.C:
+			if !ok {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "subscription is not closed")
+		}
+	}
+}
+
+func Test_Hub(t *testing.T) {
+	hub := newTestHub(repository.NewMemoryRepository())
+
+	alice := hub.Subscribe(1)
+	aliceAgain := hub.Subscribe(1)
+	bob := hub.Subscribe(2)
+
+	hub.Publish(&models.UserEvent{ID: 1, UserID: 1})
+	assert.Equal(t, 1, receive(t, alice).ID)
+	assert.Equal(t, 1, receive(t, aliceAgain).ID)
+	assert.Empty(t, bob.C, "events go to their own user only")
+
+	aliceAgain.Close()
+	aliceAgain.Close()
+	requireClosed(t, aliceAgain)
+
+	t.Run("slow subscriber is dropped", func(t *testing.T) {
+		for i := range bufferSize + 1 {
+			hub.Publish(&models.UserEvent{ID: 100 + i, UserID: 2})
+		}
+		requireClosed(t, bob)
+
+		hub.Publish(&models.UserEvent{ID: 2, UserID: 1})
+		assert.Equal(t, 2, receive(t, alice).ID)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		hub.Close()
+		requireClosed(t, alice)
+		requireClosed(t, hub.Subscribe(1))
+	})
+}
+
+func Test_Hub_Poll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repo := repository.NewMemoryRepository()
+	hub := newTestHub(repo)
+
+	alice := &models.User{Username: "alice", PasswordHash: "hash"}
+	bob := &models.User{Username: "bob", PasswordHash: "hash"}
+	require.NoError(t, repo.Add(ctx, alice))
+	require.NoError(t, repo.Add(ctx, bob))
+
+	// Events written before the hub started are left to streams resuming with Last-Event-ID.
+	_, err := repo.BuyItem(ctx, alice.ID, "cup")
+	require.NoError(t, err)
+
+	hub.catchUp(ctx)
+	sub := hub.Subscribe(alice.ID)
+
+	done := make(chan struct{})
+	go func() {
+		hub.Poll(ctx)
+		close(done)
+	}()
+
+	_, err = repo.SendCoin(ctx, bob.ID, alice.ID, 10)
+	require.NoError(t, err)
+
+	received := receive(t, sub)
+	assert.Equal(t, models.UserEventTransferReceived, received.Type)
+	balance := receive(t, sub)
+	assert.Equal(t, models.UserEventBalanceChanged, balance.Type)
+	assert.JSONEq(t, `{"balance":990}`, string(balance.Data))
+	assert.Greater(t, balance.ID, received.ID)
+
+	cancel()
+	<-done
+	assert.Empty(t, sub.C)
+}
+
+// lateCommitRepo lists the events set in visible, standing in for a database where a
+// transaction holding a smaller event ID commits after others.
+type lateCommitRepo struct {
+	repository.Repository
+
+	mu      sync.Mutex
+	visible []*models.UserEvent
+}
+
+func (r *lateCommitRepo) commit(events ...*models.UserEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.visible = append(r.visible, events...)
+	sort.Slice(r.visible, func(i, j int) bool { return r.visible[i].ID < r.visible[j].ID })
+}
+
+func (r *lateCommitRepo) LastUserEventID(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.visible) == 0 {
+		return 0, nil
+	}
+	return r.visible[len(r.visible)-1].ID, nil
+}
+
+func (r *lateCommitRepo) ListAllUserEvents(ctx context.Context, after, limit int) ([]*models.UserEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := []*models.UserEvent{}
+	for _, e := range r.visible {
+		if e.ID > after && len(events) < limit {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func Test_Hub_CatchUpLateCommit(t *testing.T) {
+	ctx := context.Background()
+	repo := &lateCommitRepo{}
+	hub := newTestHub(repo)
+
+	repo.commit(&models.UserEvent{ID: 1, UserID: 1})
+	hub.catchUp(ctx)
+	sub := hub.Subscribe(1)
+
+	// Event 2 took its ID first, but 3 committed before it.
+	repo.commit(&models.UserEvent{ID: 3, UserID: 1})
+	hub.catchUp(ctx)
+	assert.Equal(t, 3, receive(t, sub).ID)
+
+	repo.commit(&models.UserEvent{ID: 2, UserID: 1})
+	hub.catchUp(ctx)
+	assert.Equal(t, 2, receive(t, sub).ID)
+
+	hub.catchUp(ctx)
+	assert.Empty(t, sub.C, "events read again are not published twice")
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 	"time"
@@ -48,6 +49,9 @@ func ValidateToken(tokenStr string, secretKey string) (int, error) {
 	})
 
 	if err != nil || !token.Valid {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return 0, ErrExpiredToken
+		}
 		return 0, ErrInvalidToken
 	}
 
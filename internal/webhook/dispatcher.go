@@ -0,0 +1,254 @@
+// Package webhook delivers the events written to the outbox to the configured HTTP endpoints.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"merch-shop/internal/config"
+	"merch-shop/internal/metrics"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repository"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	batchSize      = 100
+	initialBackoff = 10 * time.Second
+	maxBackoff     = time.Hour
+	purgeInterval  = time.Hour
+	// maxErrorLen bounds the error stored with a failed delivery, response bodies can be large.
+	maxErrorLen = 512
+)
+
+// Dispatcher moves events from the outbox into deliveries and sends them. Failed deliveries
+// are retried with exponential backoff until they run out of attempts and become dead.
+// Deliveries are claimed with a lease, so several replicas can run a Dispatcher at once.
+type Dispatcher struct {
+	repo      repository.Repository
+	cfg       config.Webhooks
+	endpoints map[string]config.WebhookEndpoint
+	// subscribers lists endpoint names by event type.
+	subscribers map[string][]string
+	client      *http.Client
+	logger      *slog.Logger
+	metrics     *metrics.Metrics
+	lastPurge   time.Time
+}
+
+func NewDispatcher(repo repository.Repository, cfg config.Webhooks, logger *slog.Logger, metrics *metrics.Metrics) *Dispatcher {
+	d := &Dispatcher{
+		repo:        repo,
+		cfg:         cfg,
+		endpoints:   map[string]config.WebhookEndpoint{},
+		subscribers: map[string][]string{},
+		client:      &http.Client{},
+		logger:      logger,
+		metrics:     metrics,
+	}
+
+	for _, e := range cfg.Endpoints {
+		d.endpoints[e.Name] = e
+
+		events := e.Events
+		if len(events) == 0 {
+			events = models.EventTypes
+		}
+		for _, eventType := range events {
+			d.subscribers[eventType] = append(d.subscribers[eventType], e.Name)
+		}
+	}
+
+	return d
+}
+
+// Run polls the outbox until ctx is canceled. It runs even without endpoints,
+// so events that nobody subscribed to don't pile up in the outbox.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) poll(ctx context.Context) {
+	if err := d.dispatch(ctx); err != nil && ctx.Err() == nil {
+		d.logger.Error("dispatch webhook events", slog.String("error", err.Error()))
+	}
+
+	if err := d.deliverDue(ctx); err != nil && ctx.Err() == nil {
+		d.logger.Error("claim webhook deliveries", slog.String("error", err.Error()))
+	}
+
+	if time.Since(d.lastPurge) >= purgeInterval {
+		n, err := d.repo.PurgeDeliveries(ctx, d.cfg.Retention)
+		if err != nil {
+			if ctx.Err() == nil {
+				d.logger.Error("purge webhook deliveries", slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		d.lastPurge = time.Now()
+		if n > 0 {
+			d.logger.Info("purged webhook deliveries", slog.Int("count", n))
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context) error {
+	for {
+		n, err := d.repo.DispatchEvents(ctx, d.subscribers, batchSize)
+		if err != nil || n < batchSize {
+			return err
+		}
+	}
+}
+
+// deliverDue sends the due deliveries in batches, the deliveries of a batch in parallel.
+func (d *Dispatcher) deliverDue(ctx context.Context) error {
+	// A claimed delivery must not be claimed again while it is being sent.
+	lease := d.cfg.Timeout + time.Minute
+
+	for {
+		deliveries, err := d.repo.ClaimDeliveries(ctx, lease, batchSize)
+		if err != nil {
+			return err
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.deliver(ctx, delivery)
+			}()
+		}
+		wg.Wait()
+
+		if len(deliveries) < batchSize || ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	attempt := delivery.Attempts + 1
+
+	err := d.send(ctx, delivery)
+	if err == nil {
+		if err := d.repo.MarkDelivered(ctx, delivery.ID, delivery.LeaseID); err != nil {
+			d.logMarkError(delivery, "mark webhook delivered", err)
+		}
+		d.metrics.WebhookAttempt(delivery.Endpoint, models.DeliveryDelivered)
+		return
+	}
+
+	// The attempt was cut short by shutdown, the lease runs out and another poll retries it.
+	if ctx.Err() != nil {
+		return
+	}
+
+	dead := attempt >= d.cfg.MaxAttempts
+	retryIn := backoff(attempt)
+
+	message := err.Error()
+	if len(message) > maxErrorLen {
+		message = message[:maxErrorLen]
+	}
+
+	if err := d.repo.MarkFailed(ctx, delivery.ID, delivery.LeaseID, message, retryIn, dead); err != nil {
+		d.logMarkError(delivery, "mark webhook failed", err)
+		// The result of the attempt was not recorded, so it neither retries nor kills the delivery.
+		if errors.Is(err, repository.ErrLeaseLost) {
+			return
+		}
+	}
+
+	attrs := []slog.Attr{
+		slog.Int("delivery_id", delivery.ID),
+		slog.String("endpoint", delivery.Endpoint),
+		slog.String("event_type", delivery.EventType),
+		slog.Int("attempt", attempt),
+		slog.String("error", message),
+	}
+
+	if dead {
+		d.metrics.WebhookAttempt(delivery.Endpoint, models.DeliveryDead)
+		d.logger.LogAttrs(ctx, slog.LevelError, "webhook delivery is dead", attrs...)
+		return
+	}
+
+	d.metrics.WebhookAttempt(delivery.Endpoint, "failed")
+	d.logger.LogAttrs(ctx, slog.LevelWarn, "webhook delivery failed", append(attrs, slog.Duration("retry_in", retryIn))...)
+}
+
+// logMarkError logs a failure to record the result of an attempt. A lost lease is expected
+// when an attempt outlives its lease and another dispatcher claims the delivery.
+func (d *Dispatcher) logMarkError(delivery *models.WebhookDelivery, msg string, err error) {
+	level := slog.LevelError
+	if errors.Is(err, repository.ErrLeaseLost) {
+		level = slog.LevelWarn
+	}
+
+	d.logger.LogAttrs(context.Background(), level, msg, slog.Int("delivery_id", delivery.ID), slog.String("error", err.Error()))
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery *models.WebhookDelivery) error {
+	endpoint, ok := d.endpoints[delivery.Endpoint]
+	if !ok {
+		return fmt.Errorf("endpoint %q is not configured", delivery.Endpoint)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "merch-shop-webhooks")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(delivery.ID))
+	req.Header.Set(HeaderSignature, Sign(endpoint.Secret, time.Now(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLen))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded with %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
+
+// backoff returns the delay before the attempt after the given one: initialBackoff doubled
+// with every attempt, up to maxBackoff.
+func backoff(attempt int) time.Duration {
+	d := initialBackoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
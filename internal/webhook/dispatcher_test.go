@@ -0,0 +1,171 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"merch-shop/internal/config"
+	"merch-shop/internal/metrics"
+	"merch-shop/internal/models"
+	"merch-shop/internal/repository"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+type receiver struct {
+	mu     sync.Mutex
+	status int
+	events []models.Event
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if err := Verify(testSecret, r.Header.Get(HeaderSignature), body, time.Minute, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var event models.Event
+	if err := json.Unmarshal(body, &event); err != nil || r.Header.Get(HeaderEvent) != event.Type {
+		http.Error(w, "bad event", http.StatusBadRequest)
+		return
+	}
+
+	rc.events = append(rc.events, event)
+	w.WriteHeader(rc.status)
+}
+
+func (rc *receiver) eventTypes() []string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	types := []string{}
+	for _, e := range rc.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func newTestDispatcher(t *testing.T, repo repository.Repository, endpoints ...config.WebhookEndpoint) *Dispatcher {
+	t.Helper()
+
+	cfg := config.Webhooks{
+		Endpoints:    endpoints,
+		MaxAttempts:  2,
+		PollInterval: time.Second,
+		Timeout:      time.Second,
+		Retention:    time.Hour,
+	}
+
+	return NewDispatcher(repo, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), metrics.New())
+}
+
+func Test_Dispatcher(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+
+	all := &receiver{status: http.StatusNoContent}
+	allSrv := httptest.NewServer(all)
+	defer allSrv.Close()
+
+	failing := &receiver{status: http.StatusServiceUnavailable}
+	failingSrv := httptest.NewServer(failing)
+	defer failingSrv.Close()
+
+	d := newTestDispatcher(t, repo,
+		config.WebhookEndpoint{Name: "all", URL: allSrv.URL, Secret: testSecret},
+		config.WebhookEndpoint{Name: "transfers", URL: failingSrv.URL, Secret: testSecret, Events: []string{models.EventTransferCreated}},
+	)
+
+	alice := &models.User{Username: "alice", PasswordHash: "hash"}
+	bob := &models.User{Username: "bob", PasswordHash: "hash"}
+	require.NoError(t, repo.Add(ctx, alice))
+	require.NoError(t, repo.Add(ctx, bob))
+	_, err := repo.SendCoin(ctx, alice.ID, bob.ID, 10)
+	require.NoError(t, err)
+	_, err = repo.BuyItem(ctx, alice.ID, "cup")
+	require.NoError(t, err)
+
+	d.poll(ctx)
+
+	// Deliveries are sent in parallel, so their order is not kept.
+	assert.ElementsMatch(t, []string{
+		models.EventUserRegistered,
+		models.EventUserRegistered,
+		models.EventTransferCreated,
+		models.EventPurchaseCreated,
+	}, all.eventTypes())
+	assert.Equal(t, []string{models.EventTransferCreated}, failing.eventTypes(), "endpoints should only get the events they subscribed to")
+
+	delivered, err := repo.ListDeliveries(ctx, models.DeliveryDelivered, 0, 100)
+	require.NoError(t, err)
+	assert.Len(t, delivered, 4)
+
+	pending, err := repo.ListDeliveries(ctx, models.DeliveryPending, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "transfers", pending[0].Endpoint)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Contains(t, pending[0].LastError, "503")
+	assert.WithinDuration(t, time.Now().Add(initialBackoff), pending[0].NextAttemptAt, time.Second)
+
+	d.poll(ctx)
+	assert.Len(t, failing.eventTypes(), 1, "failed deliveries should wait for the backoff")
+
+	// Skip the backoff of the second attempt, with MaxAttempts 2 the next failure makes the delivery dead.
+	require.NoError(t, repo.MarkFailed(ctx, pending[0].ID, pending[0].LeaseID, "timeout", 0, false))
+	d.poll(ctx)
+	assert.Len(t, failing.eventTypes(), 2)
+
+	dead, err := repo.ListDeliveries(ctx, models.DeliveryDead, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, dead, 1, "deliveries should become dead after max attempts")
+	assert.Equal(t, 3, dead[0].Attempts)
+
+	failing.mu.Lock()
+	failing.status = http.StatusOK
+	failing.mu.Unlock()
+
+	_, err = repo.ReplayDelivery(ctx, dead[0].ID)
+	require.NoError(t, err)
+	d.poll(ctx)
+
+	assert.Len(t, failing.eventTypes(), 3)
+	assert.Equal(t, failing.events[0].ID, failing.events[2].ID, "replays should keep the event ID")
+
+	delivered, err = repo.ListDeliveries(ctx, models.DeliveryDelivered, 0, 100)
+	require.NoError(t, err)
+	assert.Len(t, delivered, 5)
+}
+
+func Test_Dispatcher_UnknownEndpoint(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+
+	// Deliveries outlive the config, here the endpoint was removed after they were created.
+	require.NoError(t, repo.Add(ctx, &models.User{Username: "alice", PasswordHash: "hash"}))
+	_, err := repo.DispatchEvents(ctx, map[string][]string{models.EventUserRegistered: {"removed"}}, 10)
+	require.NoError(t, err)
+
+	d := newTestDispatcher(t, repo)
+	d.cfg.MaxAttempts = 1
+	d.poll(ctx)
+
+	dead, err := repo.ListDeliveries(ctx, models.DeliveryDead, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, `endpoint "removed" is not configured`, dead[0].LastError)
+}
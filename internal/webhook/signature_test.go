@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Verify(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+	body := []byte(`{"id": 1, "type": "user.registered"}`)
+	now := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name    string
+		header  string
+		body    []byte
+		wantErr error
+	}{
+		{
+			name:   "valid signature",
+			header: Sign(secret, now, body),
+			body:   body,
+		},
+		{
+			name:   "one of several signatures matches",
+			header: "t=1700000000,v1=00ff," + Sign(secret, now, body)[len("t=1700000000,"):],
+			body:   body,
+		},
+		{
+			name:    "changed body",
+			header:  Sign(secret, now, body),
+			body:    []byte(`{"id": 2, "type": "user.registered"}`),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "wrong secret",
+			header:  Sign("fedcba9876543210fedcba9876543210", now, body),
+			body:    body,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "old timestamp",
+			header:  Sign(secret, now.Add(-10*time.Minute), body),
+			body:    body,
+			wantErr: ErrExpiredSignature,
+		},
+		{
+			name:    "missing timestamp",
+			header:  "v1=00ff",
+			body:    body,
+			wantErr: ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(secret, tt.header, tt.body, 5*time.Minute, now)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_backoff(t *testing.T) {
+	assert.Equal(t, 10*time.Second, backoff(1))
+	assert.Equal(t, 20*time.Second, backoff(2))
+	assert.Equal(t, 80*time.Second, backoff(4))
+	assert.Equal(t, time.Hour, backoff(20))
+	assert.Equal(t, time.Hour, backoff(1000))
+}
@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// Migrations are named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// with one directory per SQL dialect.
+//
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+var (
+	Postgres = mustSub("postgres")
+	SQLite   = mustSub("sqlite")
+)
+
+func mustSub(dir string) fs.FS {
+	sub, err := fs.Sub(files, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
@@ -6,9 +6,9 @@ CREATE TABLE IF NOT EXISTS users (
 	is_active BOOLEAN DEFAULT TRUE
 );
 
-CREATE INDEX idx_users_is_active ON users(is_active);
+CREATE INDEX IF NOT EXISTS idx_users_is_active ON users(is_active);
 
-CREATE VIEW active_users AS
+CREATE OR REPLACE VIEW active_users AS
 SELECT id, username, password_hash, created_at
 FROM users
 WHERE is_active = TRUE;
@@ -18,7 +18,7 @@ CREATE TABLE IF NOT EXISTS coins (
 	balance INT NOT NULL DEFAULT 1000 CHECK (balance >= 0)
 );
 
-CREATE INDEX idx_user_id ON coins(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_id ON coins(user_id);
 
 CREATE TABLE IF NOT EXISTS item (
 	id SERIAL PRIMARY KEY,
@@ -42,7 +42,7 @@ CREATE TABLE IF NOT EXISTS transaction (
 	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
-CREATE INDEX idx_transaction_id ON transaction(sender_id, receiver_id);
+CREATE INDEX IF NOT EXISTS idx_transaction_id ON transaction(sender_id, receiver_id);
 
 INSERT INTO item(type, price)
 VALUES
@@ -55,4 +55,5 @@ VALUES
        ('umbrella', 200),
        ('socks', 10),
        ('wallet', 50),
-       ('pink-hoody', 500);
+       ('pink-hoody', 500)
+ON CONFLICT (type) DO NOTHING;
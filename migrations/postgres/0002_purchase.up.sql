@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS purchase (
+	id SERIAL PRIMARY KEY,
+	user_id INT REFERENCES users(id),
+	item_id INT REFERENCES item(id) ON DELETE CASCADE,
+	price INT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_purchase_user_id ON purchase(user_id);
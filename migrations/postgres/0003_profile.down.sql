@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_users_username_c;
+
+DROP VIEW IF EXISTS active_users;
+
+CREATE VIEW active_users AS
+SELECT id, username, password_hash, created_at
+FROM users
+WHERE is_active = TRUE;
+
+ALTER TABLE users DROP COLUMN IF EXISTS avatar_url;
+ALTER TABLE users DROP COLUMN IF EXISTS display_name;
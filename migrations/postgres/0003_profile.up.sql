@@ -0,0 +1,11 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_url TEXT NOT NULL DEFAULT '';
+
+CREATE OR REPLACE VIEW active_users AS
+SELECT id, username, password_hash, created_at, display_name, avatar_url
+FROM users
+WHERE is_active = TRUE;
+
+-- User search matches username prefixes and pages in byte order, so the index uses
+-- the C collation: it serves both LIKE 'prefix%' and the keyset ordering.
+CREATE INDEX IF NOT EXISTS idx_users_username_c ON users((username COLLATE "C"));
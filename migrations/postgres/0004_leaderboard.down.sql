@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_transaction_created_at;
+
+DROP VIEW IF EXISTS active_users;
+
+CREATE VIEW active_users AS
+SELECT id, username, password_hash, created_at, display_name, avatar_url
+FROM users
+WHERE is_active = TRUE;
+
+ALTER TABLE users DROP COLUMN IF EXISTS leaderboard_opt_out;
@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS leaderboard_opt_out BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE OR REPLACE VIEW active_users AS
+SELECT id, username, password_hash, created_at, display_name, avatar_url, leaderboard_opt_out
+FROM users
+WHERE is_active = TRUE;
+
+-- Leaderboards sum transfers over a time window, the included columns let them
+-- be answered from the index alone.
+CREATE INDEX IF NOT EXISTS idx_transaction_created_at ON transaction(created_at) INCLUDE (sender_id, receiver_id, amount);
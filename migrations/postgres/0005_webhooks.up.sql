@@ -0,0 +1,26 @@
+-- Events are written in the same transaction as the change that caused them,
+-- the webhook dispatcher moves them into deliveries, one per endpoint.
+CREATE TABLE IF NOT EXISTS outbox (
+	id SERIAL PRIMARY KEY,
+	event_type VARCHAR(50) NOT NULL,
+	payload TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+	id SERIAL PRIMARY KEY,
+	event_id INT NOT NULL,
+	event_type VARCHAR(50) NOT NULL,
+	endpoint VARCHAR(100) NOT NULL,
+	payload TEXT NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	delivered_at TIMESTAMP,
+	UNIQUE (event_id, endpoint)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_due ON webhook_delivery(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_status ON webhook_delivery(status, id);
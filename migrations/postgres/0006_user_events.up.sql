@@ -0,0 +1,12 @@
+-- Events for the live stream of a user, written in the same transaction as the change.
+-- They are kept for a while after being pushed, so a client can resume a dropped stream.
+CREATE TABLE IF NOT EXISTS user_event (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id),
+	event_type VARCHAR(50) NOT NULL,
+	payload TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_event_user_id ON user_event(user_id, id);
+CREATE INDEX IF NOT EXISTS idx_user_event_created_at ON user_event(created_at);
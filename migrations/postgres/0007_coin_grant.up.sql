@@ -0,0 +1,10 @@
+-- Coins added by an admin. Every grant is kept, so a balance can be traced back to
+-- the transfers, purchases and grants that made it.
+CREATE TABLE IF NOT EXISTS coin_grant (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id),
+	amount INT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_coin_grant_user_id ON coin_grant(user_id);
@@ -0,0 +1 @@
+ALTER TABLE webhook_delivery DROP COLUMN lease_id;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS "transaction";
+DROP TABLE IF EXISTS inventory;
+DROP TABLE IF EXISTS item;
+DROP TABLE IF EXISTS coins;
+DROP VIEW IF EXISTS active_users;
+DROP TABLE IF EXISTS users;
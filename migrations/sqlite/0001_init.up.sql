@@ -0,0 +1,57 @@
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username VARCHAR(50) UNIQUE NOT NULL,
+	password_hash TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	is_active BOOLEAN DEFAULT TRUE
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_is_active ON users(is_active);
+
+CREATE VIEW IF NOT EXISTS active_users AS
+SELECT id, username, password_hash, created_at
+FROM users
+WHERE is_active = TRUE;
+
+CREATE TABLE IF NOT EXISTS coins (
+	user_id INTEGER PRIMARY KEY REFERENCES users(id),
+	balance INTEGER NOT NULL DEFAULT 1000 CHECK (balance >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS item (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	type VARCHAR(50) UNIQUE NOT NULL,
+	price INTEGER NOT NULL CHECK (price >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS inventory (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER REFERENCES users(id),
+	item_id INTEGER REFERENCES item(id) ON DELETE CASCADE,
+	quantity INTEGER NOT NULL DEFAULT 1,
+	UNIQUE (user_id, item_id)
+);
+
+CREATE TABLE IF NOT EXISTS "transaction" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	sender_id INTEGER REFERENCES users(id),
+	receiver_id INTEGER REFERENCES users(id),
+	amount INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_id ON "transaction"(sender_id, receiver_id);
+
+INSERT INTO item(type, price)
+VALUES
+       ('t-shirt', 80),
+       ('cup', 20),
+       ('book', 50),
+       ('pen', 10),
+       ('powerbank', 200),
+       ('hoody', 300),
+       ('umbrella', 200),
+       ('socks', 10),
+       ('wallet', 50),
+       ('pink-hoody', 500)
+ON CONFLICT (type) DO NOTHING;
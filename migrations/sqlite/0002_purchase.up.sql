@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS purchase (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER REFERENCES users(id),
+	item_id INTEGER REFERENCES item(id) ON DELETE CASCADE,
+	price INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_purchase_user_id ON purchase(user_id);
@@ -0,0 +1,9 @@
+DROP VIEW IF EXISTS active_users;
+
+CREATE VIEW active_users AS
+SELECT id, username, password_hash, created_at
+FROM users
+WHERE is_active = TRUE;
+
+ALTER TABLE users DROP COLUMN avatar_url;
+ALTER TABLE users DROP COLUMN display_name;
@@ -0,0 +1,9 @@
+ALTER TABLE users ADD COLUMN display_name VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';
+
+DROP VIEW IF EXISTS active_users;
+
+CREATE VIEW active_users AS
+SELECT id, username, password_hash, created_at, display_name, avatar_url
+FROM users
+WHERE is_active = TRUE;
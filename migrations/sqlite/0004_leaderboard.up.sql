@@ -0,0 +1,11 @@
+ALTER TABLE users ADD COLUMN leaderboard_opt_out BOOLEAN NOT NULL DEFAULT FALSE;
+
+DROP VIEW IF EXISTS active_users;
+
+CREATE VIEW active_users AS
+SELECT id, username, password_hash, created_at, display_name, avatar_url, leaderboard_opt_out
+FROM users
+WHERE is_active = TRUE;
+
+-- Covers the leaderboard queries, which sum transfers over a time window.
+CREATE INDEX IF NOT EXISTS idx_transaction_created_at ON "transaction"(created_at, sender_id, receiver_id, amount);
@@ -0,0 +1,4 @@
+-- Every claim of a delivery takes a new lease ID. A dispatcher records the result of an
+-- attempt only while the delivery still carries the ID of its claim, so a dispatcher whose
+-- lease ran out can't overwrite the result of the one that claimed the delivery after it.
+ALTER TABLE webhook_delivery ADD COLUMN lease_id INTEGER NOT NULL DEFAULT 0;
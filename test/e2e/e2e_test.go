@@ -3,55 +3,95 @@
 package e2e
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
+	"log/slog"
+	"merch-shop/client"
 	"merch-shop/internal/config"
 	"merch-shop/internal/dbinit"
-	"merch-shop/internal/models"
 	"net/http"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	configPath = "../../config.yml"
+	httpHost   = "http://localhost:8081"
 )
 
 func TestMain(m *testing.M) {
-	os.Chdir("../..")
-	exitCode := m.Run()
-	os.Exit(exitCode)
+	if err := setupTestDB(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	os.Exit(m.Run())
 }
 
-func AuthUser(t *testing.T, username, password string) (int, string) {
-	httpHost := "http://localhost:8081"
-	client := &http.Client{}
+// setupTestDB waits until the service has migrated the test DB and lowers
+// the default balance of new users to 100 for easier tests.
+func setupTestDB() error {
+	var err error
+	for i := 0; i < 30; i++ {
+		var resp *http.Response
+		resp, err = http.Get(httpHost + "/readyz")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				break
+			}
+			err = fmt.Errorf("readyz: status %d", resp.StatusCode)
+		}
+		time.Sleep(time.Second)
+	}
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.New(configPath)
+	if err != nil {
+		return err
+	}
+	cfg.DB.Port = "5433"
+	cfg.DB.Name = "shop_test"
 
-	authReq := models.AuthRequest{
-		Username: username,
-		Password: password,
+	db, err := dbinit.OpenDB(context.Background(), cfg, slog.Default())
+	if err != nil {
+		return err
 	}
+	defer db.Close()
 
-	authBody, err := json.Marshal(authReq)
-	assert.NoError(t, err)
+	_, err = db.Exec(`ALTER TABLE coins ALTER COLUMN balance SET DEFAULT 100`)
+	return err
+}
 
-	req, err := http.NewRequest("POST", httpHost+"/api/auth", bytes.NewReader(authBody))
-	assert.NoError(t, err)
-	req.Header.Add("Content-type", "application/json")
+// AuthUser logs the user in with a new client. Retries are off, so every
+// request the tests expect to fail is sent once.
+func AuthUser(t *testing.T, username, password string) (*client.Client, error) {
+	t.Helper()
 
-	resp, err := client.Do(req)
-	assert.NoError(t, err)
-	defer resp.Body.Close()
+	c := client.New(httpHost, client.WithRetries(0, 0))
+	_, err := c.Auth(context.Background(), username, password)
+
+	return c, err
+}
+
+// statusCode returns the HTTP status of the error a call returned, http.StatusOK if it succeeded.
+func statusCode(t *testing.T, err error) int {
+	t.Helper()
 
-	if resp.StatusCode != http.StatusOK {
-		return resp.StatusCode, ""
+	if err == nil {
+		return http.StatusOK
 	}
 
-	authResponse := models.AuthResponse{}
-	err = json.NewDecoder(resp.Body).Decode(&authResponse)
-	assert.NoError(t, err)
+	var apiErr *client.Error
+	require.ErrorAs(t, err, &apiErr)
 
-	assert.NotEmpty(t, authResponse.Token)
-	return resp.StatusCode, authResponse.Token
+	return apiErr.StatusCode
 }
 
 func Test_Auth_E2E(t *testing.T) {
@@ -102,14 +142,14 @@ func Test_Auth_E2E(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotStatusCode, token := AuthUser(t, tt.username, tt.password)
+			c, err := AuthUser(t, tt.username, tt.password)
 
-			assert.Equal(t, tt.wantStatusCode, gotStatusCode)
+			assert.Equal(t, tt.wantStatusCode, statusCode(t, err))
 
 			if tt.wantToken {
-				assert.NotEmpty(t, token)
+				assert.NotEmpty(t, c.Token())
 			} else {
-				assert.Empty(t, token)
+				assert.Empty(t, c.Token())
 			}
 		})
 	}
@@ -122,14 +162,14 @@ func Test_Auth_E2E(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotStatusCode, token := AuthUser(t, tt.username, tt.password)
+			c, err := AuthUser(t, tt.username, tt.password)
 
-			assert.Equal(t, tt.wantStatusCode, gotStatusCode)
+			assert.Equal(t, tt.wantStatusCode, statusCode(t, err))
 
 			if tt.wantToken {
-				assert.NotEmpty(t, token)
+				assert.NotEmpty(t, c.Token())
 			} else {
-				assert.Empty(t, token)
+				assert.Empty(t, c.Token())
 			}
 		})
 	}
@@ -138,15 +178,12 @@ func Test_Auth_E2E(t *testing.T) {
 func Test_BuyItem_E2E(t *testing.T) {
 	// For tests default coins in DB for users set to 100
 
-	httpHost := "http://localhost:8081"
-	client := &http.Client{}
-
-	cfg, err := config.New(".")
+	cfg, err := config.New(configPath)
 	assert.NoError(t, err)
 	cfg.DB.Port = "5433"
 	cfg.DB.Name = "shop_test"
 
-	db, err := dbinit.OpenDB(cfg)
+	db, err := dbinit.OpenDB(context.Background(), cfg, slog.Default())
 	assert.NoError(t, err)
 
 	tests := []struct {
@@ -157,6 +194,7 @@ func Test_BuyItem_E2E(t *testing.T) {
 		wantStatusCode int
 		wantErr        bool
 		errResponse    string
+		errCode        string
 		wantBalance    int
 	}{
 		{
@@ -176,6 +214,7 @@ func Test_BuyItem_E2E(t *testing.T) {
 			wantStatusCode: http.StatusBadRequest,
 			wantErr:        true,
 			errResponse:    "not enough coins",
+			errCode:        client.CodeInsufficientFunds,
 			wantBalance:    100,
 		},
 		{
@@ -186,31 +225,29 @@ func Test_BuyItem_E2E(t *testing.T) {
 			wantStatusCode: http.StatusBadRequest,
 			wantErr:        true,
 			errResponse:    "item: record not found",
+			errCode:        client.CodeItemNotFound,
 			wantBalance:    100,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, token := AuthUser(t, tt.username, tt.password)
+			c, err := AuthUser(t, tt.username, tt.password)
+			require.NoError(t, err)
 
-			reqURL := fmt.Sprintf("%s/api/buy/%s", httpHost, tt.item)
-			req, err := http.NewRequest("GET", reqURL, nil)
-			assert.NoError(t, err)
+			purchase, err := c.BuyItem(context.Background(), tt.item)
 
-			req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
-
-			resp, err := client.Do(req)
-			assert.NoError(t, err)
-			defer resp.Body.Close()
-
-			assert.Equal(t, tt.wantStatusCode, resp.StatusCode)
+			assert.Equal(t, tt.wantStatusCode, statusCode(t, err))
 
 			if tt.wantErr {
-				errorResponse := &models.ErrorResponse{}
-				err := json.NewDecoder(resp.Body).Decode(errorResponse)
-				assert.NoError(t, err)
-				assert.Equal(t, tt.errResponse, errorResponse.Errors)
+				var apiErr *client.Error
+				require.ErrorAs(t, err, &apiErr)
+				assert.Equal(t, tt.errResponse, apiErr.Message)
+				assert.Equal(t, tt.errCode, apiErr.Code)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.item, purchase.Item)
+				assert.Equal(t, tt.wantBalance, purchase.Balance)
 			}
 
 			query := `
@@ -231,15 +268,12 @@ func Test_BuyItem_E2E(t *testing.T) {
 func Test_SendCoin_E2E(t *testing.T) {
 	// For tests default coins in DB for users set to 100
 
-	httpHost := "http://localhost:8081"
-	client := &http.Client{}
-
-	cfg, err := config.New(".")
+	cfg, err := config.New(configPath)
 	assert.NoError(t, err)
 	cfg.DB.Port = "5433"
 	cfg.DB.Name = "shop_test"
 
-	db, err := dbinit.OpenDB(cfg)
+	db, err := dbinit.OpenDB(context.Background(), cfg, slog.Default())
 	assert.NoError(t, err)
 
 	tests := []struct {
@@ -251,6 +285,7 @@ func Test_SendCoin_E2E(t *testing.T) {
 		wantStatusCode      int
 		wantErr             bool
 		errResponse         string
+		errCode             string
 		wantSenderBalance   int
 		wantReceiverBalance int
 	}{
@@ -274,9 +309,12 @@ func Test_SendCoin_E2E(t *testing.T) {
 			wantStatusCode:      http.StatusBadRequest,
 			wantErr:             true,
 			errResponse:         "not enough coins",
+			errCode:             client.CodeInsufficientFunds,
 			wantSenderBalance:   100,
 			wantReceiverBalance: 100,
 		},
+		// The server runs in test mode, so MiddlewareOpenAPI rejects the amount the spec
+		// forbids before the handler does. The handler's own answer is covered by the route tests.
 		{
 			name:                "invalid request, non positive amount",
 			sender:              "aleksandr",
@@ -285,7 +323,8 @@ func Test_SendCoin_E2E(t *testing.T) {
 			amount:              -50,
 			wantStatusCode:      http.StatusBadRequest,
 			wantErr:             true,
-			errResponse:         "amount to send should be positive",
+			errResponse:         "request does not match openapi spec: request body has an error: doesn't match schema #/components/schemas/SendCoinRequest: amount: number must be at least 1",
+			errCode:             client.CodeInvalidRequest,
 			wantSenderBalance:   100,
 			wantReceiverBalance: 100,
 		},
@@ -298,6 +337,7 @@ func Test_SendCoin_E2E(t *testing.T) {
 			wantStatusCode:      http.StatusBadRequest,
 			wantErr:             true,
 			errResponse:         "can't send coins to yourself",
+			errCode:             client.CodeSelfTransfer,
 			wantSenderBalance:   100,
 			wantReceiverBalance: 100,
 		},
@@ -305,35 +345,25 @@ func Test_SendCoin_E2E(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, senderToken := AuthUser(t, tt.sender, tt.password)
-			AuthUser(t, tt.receiver, tt.password)
+			sender, err := AuthUser(t, tt.sender, tt.password)
+			require.NoError(t, err)
+			_, err = AuthUser(t, tt.receiver, tt.password)
+			require.NoError(t, err)
 
-			reqURL := fmt.Sprintf("%s/api/sendCoin", httpHost)
-			sendCoinReq := models.SendCoinRequest{
-				ReceiverName: tt.receiver,
-				Amount:       tt.amount,
-			}
+			transfer, err := sender.SendCoin(context.Background(), tt.receiver, tt.amount)
 
-			sendCoinBody, err := json.Marshal(sendCoinReq)
-			assert.NoError(t, err)
-
-			req, err := http.NewRequest("POST", reqURL, bytes.NewReader(sendCoinBody))
-			assert.NoError(t, err)
-
-			req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", senderToken))
-			req.Header.Add("Content-type", "application/json")
-
-			resp, err := client.Do(req)
-			assert.NoError(t, err)
-			defer resp.Body.Close()
-
-			assert.Equal(t, tt.wantStatusCode, resp.StatusCode)
+			assert.Equal(t, tt.wantStatusCode, statusCode(t, err))
 
 			if tt.wantErr {
-				errorResponse := &models.ErrorResponse{}
-				err := json.NewDecoder(resp.Body).Decode(errorResponse)
-				assert.NoError(t, err)
-				assert.Equal(t, tt.errResponse, errorResponse.Errors)
+				var apiErr *client.Error
+				require.ErrorAs(t, err, &apiErr)
+				assert.Equal(t, tt.errResponse, apiErr.Message)
+				assert.Equal(t, tt.errCode, apiErr.Code)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.receiver, transfer.ToUser)
+				assert.Equal(t, tt.amount, transfer.Amount)
+				assert.Equal(t, tt.wantSenderBalance, transfer.Balance)
 			}
 
 			query := `
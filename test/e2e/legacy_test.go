@@ -0,0 +1,412 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"merch-shop/internal/config"
+	"merch-shop/internal/dbinit"
+	"merch-shop/internal/models"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The unversioned /api routes are served to existing clients until their sunset date, so they
+// are tested with plain requests, the way those clients send them. Users are not shared with
+// the /api/v2 tests, whose balances they would change.
+
+// legacyRequest sends a JSON request to the unversioned API.
+func legacyRequest(t *testing.T, method, path, token string, body any) *http.Response {
+	t.Helper()
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&reqBody).Encode(body))
+	}
+
+	req, err := http.NewRequest(method, httpHost+path, &reqBody)
+	require.NoError(t, err)
+
+	if body != nil {
+		req.Header.Add("Content-type", "application/json")
+	}
+	if token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	assert.Equal(t, "true", resp.Header.Get("Deprecation"))
+
+	return resp
+}
+
+// legacyAuthUser logs the user in through /api/auth and returns the status and the token.
+func legacyAuthUser(t *testing.T, username, password string) (int, string) {
+	t.Helper()
+
+	resp := legacyRequest(t, http.MethodPost, "/api/auth", "", models.AuthRequest{
+		Username: username,
+		Password: password,
+	})
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, ""
+	}
+
+	authResponse := models.AuthResponse{}
+	err := json.NewDecoder(resp.Body).Decode(&authResponse)
+	assert.NoError(t, err)
+
+	assert.NotEmpty(t, authResponse.Token)
+	return resp.StatusCode, authResponse.Token
+}
+
+// legacyError decodes the error response of a legacy route.
+func legacyError(t *testing.T, resp *http.Response) *models.ErrorResponse {
+	t.Helper()
+
+	errorResponse := &models.ErrorResponse{}
+	err := json.NewDecoder(resp.Body).Decode(errorResponse)
+	assert.NoError(t, err)
+
+	return errorResponse
+}
+
+func Test_Auth_Legacy_E2E(t *testing.T) {
+	tests := []struct {
+		name           string
+		username       string
+		password       string
+		wantStatusCode int
+		errResponse    string
+		errCode        string
+	}{
+		{
+			name:           "valid request",
+			username:       "legacy-bob",
+			password:       "password",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "invalid request, empty username",
+			username:       "",
+			password:       "password",
+			wantStatusCode: http.StatusBadRequest,
+			errResponse:    "empty name or password specified",
+			errCode:        models.CodeEmptyCredentials,
+		},
+		{
+			name:           "invalid request, empty password",
+			username:       "legacy-fake",
+			password:       "",
+			wantStatusCode: http.StatusBadRequest,
+			errResponse:    "empty name or password specified",
+			errCode:        models.CodeEmptyCredentials,
+		},
+		{
+			name:           "invalid request, too long password",
+			username:       "legacy-fake",
+			password:       string(make([]byte, 76)),
+			wantStatusCode: http.StatusBadRequest,
+			errResponse:    "password is longer then 72 ASCII characters",
+			errCode:        models.CodePasswordTooLong,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := legacyRequest(t, http.MethodPost, "/api/auth", "", models.AuthRequest{
+				Username: tt.username,
+				Password: tt.password,
+			})
+
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode)
+
+			if tt.errCode != "" {
+				errorResponse := legacyError(t, resp)
+				assert.Equal(t, tt.errResponse, errorResponse.Errors)
+				assert.Equal(t, tt.errCode, errorResponse.Code)
+			} else {
+				authResponse := models.AuthResponse{}
+				err := json.NewDecoder(resp.Body).Decode(&authResponse)
+				assert.NoError(t, err)
+				assert.NotEmpty(t, authResponse.Token)
+			}
+		})
+	}
+
+	t.Run("invalid request, wrong password", func(t *testing.T) {
+		resp := legacyRequest(t, http.MethodPost, "/api/auth", "", models.AuthRequest{
+			Username: "legacy-bob",
+			Password: "wrong password",
+		})
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, models.CodeWrongPassword, legacyError(t, resp).Code)
+	})
+
+	// Baseline clients send the JSON body without a Content-Type, or with curl's default one.
+	for _, contentType := range []string{"", "application/x-www-form-urlencoded"} {
+		t.Run(fmt.Sprintf("valid request, content type %q", contentType), func(t *testing.T) {
+			body := strings.NewReader(`{"username": "legacy-bob", "password": "password"}`)
+			req, err := http.NewRequest(http.MethodPost, httpHost+"/api/auth", body)
+			require.NoError(t, err)
+			if contentType != "" {
+				req.Header.Set("Content-Type", contentType)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			authResponse := models.AuthResponse{}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&authResponse))
+			assert.NotEmpty(t, authResponse.Token)
+		})
+	}
+}
+
+func Test_BuyItem_Legacy_E2E(t *testing.T) {
+	// For tests default coins in DB for users set to 100
+
+	tests := []struct {
+		name           string
+		username       string
+		item           string
+		wantStatusCode int
+		errResponse    string
+		errCode        string
+		wantBalance    int
+	}{
+		{
+			name:           "valid request, success buy",
+			username:       "legacy-carl",
+			item:           "book", // 50
+			wantStatusCode: http.StatusOK,
+			wantBalance:    50,
+		},
+		{
+			name:           "valid request, not enough coins",
+			username:       "legacy-sarah",
+			item:           "pink-hoody", // 500
+			wantStatusCode: http.StatusBadRequest,
+			errResponse:    "not enough coins",
+			errCode:        models.CodeInsufficientFunds,
+		},
+		{
+			name:           "invalid request, item not exists",
+			username:       "legacy-kenny",
+			item:           "beer",
+			wantStatusCode: http.StatusBadRequest,
+			errResponse:    "item: record not found",
+			errCode:        models.CodeItemNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, token := legacyAuthUser(t, tt.username, "password")
+
+			resp := legacyRequest(t, http.MethodGet, "/api/buy/"+tt.item, token, nil)
+
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode)
+
+			if tt.errCode != "" {
+				errorResponse := legacyError(t, resp)
+				assert.Equal(t, tt.errResponse, errorResponse.Errors)
+				assert.Equal(t, tt.errCode, errorResponse.Code)
+			} else {
+				purchase := &models.Purchase{}
+				err := json.NewDecoder(resp.Body).Decode(purchase)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.item, purchase.Item)
+				assert.Equal(t, tt.wantBalance, purchase.Balance)
+			}
+		})
+	}
+
+	t.Run("invalid request, no token", func(t *testing.T) {
+		resp := legacyRequest(t, http.MethodGet, "/api/buy/book", "", nil)
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, models.CodeMissingToken, legacyError(t, resp).Code)
+	})
+}
+
+func Test_Info_Legacy_E2E(t *testing.T) {
+	// For tests default coins in DB for users set to 100
+
+	_, token := legacyAuthUser(t, "legacy-olga", "password")
+	_, receiverToken := legacyAuthUser(t, "legacy-pavel", "password")
+
+	resp := legacyRequest(t, http.MethodGet, "/api/buy/pen", token, nil) // 10
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = legacyRequest(t, http.MethodPost, "/api/sendCoin", token, models.SendCoinRequest{
+		ReceiverName: "legacy-pavel",
+		Amount:       20,
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = legacyRequest(t, http.MethodGet, "/api/info", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	info := &models.InfoResponse{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(info))
+	assert.Equal(t, 70, info.Coins)
+	require.Len(t, info.Inventory, 1)
+	assert.Equal(t, models.InventoryItem{Type: "pen", Quantity: 1}, *info.Inventory[0])
+	require.Len(t, info.CoinHistory.Sent, 1)
+	assert.Equal(t, models.CoinTransaction{ToUser: "legacy-pavel", Amount: 20}, *info.CoinHistory.Sent[0])
+	assert.Empty(t, info.CoinHistory.Received)
+
+	resp = legacyRequest(t, http.MethodGet, "/api/info", receiverToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	info = &models.InfoResponse{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(info))
+	assert.Equal(t, 120, info.Coins)
+	require.Len(t, info.CoinHistory.Received, 1)
+	assert.Equal(t, models.CoinTransaction{FromUser: "legacy-olga", Amount: 20}, *info.CoinHistory.Received[0])
+
+	resp = legacyRequest(t, http.MethodGet, "/api/info", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func Test_SendCoin_Legacy_E2E(t *testing.T) {
+	// For tests default coins in DB for users set to 100
+
+	cfg, err := config.New(configPath)
+	assert.NoError(t, err)
+	cfg.DB.Port = "5433"
+	cfg.DB.Name = "shop_test"
+
+	db, err := dbinit.OpenDB(context.Background(), cfg, slog.Default())
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name                string
+		sender              string
+		receiver            string
+		password            string
+		amount              int
+		wantStatusCode      int
+		wantErr             bool
+		errResponse         string
+		errCode             string
+		wantSenderBalance   int
+		wantReceiverBalance int
+	}{
+		{
+			name:                "valid request, success send",
+			sender:              "legacy-ivan",
+			receiver:            "legacy-anna",
+			password:            "password",
+			amount:              50,
+			wantStatusCode:      http.StatusOK,
+			wantErr:             false,
+			wantSenderBalance:   50,
+			wantReceiverBalance: 150,
+		},
+		{
+			name:                "invalid request, not enough coins",
+			sender:              "legacy-andrey",
+			receiver:            "legacy-elena",
+			password:            "password",
+			amount:              200,
+			wantStatusCode:      http.StatusBadRequest,
+			wantErr:             true,
+			errResponse:         "not enough coins",
+			errCode:             models.CodeInsufficientFunds,
+			wantSenderBalance:   100,
+			wantReceiverBalance: 100,
+		},
+		// The server runs in test mode, so MiddlewareOpenAPI rejects the amount the spec
+		// forbids before the handler does. The handler's own answer is covered by the route tests.
+		{
+			name:                "invalid request, non positive amount",
+			sender:              "legacy-aleksandr",
+			receiver:            "legacy-alisa",
+			password:            "password",
+			amount:              -50,
+			wantStatusCode:      http.StatusBadRequest,
+			wantErr:             true,
+			errResponse:         "request does not match openapi spec: request body has an error: doesn't match schema #/components/schemas/SendCoinRequest: amount: number must be at least 1",
+			errCode:             models.CodeInvalidRequest,
+			wantSenderBalance:   100,
+			wantReceiverBalance: 100,
+		},
+		{
+			name:                "invalid request, sending yourself",
+			sender:              "legacy-sergey",
+			receiver:            "legacy-sergey",
+			password:            "password",
+			amount:              50,
+			wantStatusCode:      http.StatusBadRequest,
+			wantErr:             true,
+			errResponse:         "can't send coins to yourself",
+			errCode:             models.CodeSelfTransfer,
+			wantSenderBalance:   100,
+			wantReceiverBalance: 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, senderToken := legacyAuthUser(t, tt.sender, tt.password)
+			legacyAuthUser(t, tt.receiver, tt.password)
+
+			resp := legacyRequest(t, http.MethodPost, "/api/sendCoin", senderToken, models.SendCoinRequest{
+				ReceiverName: tt.receiver,
+				Amount:       tt.amount,
+			})
+
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode)
+
+			if tt.wantErr {
+				errorResponse := &models.ErrorResponse{}
+				err := json.NewDecoder(resp.Body).Decode(errorResponse)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.errResponse, errorResponse.Errors)
+				assert.Equal(t, tt.errCode, errorResponse.Code)
+			} else {
+				transfer := &models.Transfer{}
+				err := json.NewDecoder(resp.Body).Decode(transfer)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.receiver, transfer.ToUser)
+				assert.Equal(t, tt.amount, transfer.Amount)
+				assert.Equal(t, tt.wantSenderBalance, transfer.Balance)
+			}
+
+			query := `
+			    SELECT balance
+			    FROM coins
+			    JOIN active_users ON coins.user_id = active_users.id
+			    WHERE active_users.username = $1`
+
+			var balance int
+			err = db.QueryRow(query, tt.sender).Scan(&balance)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tt.wantSenderBalance, balance)
+
+			err = db.QueryRow(query, tt.receiver).Scan(&balance)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tt.wantReceiverBalance, balance)
+		})
+	}
+}